@@ -1,12 +1,15 @@
 package main
 
 import (
+	"os"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/erumble/dynamo-playground/pkg/logger"
+	"github.com/erumble/dynamo-playground/pkg/metrics"
 	"github.com/erumble/dynamo-playground/pkg/node"
 )
 
@@ -42,7 +45,13 @@ func main() {
 	}))
 
 	dynamoSvc := dynamodb.New(sess)
-	client := node.NewClient(logger, dynamoSvc, "nodes", "ParentID-index")
+	recorder := metrics.NewPrometheus("playground", nil)
+	client := node.NewClient(logger, dynamoSvc, "nodes", "ParentID-index", node.WithMetrics(recorder))
+
+	logger.Info("validating table schema...")
+	if err := client.ValidateSchema(); err != nil {
+		logger.Errorf("Error validating table: %v", err)
+	}
 
 	logger.Info("populating table...")
 	if err := client.BatchPut(nodes); err != nil {
@@ -93,4 +102,9 @@ func main() {
 	if err := client.BatchPut(toWrite); err != nil {
 		logger.Errorf("Error updating table: %v", err)
 	}
+
+	logger.Info("metrics:")
+	if _, err := recorder.WriteTo(os.Stdout); err != nil {
+		logger.Errorf("Error writing metrics: %v", err)
+	}
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/erumble/dynamo-playground/pkg/loader"
+	"github.com/erumble/dynamo-playground/pkg/logger"
+	"github.com/erumble/dynamo-playground/pkg/node"
+	"github.com/erumble/dynamo-playground/pkg/ratelimit"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run loads the file and returns the exit code, so that its deferred calls
+// run before the program exits.
+func run() int {
+	var (
+		file       = flag.String("file", "", "NDJSON or CSV file of nodes to load (required)")
+		format     = flag.String("format", "", "file format, ndjson or csv (default: guessed from the extension)")
+		checkpoint = flag.String("checkpoint", "", "file to save progress to, and resume from")
+		workers    = flag.Int("workers", 4, "number of batches to write in parallel")
+		wcu        = flag.Float64("wcu", 0, "write capacity units per second to stay within (default: unlimited)")
+		interval   = flag.Duration("report", 10*time.Second, "how often to report throughput")
+		table      = flag.String("table", "nodes", "DynamoDB table to load into")
+		gsi        = flag.String("gsi", "ParentID-index", "ParentID GSI of the table")
+		indexed    = flag.Bool("indexed-children", false, "only keep track of children in the GSI, for very wide parents")
+		region     = flag.String("region", "us-east-1", "AWS region")
+		profile    = flag.String("profile", "personal", "AWS shared credentials profile")
+		logLevel   = flag.String("log-level", "info", "log level")
+	)
+	flag.Parse()
+
+	log := logger.NewLeveledLogger(logLevel)
+
+	// Send SIGUSR1 to switch to debug logging and back, without a restart.
+	defer logger.ToggleDebugOnSignal(log)()
+
+	if *file == "" {
+		flag.Usage()
+		return 2
+	}
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String(*region),
+		Credentials: credentials.NewSharedCredentials("", *profile),
+	}))
+
+	opts := []node.ClientOption{node.WithRateLimiter(ratelimit.NewLimiter(0, *wcu))}
+	if *indexed {
+		opts = append(opts, node.WithChildStorage(node.IndexedChildren))
+	}
+
+	client := node.NewClient(log, dynamodb.New(sess), *table, *gsi, opts...)
+
+	l := loader.New(log, client, loader.Config{
+		Format:         loader.Format(*format),
+		Workers:        *workers,
+		CheckpointPath: *checkpoint,
+		ReportInterval: *interval,
+	})
+
+	if _, err := l.LoadFile(*file); err != nil {
+		log.Errorf("Error loading nodes: %v", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/erumble/dynamo-playground/pkg/logger"
+	"github.com/erumble/dynamo-playground/pkg/node"
+)
+
+const usage = `Usage: nodectl [flags] <command> [command flags]
+
+Commands:
+  export -root ID [-out FILE]      write a subtree as nested JSON
+  import [-parent ID] [-in FILE] [-overwrite]
+                                   store a nested JSON document
+  backfill-positions               give a Position to children stored without one
+
+Flags:
+`
+
+func main() {
+	var (
+		table    = flag.String("table", "nodes", "DynamoDB table")
+		gsi      = flag.String("gsi", "ParentID-index", "ParentID GSI of the table")
+		indexed  = flag.Bool("indexed-children", false, "only keep track of children in the GSI, for very wide parents")
+		region   = flag.String("region", "us-east-1", "AWS region")
+		profile  = flag.String("profile", "personal", "AWS shared credentials profile")
+		logLevel = flag.String("log-level", "info", "log level")
+	)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger := logger.NewLeveledLogger(logLevel)
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String(*region),
+		Credentials: credentials.NewSharedCredentials("", *profile),
+	}))
+
+	opts := []node.ClientOption{}
+	if *indexed {
+		opts = append(opts, node.WithChildStorage(node.IndexedChildren))
+	}
+
+	client := node.NewClient(logger, dynamodb.New(sess), *table, *gsi, opts...)
+
+	var err error
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "export":
+		err = export(client, args)
+	case "import":
+		err = importNested(client, args)
+	case "backfill-positions":
+		err = backfillPositions(client)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		logger.Errorf("Error running %s: %v", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+// export writes the subtree given on the command line as nested JSON.
+func export(client node.Client, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	root := fs.String("root", "", "ID of the root of the subtree to export (required)")
+	out := fs.String("out", "-", "file to write to, - for stdout")
+	fs.Parse(args)
+
+	if *root == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return client.ExportNested(w, *root)
+}
+
+// backfillPositions renumbers the children stored without a Position,
+// printing how many nodes were written.
+func backfillPositions(client node.Client) error {
+	n, err := client.BackfillPositions()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("renumbered %d nodes\n", n)
+
+	return nil
+}
+
+// importNested stores the nested JSON document given on the command line,
+// printing the IDs of its roots.
+func importNested(client node.Client, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	parent := fs.String("parent", "", "ID of the node to import under (default: import as new roots)")
+	in := fs.String("in", "-", "file to read from, - for stdin")
+	overwrite := fs.Bool("overwrite", false, "replace stored nodes with the same IDs, instead of failing")
+	fs.Parse(args)
+
+	var r io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	ids, err := client.ImportNested(r, *parent, node.ImportOptions{Overwrite: *overwrite})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		fmt.Println(id)
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+// Package diff compares two sets of nodes, e.g. two tables, two subtrees, or
+// a table and an exported file, and describes how to get from one to the other.
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/erumble/dynamo-playground/pkg/node"
+)
+
+// Op is the kind of a Change.
+type Op string
+
+const (
+	// Add means the Node only exists in the target.
+	Add Op = "add"
+	// Remove means the Node only exists in the source.
+	Remove Op = "remove"
+	// Move means the Node's ParentID changed.
+	Move Op = "move"
+	// Reorder means the order of the Node's children changed.
+	Reorder Op = "reorder"
+	// Modify means the Node's Metadata changed.
+	Modify Op = "modify"
+)
+
+// opOrder is the order Changes are listed in.
+var opOrder = map[Op]int{Add: 0, Remove: 1, Move: 2, Reorder: 3, Modify: 4}
+
+// Change describes a single difference between the source and the target.
+// Which fields are set depends on the Op.
+type Change struct {
+	Op Op     `json:"op"`
+	ID string `json:"id"`
+
+	// Node is the added Node for Add, and the removed Node for Remove.
+	Node *node.Node `json:"node,omitempty"`
+
+	// FromParent and ToParent are the old and new ParentID for Move, an
+	// empty ParentID means the Node is a root.
+	FromParent string `json:"from_parent,omitempty"`
+	ToParent   string `json:"to_parent,omitempty"`
+
+	// FromOrder and ToOrder are the old and new order of the children that
+	// exist in both sets for Reorder.
+	FromOrder []string `json:"from_order,omitempty"`
+	ToOrder   []string `json:"to_order,omitempty"`
+
+	// FromMetadata and ToMetadata are the old and new Metadata for Modify.
+	FromMetadata string `json:"from_metadata,omitempty"`
+	ToMetadata   string `json:"to_metadata,omitempty"`
+}
+
+// String returns a human readable description of the Change.
+func (c Change) String() string {
+	switch c.Op {
+	case Add, Remove:
+		sign := map[Op]string{Add: "+", Remove: "-"}[c.Op]
+
+		// A Change decoded from JSON, or built by hand, might not have its Node.
+		if c.Node == nil {
+			return fmt.Sprintf("%s %s", sign, c.ID)
+		}
+
+		return fmt.Sprintf("%s %s (parent %s)", sign, c.ID, describeParent(c.Node.ParentID))
+	case Move:
+		return fmt.Sprintf("> %s moved from %s to %s", c.ID, describeParent(c.FromParent), describeParent(c.ToParent))
+	case Reorder:
+		return fmt.Sprintf("^ %s children reordered from [%s] to [%s]", c.ID, strings.Join(c.FromOrder, ", "), strings.Join(c.ToOrder, ", "))
+	case Modify:
+		return fmt.Sprintf("~ %s metadata changed from %q to %q", c.ID, c.FromMetadata, c.ToMetadata)
+	}
+
+	return fmt.Sprintf("? %s %s", c.Op, c.ID)
+}
+
+func describeParent(id string) string {
+	if id == "" {
+		return "<root>"
+	}
+
+	return id
+}
+
+// Result is the list of Changes between two sets of nodes.
+type Result struct {
+	Changes []Change
+}
+
+// Empty returns true if the sets were the same.
+func (r Result) Empty() bool {
+	return len(r.Changes) == 0
+}
+
+// String returns a human readable description of every Change, one per line.
+func (r Result) String() string {
+	buf := &bytes.Buffer{}
+	for _, c := range r.Changes {
+		fmt.Fprintln(buf, c)
+	}
+
+	return buf.String()
+}
+
+// MarshalJSON encodes the Result as a JSON array of Changes, similar to a
+// JSON patch.
+func (r Result) MarshalJSON() ([]byte, error) {
+	if r.Changes == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(r.Changes)
+}
+
+// UnmarshalJSON decodes a Result encoded with MarshalJSON.
+func (r *Result) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &r.Changes)
+}
+
+// Compare returns the Changes that turn the from set of nodes into the to
+// set. Nodes are matched by ID. Changes are ordered by Op, then by ID.
+func Compare(from, to []*node.Node) Result {
+	src := index(from)
+	dst := index(to)
+
+	changes := []Change{}
+
+	for id, n := range dst {
+		old, ok := src[id]
+		if !ok {
+			changes = append(changes, Change{Op: Add, ID: id, Node: n})
+			continue
+		}
+
+		if old.ParentID != n.ParentID {
+			changes = append(changes, Change{Op: Move, ID: id, FromParent: old.ParentID, ToParent: n.ParentID})
+		}
+
+		if old.Metadata != n.Metadata {
+			changes = append(changes, Change{Op: Modify, ID: id, FromMetadata: old.Metadata, ToMetadata: n.Metadata})
+		}
+
+		// Only the relative order of the children that are in both sets is
+		// compared, added, removed and moved children are reported already.
+		fromOrder, toOrder := commonOrder(childOrder(old, src), childOrder(n, dst))
+		if !equal(fromOrder, toOrder) {
+			changes = append(changes, Change{Op: Reorder, ID: id, FromOrder: fromOrder, ToOrder: toOrder})
+		}
+	}
+
+	for id, n := range src {
+		if _, ok := dst[id]; !ok {
+			changes = append(changes, Change{Op: Remove, ID: id, Node: n})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Op != changes[j].Op {
+			return opOrder[changes[i].Op] < opOrder[changes[j].Op]
+		}
+		return changes[i].ID < changes[j].ID
+	})
+
+	return Result{Changes: changes}
+}
+
+func index(nodes []*node.Node) map[string]*node.Node {
+	byID := map[string]*node.Node{}
+	for _, n := range nodes {
+		byID[n.ID] = n
+	}
+
+	return byID
+}
+
+// childOrder returns the IDs of the children of parent within the set. The
+// parent's ChildIDs are used when they are available, otherwise the children
+// are ordered by Position, which is all there is when children are indexed.
+func childOrder(parent *node.Node, set map[string]*node.Node) []string {
+	if len(parent.ChildIDs) > 0 {
+		return parent.ChildIDs
+	}
+
+	children := []*node.Node{}
+	for _, n := range set {
+		if n.ParentID == parent.ID {
+			children = append(children, n)
+		}
+	}
+
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].Position != children[j].Position {
+			return children[i].Position < children[j].Position
+		}
+		return children[i].ID < children[j].ID
+	})
+
+	ids := []string{}
+	for _, n := range children {
+		ids = append(ids, n.ID)
+	}
+
+	return ids
+}
+
+// commonOrder filters each list down to the IDs that are in both.
+func commonOrder(a, b []string) ([]string, []string) {
+	inA, inB := map[string]bool{}, map[string]bool{}
+	for _, id := range a {
+		inA[id] = true
+	}
+	for _, id := range b {
+		inB[id] = true
+	}
+
+	fa, fb := []string{}, []string{}
+	for _, id := range a {
+		if inB[id] {
+			fa = append(fa, id)
+		}
+	}
+	for _, id := range b {
+		if inA[id] {
+			fb = append(fb, id)
+		}
+	}
+
+	return fa, fb
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,185 @@
+package diff
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/erumble/dynamo-playground/pkg/node"
+)
+
+func TestChangeString(t *testing.T) {
+	tests := []struct {
+		change Change
+		want   string
+	}{
+		{Change{Op: Add, ID: "a", Node: &node.Node{ID: "a", ParentID: "p"}}, "+ a (parent p)"},
+		{Change{Op: Add, ID: "a"}, "+ a"},
+		{Change{Op: Remove, ID: "a", Node: &node.Node{ID: "a"}}, "- a (parent <root>)"},
+		{Change{Op: Remove, ID: "a"}, "- a"},
+		{Change{Op: Move, ID: "a", FromParent: "p", ToParent: ""}, "> a moved from p to <root>"},
+		{Change{Op: Reorder, ID: "p", FromOrder: []string{"a", "b"}, ToOrder: []string{"b", "a"}}, "^ p children reordered from [a, b] to [b, a]"},
+		{Change{Op: Modify, ID: "a", FromMetadata: "x", ToMetadata: "y"}, `~ a metadata changed from "x" to "y"`},
+		{Change{Op: "other", ID: "a"}, "? other a"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.change.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// tree returns a parent p with children a and b, changed by each of the given
+// functions, as a slice ordered by ID.
+func tree(positions bool, changes ...func(map[string]*node.Node)) []*node.Node {
+	nodes := map[string]*node.Node{
+		"p": {ID: "p", ChildIDs: []string{"a", "b"}, Metadata: "parent"},
+		"a": {ID: "a", ParentID: "p", Position: "1"},
+		"b": {ID: "b", ParentID: "p", Position: "2"},
+	}
+	if positions {
+		nodes["p"].ChildIDs = nil
+	}
+
+	for _, change := range changes {
+		change(nodes)
+	}
+
+	list := []*node.Node{}
+	for _, n := range nodes {
+		list = append(list, n)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	return list
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name      string
+		positions bool
+		change    func(map[string]*node.Node)
+		want      []Change
+	}{
+		{
+			name:   "same",
+			change: func(map[string]*node.Node) {},
+			want:   []Change{},
+		},
+		{
+			name: "add",
+			change: func(m map[string]*node.Node) {
+				m["c"] = &node.Node{ID: "c", ParentID: "p", Position: "3"}
+				m["p"].ChildIDs = append(m["p"].ChildIDs, "c")
+			},
+			want: []Change{{Op: Add, ID: "c", Node: &node.Node{ID: "c", ParentID: "p", Position: "3"}}},
+		},
+		{
+			name: "remove",
+			change: func(m map[string]*node.Node) {
+				delete(m, "b")
+				m["p"].ChildIDs = []string{"a"}
+			},
+			want: []Change{{Op: Remove, ID: "b", Node: &node.Node{ID: "b", ParentID: "p", Position: "2"}}},
+		},
+		{
+			name: "move",
+			change: func(m map[string]*node.Node) {
+				m["p"].ChildIDs = []string{"a"}
+				m["a"].ChildIDs = []string{"b"}
+				m["b"].ParentID = "a"
+			},
+			want: []Change{{Op: Move, ID: "b", FromParent: "p", ToParent: "a"}},
+		},
+		{
+			name: "move to root",
+			change: func(m map[string]*node.Node) {
+				m["p"].ChildIDs = []string{"b"}
+				m["a"].ParentID, m["a"].Position = "", ""
+			},
+			want: []Change{{Op: Move, ID: "a", FromParent: "p", ToParent: ""}},
+		},
+		{
+			name: "metadata",
+			change: func(m map[string]*node.Node) {
+				m["p"].Metadata = "changed"
+			},
+			want: []Change{{Op: Modify, ID: "p", FromMetadata: "parent", ToMetadata: "changed"}},
+		},
+		{
+			name: "reorder",
+			change: func(m map[string]*node.Node) {
+				m["p"].ChildIDs = []string{"b", "a"}
+			},
+			want: []Change{{Op: Reorder, ID: "p", FromOrder: []string{"a", "b"}, ToOrder: []string{"b", "a"}}},
+		},
+		{
+			name:      "reorder by position",
+			positions: true,
+			change: func(m map[string]*node.Node) {
+				m["a"].Position, m["b"].Position = "2", "1"
+			},
+			want: []Change{{Op: Reorder, ID: "p", FromOrder: []string{"a", "b"}, ToOrder: []string{"b", "a"}}},
+		},
+		{
+			name: "new position only",
+			change: func(m map[string]*node.Node) {
+				m["a"].Position = "0"
+			},
+			want: []Change{},
+		},
+		{
+			name: "ordered by op then ID",
+			change: func(m map[string]*node.Node) {
+				m["b"].Metadata = "b"
+				m["a"].Metadata = "a"
+				delete(m, "p")
+				m["q"] = &node.Node{ID: "q"}
+			},
+			want: []Change{
+				{Op: Add, ID: "q", Node: &node.Node{ID: "q"}},
+				{Op: Remove, ID: "p", Node: &node.Node{ID: "p", ChildIDs: []string{"a", "b"}, Metadata: "parent"}},
+				{Op: Modify, ID: "a", FromMetadata: "", ToMetadata: "a"},
+				{Op: Modify, ID: "b", FromMetadata: "", ToMetadata: "b"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compare(tree(tt.positions), tree(tt.positions, tt.change))
+			if !reflect.DeepEqual(got.Changes, tt.want) {
+				t.Errorf("Compare =\n%v\nwant\n%v", got, Result{Changes: tt.want})
+			}
+			if got.Empty() != (len(tt.want) == 0) {
+				t.Errorf("Empty() = %v with %d changes", got.Empty(), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestResultJSON(t *testing.T) {
+	r := Compare(tree(false), tree(false, func(m map[string]*node.Node) {
+		m["p"].ChildIDs = []string{"b", "a"}
+		m["a"].Metadata = "a"
+	}))
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	decoded := Result{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, r) {
+		t.Errorf("decoded %s as %+v, want %+v", b, decoded, r)
+	}
+
+	if b, _ := json.Marshal(Result{}); string(b) != "[]" {
+		t.Errorf("an empty Result marshals to %s, want []", b)
+	}
+}
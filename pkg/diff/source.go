@@ -0,0 +1,62 @@
+package diff
+
+import (
+	"os"
+
+	"github.com/erumble/dynamo-playground/pkg/node"
+	"github.com/pkg/errors"
+)
+
+// Source provides one of the sets of nodes to compare.
+type Source func() ([]*node.Node, error)
+
+// Table is a Source of every Node in the Client's table.
+func Table(c node.Client) Source {
+	return func() ([]*node.Node, error) {
+		return c.GetAll()
+	}
+}
+
+// Subtree is a Source of the Node with the given ID, and its descendants, in
+// the Client's table.
+func Subtree(c node.Client, rootID string) Source {
+	return func() ([]*node.Node, error) {
+		return c.GetSubtree(rootID)
+	}
+}
+
+// File is a Source of the nodes in a newline delimited JSON file, as written
+// by node.WriteNDJSON.
+func File(path string) Source {
+	return func() ([]*node.Node, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		return node.ReadNDJSON(f)
+	}
+}
+
+// Nodes is a Source of the given nodes.
+func Nodes(nodes []*node.Node) Source {
+	return func() ([]*node.Node, error) {
+		return nodes, nil
+	}
+}
+
+// Sources fetches both sets of nodes and compares them.
+func Sources(from, to Source) (Result, error) {
+	src, err := from()
+	if err != nil {
+		return Result{}, errors.Wrap(err, "diff: error reading source")
+	}
+
+	dst, err := to()
+	if err != nil {
+		return Result{}, errors.Wrap(err, "diff: error reading target")
+	}
+
+	return Compare(src, dst), nil
+}
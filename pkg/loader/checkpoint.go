@@ -0,0 +1,86 @@
+package loader
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// checkpoint tracks how many records, from the start of the file, have all
+// been written. Batches finish out of order, so the checkpoint only advances
+// once every batch before it has finished too.
+type checkpoint struct {
+	path string
+
+	mu       sync.Mutex
+	done     int
+	finished map[int]int
+}
+
+// loadCheckpoint reads the checkpoint at path, if there is one. An empty path
+// disables checkpointing.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	c := &checkpoint{path: path, finished: map[int]int{}}
+	if path == "" {
+		return c, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "error reading checkpoint")
+	}
+
+	c.done, err = strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid checkpoint in %s", path)
+	}
+
+	return c, nil
+}
+
+// Done returns how many records from the start of the file have been written.
+func (c *checkpoint) Done() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.done
+}
+
+// Finish records that the count records starting at start have been written,
+// and saves the checkpoint if it advanced.
+func (c *checkpoint) Finish(start, count int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.finished[start] = count
+
+	advanced := false
+	for {
+		n, ok := c.finished[c.done]
+		if !ok {
+			break
+		}
+		delete(c.finished, c.done)
+		c.done += n
+		advanced = true
+	}
+
+	if !advanced || c.path == "" {
+		return nil
+	}
+
+	// Write to a temporary file and rename it, so a crash never leaves a
+	// truncated checkpoint behind.
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.Itoa(c.done)+"\n"), 0644); err != nil {
+		return errors.Wrap(err, "error writing checkpoint")
+	}
+
+	return errors.Wrap(os.Rename(tmp, c.path), "error writing checkpoint")
+}
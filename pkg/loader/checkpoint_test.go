@@ -0,0 +1,91 @@
+package loader
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// readCheckpoint returns the contents of the checkpoint file at path.
+func readCheckpoint(t *testing.T, path string) string {
+	t.Helper()
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	return string(b)
+}
+
+func TestCheckpointFinishOutOfOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint")
+
+	cp, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("loadCheckpoint: %v", err)
+	}
+
+	// The later batches finish first, and can not advance the checkpoint.
+	for _, start := range []int{50, 25} {
+		if err := cp.Finish(start, 25); err != nil {
+			t.Fatalf("Finish(%d): %v", start, err)
+		}
+		if cp.Done() != 0 {
+			t.Errorf("Done = %d after finishing records from %d, want 0", cp.Done(), start)
+		}
+	}
+	if _, err := ioutil.ReadFile(path); err == nil {
+		t.Error("the checkpoint was saved before it advanced")
+	}
+
+	if err := cp.Finish(0, 25); err != nil {
+		t.Fatalf("Finish(0): %v", err)
+	}
+	if cp.Done() != 75 {
+		t.Errorf("Done = %d, want 75", cp.Done())
+	}
+	if got := readCheckpoint(t, path); got != "75\n" {
+		t.Errorf("checkpoint file = %q, want 75", got)
+	}
+
+	// A gap holds the checkpoint back until it is filled.
+	if err := cp.Finish(90, 10); err != nil {
+		t.Fatalf("Finish(90): %v", err)
+	}
+	if cp.Done() != 75 {
+		t.Errorf("Done = %d with records 75 to 90 unwritten, want 75", cp.Done())
+	}
+	if err := cp.Finish(75, 15); err != nil {
+		t.Fatalf("Finish(75): %v", err)
+	}
+	if cp.Done() != 100 {
+		t.Errorf("Done = %d, want 100", cp.Done())
+	}
+
+	resumed, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("loadCheckpoint: %v", err)
+	}
+	if resumed.Done() != 100 {
+		t.Errorf("loaded checkpoint Done = %d, want 100", resumed.Done())
+	}
+}
+
+func TestLoadCheckpoint(t *testing.T) {
+	cp, err := loadCheckpoint("")
+	if err != nil || cp.Done() != 0 {
+		t.Fatalf("loadCheckpoint without a path = %v, %v, want an empty checkpoint", cp, err)
+	}
+	if err := cp.Finish(0, 25); err != nil || cp.Done() != 25 {
+		t.Errorf("Finish without a path = %v, Done %d, want 25", err, cp.Done())
+	}
+
+	path := filepath.Join(t.TempDir(), "checkpoint")
+	if err := ioutil.WriteFile(path, []byte("not a number\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := loadCheckpoint(path); err == nil {
+		t.Error("loadCheckpoint of an invalid file returned no error")
+	}
+}
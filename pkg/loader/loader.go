@@ -0,0 +1,319 @@
+// Package loader bulk loads nodes from NDJSON or CSV files into DynamoDB.
+package loader
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/erumble/dynamo-playground/pkg/logger"
+	"github.com/erumble/dynamo-playground/pkg/node"
+	"github.com/pkg/errors"
+)
+
+// batchSize is the most items DynamoDB accepts in a single BatchWriteItem.
+const batchSize = 25
+
+// Config configures a Loader.
+type Config struct {
+	// Format of the file, FormatFromPath is used when it is empty.
+	Format Format
+
+	// Workers is the number of batches written in parallel, defaults to 4.
+	Workers int
+
+	// CheckpointPath, if set, is where progress is saved. If the file exists
+	// when loading starts, the records it covers are skipped.
+	CheckpointPath string
+
+	// ReportInterval is how often throughput is logged, defaults to 10s.
+	ReportInterval time.Duration
+}
+
+// Stats summarises a load.
+type Stats struct {
+	// Records is the number of records in the file.
+	Records int
+	// Skipped is the number of records a previous load already wrote.
+	Skipped int
+	// Written is the number of records written by this load.
+	Written int
+	// Duration is how long writing took.
+	Duration time.Duration
+}
+
+// Rate returns the number of records written per second.
+func (s Stats) Rate() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+
+	return float64(s.Written) / s.Duration.Seconds()
+}
+
+// Loader writes the nodes in a file to DynamoDB through a node.Client. Give
+// the Client a node.WithRateLimiter to keep the load within the table's
+// provisioned throughput.
+type Loader struct {
+	client node.Client
+	log    logger.LeveledLogger
+	cfg    Config
+}
+
+// New creates a Loader.
+func New(logger logger.LeveledLogger, client node.Client, cfg Config) Loader {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+
+	if cfg.ReportInterval <= 0 {
+		cfg.ReportInterval = 10 * time.Second
+	}
+
+	return Loader{
+		client: client,
+		log:    logger.Indent("loader"),
+		cfg:    cfg,
+	}
+}
+
+// structure is what the first pass learns about the tree, so each node can be
+// written complete in the second.
+type structure struct {
+	records   int
+	parents   map[string]*node.Node
+	positions map[string]string
+}
+
+// LoadFile loads every node in the file at path. The file is read twice,
+// first to derive each node's ChildIDs and Position, then to write the nodes
+// in batches of 25 with a pool of workers.
+func (l Loader) LoadFile(path string) (Stats, error) {
+	log := l.log.Indent("LoadFile")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	format := l.cfg.Format
+	if format == "" {
+		format = FormatFromPath(path)
+	}
+
+	cp, err := loadCheckpoint(l.cfg.CheckpointPath)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	log.Infof("indexing %s...", path)
+	s, err := l.index(path, format)
+	if err != nil {
+		return Stats{}, errors.Wrap(err, "Loader.LoadFile: error indexing file")
+	}
+
+	stats := Stats{Records: s.records, Skipped: cp.Done()}
+	if stats.Skipped > 0 {
+		log.Infof("resuming after %d records...", stats.Skipped)
+	}
+
+	start := time.Now()
+	written, err := l.write(path, format, s, cp)
+	stats.Written = written
+	stats.Duration = time.Since(start)
+
+	if err != nil {
+		return stats, errors.Wrap(err, "Loader.LoadFile: error writing nodes")
+	}
+
+	log.Infof("wrote %d records in %s (%.1f records/s)", stats.Written, stats.Duration, stats.Rate())
+
+	return stats, nil
+}
+
+// openRecords opens the file at path for reading records.
+func openRecords(path string, format Format) (recordReader, io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := newRecordReader(f, format)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return r, f, nil
+}
+
+// index is the first pass, it registers every node with its parent, in file
+// order, keeping only IDs in memory.
+func (l Loader) index(path string, format Format) (*structure, error) {
+	r, f, err := openRecords(path, format)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := &structure{parents: map[string]*node.Node{}, positions: map[string]string{}}
+	seen := map[string]bool{}
+
+	for {
+		n, err := r.Read()
+		if err == io.EOF {
+			return s, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if seen[n.ID] {
+			return nil, errors.Errorf("duplicate id %s", n.ID)
+		}
+		seen[n.ID] = true
+		s.records++
+
+		if n.ParentID == "" {
+			continue
+		}
+
+		parent, ok := s.parents[n.ParentID]
+		if !ok {
+			parent = &node.Node{ID: n.ParentID}
+			s.parents[n.ParentID] = parent
+		}
+
+		child := &node.Node{ID: n.ID}
+		if err := parent.RegisterChild(child); err != nil {
+			return nil, errors.Wrapf(err, "record %d", s.records)
+		}
+		s.positions[n.ID] = child.Position
+	}
+}
+
+// write is the second pass, it streams the nodes to a pool of workers.
+func (l Loader) write(path string, format Format, s *structure, cp *checkpoint) (int, error) {
+	r, f, err := openRecords(path, format)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	type batch struct {
+		start int
+		nodes []*node.Node
+	}
+
+	var (
+		written  int64
+		firstErr error
+		errOnce  sync.Once
+		stop     = make(chan struct{})
+		batches  = make(chan batch, l.cfg.Workers)
+		wg       sync.WaitGroup
+	)
+
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			close(stop)
+		})
+	}
+
+	for i := 0; i < l.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				if err := l.client.BatchPut(b.nodes); err != nil {
+					fail(errors.Wrapf(err, "error writing records %d to %d", b.start+1, b.start+len(b.nodes)))
+					return
+				}
+
+				atomic.AddInt64(&written, int64(len(b.nodes)))
+				if err := cp.Finish(b.start, len(b.nodes)); err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+
+	reported := make(chan struct{})
+	go l.report(&written, s.records-cp.Done(), stop, reported)
+
+	skip := cp.Done()
+	current := batch{start: skip}
+
+	func() {
+		defer close(batches)
+
+		send := func() bool {
+			select {
+			case batches <- current:
+				return true
+			case <-stop:
+				return false
+			}
+		}
+
+		for i := 0; ; i++ {
+			n, err := r.Read()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				fail(err)
+				return
+			}
+
+			if i < skip {
+				continue
+			}
+
+			if parent, ok := s.parents[n.ID]; ok {
+				n.ChildIDs = parent.ChildIDs
+				n.ChildCount = parent.ChildCount
+				n.LastChildPosition = parent.LastChildPosition
+			}
+			n.Position = s.positions[n.ID]
+
+			current.nodes = append(current.nodes, n)
+			if len(current.nodes) == batchSize {
+				if !send() {
+					return
+				}
+				current = batch{start: i + 1}
+			}
+		}
+
+		if len(current.nodes) > 0 {
+			send()
+		}
+	}()
+
+	wg.Wait()
+
+	// Stop the reporter, this keeps the first error if there already was one.
+	fail(nil)
+	<-reported
+
+	return int(atomic.LoadInt64(&written)), firstErr
+}
+
+// report logs throughput every ReportInterval until stop is closed.
+func (l Loader) report(written *int64, total int, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(l.cfg.ReportInterval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+			n := atomic.LoadInt64(written)
+			l.log.Infof("wrote %d of %d records (%.1f records/s)", n, total, float64(n)/time.Since(start).Seconds())
+		case <-stop:
+			return
+		}
+	}
+}
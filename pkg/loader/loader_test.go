@@ -0,0 +1,185 @@
+package loader
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/erumble/dynamo-playground/pkg/logger/loggertest"
+	"github.com/erumble/dynamo-playground/pkg/node"
+	"github.com/erumble/dynamo-playground/pkg/node/dynamotest"
+	"github.com/pkg/errors"
+)
+
+// writeTree writes a CSV file of a root with n-1 children, and returns its
+// path.
+func writeTree(t *testing.T, n int) string {
+	t.Helper()
+
+	lines := []string{"id,parent_id,metadata", "root,,root"}
+	for i := 1; i < n; i++ {
+		lines = append(lines, fmt.Sprintf("child%03d,root,%d", i, i))
+	}
+
+	path := filepath.Join(t.TempDir(), "nodes.csv")
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	return path
+}
+
+// checkTree fails the test unless the root and its first n-1 children are
+// stored, in order.
+func checkTree(t *testing.T, c node.Client, n int) {
+	t.Helper()
+
+	root, err := c.Get("root")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if root.ChildCount != n-1 {
+		t.Errorf("root ChildCount = %d, want %d", root.ChildCount, n-1)
+	}
+
+	children, err := c.GetChildren(*root)
+	if err != nil {
+		t.Fatalf("GetChildren: %v", err)
+	}
+	if len(children) != n-1 {
+		t.Fatalf("root has %d children, want %d", len(children), n-1)
+	}
+	for i, child := range children {
+		if want := fmt.Sprintf("child%03d", i+1); child.ID != want || child.Metadata != fmt.Sprint(i+1) {
+			t.Errorf("child %d is %s %q, want %s", i, child.ID, child.Metadata, want)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	db := dynamotest.New()
+	c := node.NewClient(loggertest.Nop(), db, "nodes", "parents")
+	path := writeTree(t, 60)
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint")
+
+	stats, err := New(loggertest.Nop(), c, Config{CheckpointPath: checkpoint}).LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+
+	if stats.Records != 60 || stats.Skipped != 0 || stats.Written != 60 {
+		t.Errorf("LoadFile = %+v, want 60 records written", stats)
+	}
+	if db.Len() != 60 {
+		t.Errorf("the table holds %d items, want 60", db.Len())
+	}
+	checkTree(t, c, 60)
+
+	if got := readCheckpoint(t, checkpoint); got != "60\n" {
+		t.Errorf("checkpoint = %q, want 60", got)
+	}
+}
+
+func TestLoadFileResumes(t *testing.T) {
+	db := dynamotest.New()
+	c := node.NewClient(loggertest.Nop(), db, "nodes", "parents")
+	path := writeTree(t, 60)
+
+	// A previous load wrote the first batch, including the root.
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint")
+	if _, err := New(loggertest.Nop(), c, Config{}).LoadFile(path); err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if err := ioutil.WriteFile(checkpoint, []byte("25\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	// Only the records after the checkpoint are written again.
+	puts := 0
+	count := node.Intercept(func(operation string, input interface{}, next node.Invoker) (interface{}, error) {
+		if operation == "BatchWriteItem" {
+			puts++
+		}
+		return next(operation, input)
+	})
+	c = node.NewClient(loggertest.Nop(), db, "nodes", "parents", node.WithMiddleware(count))
+
+	stats, err := New(loggertest.Nop(), c, Config{CheckpointPath: checkpoint, Workers: 1}).LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+
+	if stats.Records != 60 || stats.Skipped != 25 || stats.Written != 35 {
+		t.Errorf("LoadFile = %+v, want 25 records skipped and 35 written", stats)
+	}
+	if puts != 2 {
+		t.Errorf("LoadFile made %d BatchWriteItem calls, want 2", puts)
+	}
+	checkTree(t, c, 60)
+
+	if got := readCheckpoint(t, checkpoint); got != "60\n" {
+		t.Errorf("checkpoint = %q, want 60", got)
+	}
+}
+
+// TestLoadFileStopsOnError checks that a failed batch stops the load, and
+// leaves the checkpoint at the last batch written before it.
+func TestLoadFileStopsOnError(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+	boom := errors.New("boom")
+	failSecond := node.Intercept(func(operation string, input interface{}, next node.Invoker) (interface{}, error) {
+		if operation == "BatchWriteItem" {
+			mu.Lock()
+			calls++
+			n := calls
+			mu.Unlock()
+
+			if n > 1 {
+				return nil, boom
+			}
+		}
+		return next(operation, input)
+	})
+
+	db := dynamotest.New()
+	c := node.NewClient(loggertest.Nop(), db, "nodes", "parents", node.WithMiddleware(failSecond))
+	path := writeTree(t, 1000)
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint")
+
+	stats, err := New(loggertest.Nop(), c, Config{CheckpointPath: checkpoint, Workers: 1}).LoadFile(path)
+	if errors.Cause(err) != boom {
+		t.Fatalf("LoadFile = %v, want %v", err, boom)
+	}
+	if !strings.Contains(err.Error(), "records 26 to 50") {
+		t.Errorf("LoadFile = %v, want it to name the records that failed", err)
+	}
+
+	if stats.Written != 25 || db.Len() != 25 {
+		t.Errorf("LoadFile wrote %d records, and the table holds %d items, want 25", stats.Written, db.Len())
+	}
+	if calls != 2 {
+		t.Errorf("LoadFile made %d BatchWriteItem calls, want it to stop after the failure", calls)
+	}
+	if got := readCheckpoint(t, checkpoint); got != "25\n" {
+		t.Errorf("checkpoint = %q, want 25", got)
+	}
+}
+
+func TestLoadFileMalformed(t *testing.T) {
+	c := node.NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents")
+
+	path := filepath.Join(t.TempDir(), "nodes.ndjson")
+	if err := ioutil.WriteFile(path, []byte("{\"id\":\"r\"}\n{\"id\":\"r\"}\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if _, err := New(loggertest.Nop(), c, Config{}).LoadFile(path); err == nil || !strings.Contains(err.Error(), "duplicate id r") {
+		t.Errorf("LoadFile = %v, want a duplicate id error", err)
+	}
+}
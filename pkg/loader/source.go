@@ -0,0 +1,114 @@
+package loader
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/erumble/dynamo-playground/pkg/node"
+	"github.com/pkg/errors"
+)
+
+// Format is the format of a file of nodes.
+type Format string
+
+const (
+	// NDJSON is newline delimited JSON, one flat node per line, as written by
+	// node.WriteNDJSON. Only the id, parent_id and metadata fields are used.
+	NDJSON Format = "ndjson"
+
+	// CSV has the columns id, parent_id and metadata, in that order. A header
+	// row is skipped if its first column is "id".
+	CSV Format = "csv"
+)
+
+// FormatFromPath guesses the Format of a file from its extension, defaulting
+// to NDJSON.
+func FormatFromPath(path string) Format {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return CSV
+	}
+
+	return NDJSON
+}
+
+// recordReader reads nodes one at a time, returning io.EOF once there are no
+// more. Only the ID, ParentID and Metadata of each node are set.
+type recordReader interface {
+	Read() (*node.Node, error)
+}
+
+func newRecordReader(r io.Reader, f Format) (recordReader, error) {
+	switch f {
+	case NDJSON:
+		return &ndjsonReader{dec: json.NewDecoder(r)}, nil
+	case CSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		return &csvReader{r: cr}, nil
+	}
+
+	return nil, errors.Errorf("unknown format %q", f)
+}
+
+type ndjsonReader struct {
+	dec  *json.Decoder
+	line int
+}
+
+func (r *ndjsonReader) Read() (*node.Node, error) {
+	r.line++
+
+	n := &node.Node{}
+	if err := r.dec.Decode(n); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, errors.Wrapf(err, "line %d", r.line)
+	}
+
+	if n.ID == "" {
+		return nil, errors.Errorf("line %d: missing id", r.line)
+	}
+
+	return &node.Node{ID: n.ID, ParentID: n.ParentID, Metadata: n.Metadata}, nil
+}
+
+type csvReader struct {
+	r    *csv.Reader
+	line int
+}
+
+func (r *csvReader) Read() (*node.Node, error) {
+	for {
+		r.line++
+
+		rec, err := r.r.Read()
+		if err != nil {
+			if err == io.EOF {
+				return nil, err
+			}
+			return nil, errors.Wrapf(err, "line %d", r.line)
+		}
+
+		if r.line == 1 && len(rec) > 0 && rec[0] == "id" {
+			continue
+		}
+
+		if len(rec) == 0 || rec[0] == "" {
+			return nil, errors.Errorf("line %d: missing id", r.line)
+		}
+
+		n := &node.Node{ID: rec[0]}
+		if len(rec) > 1 {
+			n.ParentID = rec[1]
+		}
+		if len(rec) > 2 {
+			n.Metadata = rec[2]
+		}
+
+		return n, nil
+	}
+}
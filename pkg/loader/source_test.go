@@ -0,0 +1,98 @@
+package loader
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/erumble/dynamo-playground/pkg/node"
+)
+
+// readAll reads every record in s, returning those read before the first
+// error.
+func readAll(t *testing.T, s string, f Format) ([]node.Node, error) {
+	t.Helper()
+
+	r, err := newRecordReader(strings.NewReader(s), f)
+	if err != nil {
+		t.Fatalf("newRecordReader: %v", err)
+	}
+
+	nodes := []node.Node{}
+	for {
+		n, err := r.Read()
+		if err == io.EOF {
+			return nodes, nil
+		} else if err != nil {
+			return nodes, err
+		}
+		nodes = append(nodes, *n)
+	}
+}
+
+func TestRecordReaders(t *testing.T) {
+	want := []node.Node{{ID: "r", Metadata: "root"}, {ID: "a", ParentID: "r"}, {ID: "b", ParentID: "r", Metadata: "b, with a comma"}}
+
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+	}{
+		{"ndjson", NDJSON, `{"id":"r","metadata":"root","child_ids":["a","b"]}
+{"id":"a","parent_id":"r","position":"a0"}
+{"id":"b","parent_id":"r","metadata":"b, with a comma"}
+`},
+		{"csv", CSV, "r,,root\na,r\nb,r,\"b, with a comma\"\n"},
+		{"csv with header", CSV, "id,parent_id,metadata\nr,,root\na,r,\nb,r,\"b, with a comma\"\n"},
+	}
+
+	for _, tt := range tests {
+		got, err := readAll(t, tt.input, tt.format)
+		if err != nil {
+			t.Errorf("%s: Read: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: read %+v, want %+v", tt.name, got, want)
+		}
+	}
+}
+
+func TestRecordReadersMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+		want   string
+	}{
+		{"ndjson syntax", NDJSON, "{\"id\":\"r\"}\n{\"id\":\n", "line 2"},
+		{"ndjson type", NDJSON, "{\"id\":\"r\"}\n{\"id\":3}\n", "line 2"},
+		{"ndjson missing id", NDJSON, "{\"id\":\"r\"}\n{\"parent_id\":\"r\"}\n", "line 2: missing id"},
+		{"csv quote", CSV, "r,,root\na,r,\"unterminated\n", "line 2"},
+		{"csv missing id", CSV, "id,parent_id\nr\n,r\n", "line 3: missing id"},
+	}
+
+	for _, tt := range tests {
+		got, err := readAll(t, tt.input, tt.format)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Read = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+		if len(got) != 1 || got[0].ID != "r" {
+			t.Errorf("%s: read %+v before the error, want r", tt.name, got)
+		}
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := newRecordReader(strings.NewReader(""), "xml"); err == nil {
+		t.Error("newRecordReader of an unknown format returned no error")
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	for path, want := range map[string]Format{"nodes.csv": CSV, "NODES.CSV": CSV, "nodes.ndjson": NDJSON, "nodes": NDJSON} {
+		if got := FormatFromPath(path); got != want {
+			t.Errorf("FormatFromPath(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package logger
+
+import (
+	"context"
+)
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying l, e.g. a logger With the fields
+// of the request being handled.
+func NewContext(ctx context.Context, l LeveledLogger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or fallback if there is none.
+func FromContext(ctx context.Context, fallback LeveledLogger) LeveledLogger {
+	if l, ok := ctx.Value(contextKey{}).(LeveledLogger); ok {
+		return l
+	}
+
+	return fallback
+}
@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelHandler returns an http.Handler that reports the level of l on GET,
+// and changes it on PUT, e.g. with a body of {"level":"debug"}. Loggers
+// derived from l with Indent share its level, so they change too.
+func LevelHandler(l LeveledLogger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atom := l.Level()
+		before := atom.Level()
+
+		atom.ServeHTTP(w, r)
+
+		if after := atom.Level(); after != before {
+			l.Infof("Log level changed from %s to %s", before, after)
+		}
+	})
+}
+
+// ToggleDebugOnSignal switches l to debug level when one of the given
+// signals is received, and back to the level it was at on the next one.
+// If l is already at debug level, the first signal switches it to info.
+// If no signals are given, DefaultToggleSignals are used. Call the returned
+// function to stop listening.
+func ToggleDebugOnSignal(l LeveledLogger, sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = DefaultToggleSignals
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	if len(sigs) > 0 {
+		signal.Notify(ch, sigs...)
+	}
+
+	go func() {
+		atom := l.Level()
+		previous := atom.Level()
+		if previous == zapcore.DebugLevel {
+			previous = zapcore.InfoLevel
+		}
+
+		for {
+			select {
+			case <-ch:
+				if current := atom.Level(); current != zapcore.DebugLevel {
+					previous = current
+					atom.SetLevel(zapcore.DebugLevel)
+				} else {
+					atom.SetLevel(previous)
+				}
+				l.Infof("Log level set to: %s", atom.Level())
+
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// Level returns the level shared by the logger and every logger derived from it.
+func (l leveledLogger) Level() zap.AtomicLevel {
+	return l.atom
+}
@@ -0,0 +1,56 @@
+//go:build !windows
+// +build !windows
+
+package logger_test
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/erumble/dynamo-playground/pkg/logger"
+	"github.com/erumble/dynamo-playground/pkg/logger/loggertest"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestToggleDebugOnSignal(t *testing.T) {
+	tests := []struct {
+		name  string
+		start zapcore.Level
+		want  []zapcore.Level
+	}{
+		{"from warn", zapcore.WarnLevel, []zapcore.Level{zapcore.DebugLevel, zapcore.WarnLevel, zapcore.DebugLevel}},
+		{"from debug", zapcore.DebugLevel, []zapcore.Level{zapcore.InfoLevel, zapcore.DebugLevel, zapcore.InfoLevel}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := loggertest.New()
+			l.Level().SetLevel(tt.start)
+
+			stop := logger.ToggleDebugOnSignal(l, syscall.SIGUSR2)
+			defer stop()
+
+			for i, want := range tt.want {
+				if err := syscall.Kill(os.Getpid(), syscall.SIGUSR2); err != nil {
+					t.Fatalf("Kill: %v", err)
+				}
+				if got := waitForLevel(l, want); got != want {
+					t.Fatalf("level after signal %d = %s, want %s", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+// waitForLevel waits up to a second for l to reach the given level, and
+// returns the level it ends up at.
+func waitForLevel(l logger.LeveledLogger, want zapcore.Level) zapcore.Level {
+	deadline := time.Now().Add(time.Second)
+	for l.Level().Level() != want && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	return l.Level().Level()
+}
@@ -21,11 +21,28 @@ type LeveledLogger interface {
 	Infof(template string, args ...interface{})
 	Infow(msg string, keysAndValues ...interface{})
 
+	Warn(args ...interface{})
+	Warnf(template string, args ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+
+	// Fatal logs to the error output and then calls os.Exit(1).
+	Fatal(args ...interface{})
+	Fatalf(template string, args ...interface{})
+	Fatalw(msg string, keysAndValues ...interface{})
+
 	Indent(name string) LeveledLogger
+
+	// With returns a logger that adds the given key value pairs to every entry.
+	With(keysAndValues ...interface{}) LeveledLogger
+
+	// Level returns the level of the logger, which can be changed at runtime,
+	// see LevelHandler and ToggleDebugOnSignal.
+	Level() zap.AtomicLevel
 }
 
 type leveledLogger struct {
 	*zap.SugaredLogger
+	atom zap.AtomicLevel
 }
 
 // Indent is an alias for Named, see https://godoc.org/go.uber.org/zap#Logger.Named
@@ -33,44 +50,27 @@ func (l leveledLogger) Indent(s string) LeveledLogger {
 	newLogger := l.Named(s)
 	return &leveledLogger{
 		newLogger,
+		l.atom,
+	}
+}
+
+// With is a wrapper for With, see https://godoc.org/go.uber.org/zap#SugaredLogger.With
+func (l leveledLogger) With(keysAndValues ...interface{}) LeveledLogger {
+	return &leveledLogger{
+		l.SugaredLogger.With(keysAndValues...),
+		l.atom,
 	}
 }
 
-// NewLeveledLogger instantiates a zap.SugaredLogger
+// NewLeveledLogger instantiates a zap.SugaredLogger writing to the console,
+// see New for more control over the output. An unrecognised level is ignored.
 func NewLeveledLogger(logLevel *string) LeveledLogger {
 	atom := zap.NewAtomicLevelAt(zap.InfoLevel)
 	if logLevel != nil {
 		_ = (&atom).UnmarshalText([]byte(*logLevel))
 	}
 
-	highPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl >= zapcore.ErrorLevel && lvl >= atom.Level()
-	})
-	lowPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl < zapcore.ErrorLevel && lvl >= atom.Level()
-	})
-
 	consoleEncoder := zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
 
-	consoleDebugging := zapcore.Lock(os.Stdout)
-	consoleErrors := zapcore.Lock(os.Stderr)
-
-	core := zapcore.NewTee(
-		zapcore.NewCore(consoleEncoder, consoleDebugging, lowPriority),
-		zapcore.NewCore(consoleEncoder, consoleErrors, highPriority),
-	)
-
-	logger := zap.New(core)
-	logger = logger.WithOptions(
-		zap.AddCaller(),
-		// zap.AddCallerSkip(1),
-	)
-
-	sugaredLogger := logger.Sugar()
-	defer sugaredLogger.Sync()
-
-	sugaredLogger.Info("logger constructed")
-	sugaredLogger.Infof("Log level set to: %s", atom.Level())
-
-	return &leveledLogger{sugaredLogger}
+	return build(atom, consoleEncoder, os.Stdout, os.Stderr, nil)
 }
@@ -0,0 +1,291 @@
+// Package loggertest provides logger.LeveledLogger implementations for tests:
+// Logger records every entry in memory so tests can assert on what was
+// logged, and Nop discards everything, for benchmarks.
+package loggertest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/erumble/dynamo-playground/pkg/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Entry is a single recorded log entry.
+type Entry struct {
+	Level zapcore.Level
+
+	// Name is the chain of names given to Indent, joined with dots as zap
+	// does, e.g. "nodeClient.Get".
+	Name string
+
+	Message string
+
+	// Fields holds the key value pairs given to With and the *w methods.
+	Fields map[string]interface{}
+}
+
+// FatalPanic is what Logger panics with after recording an entry with one of
+// the Fatal methods, instead of exiting. Tests can recover it.
+type FatalPanic struct {
+	Entry Entry
+}
+
+// Logger is a logger.LeveledLogger that records entries in memory. Loggers
+// derived from it with Indent or With record into the same Entries. It is
+// safe for concurrent use.
+type Logger struct {
+	rec    *recorder
+	atom   zap.AtomicLevel
+	name   string
+	fields []interface{}
+}
+
+type recorder struct {
+	mu      sync.Mutex
+	entries Entries
+}
+
+var _ logger.LeveledLogger = &Logger{}
+
+// New returns a Logger that records entries at debug level and above.
+func New() *Logger {
+	return &Logger{
+		rec:  &recorder{},
+		atom: zap.NewAtomicLevelAt(zapcore.DebugLevel),
+	}
+}
+
+// Entries returns a copy of the entries recorded so far, oldest first.
+func (l *Logger) Entries() Entries {
+	l.rec.mu.Lock()
+	defer l.rec.mu.Unlock()
+
+	return append(Entries{}, l.rec.entries...)
+}
+
+// Reset discards the entries recorded so far.
+func (l *Logger) Reset() {
+	l.rec.mu.Lock()
+	defer l.rec.mu.Unlock()
+
+	l.rec.entries = nil
+}
+
+// Debug records a message at debug level.
+func (l *Logger) Debug(args ...interface{}) { l.log(zapcore.DebugLevel, "", args, nil) }
+
+// Debugf records a formatted message at debug level.
+func (l *Logger) Debugf(template string, args ...interface{}) {
+	l.log(zapcore.DebugLevel, template, args, nil)
+}
+
+// Debugw records a message with fields at debug level.
+func (l *Logger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.log(zapcore.DebugLevel, msg, nil, keysAndValues)
+}
+
+// Info records a message at info level.
+func (l *Logger) Info(args ...interface{}) { l.log(zapcore.InfoLevel, "", args, nil) }
+
+// Infof records a formatted message at info level.
+func (l *Logger) Infof(template string, args ...interface{}) {
+	l.log(zapcore.InfoLevel, template, args, nil)
+}
+
+// Infow records a message with fields at info level.
+func (l *Logger) Infow(msg string, keysAndValues ...interface{}) {
+	l.log(zapcore.InfoLevel, msg, nil, keysAndValues)
+}
+
+// Warn records a message at warn level.
+func (l *Logger) Warn(args ...interface{}) { l.log(zapcore.WarnLevel, "", args, nil) }
+
+// Warnf records a formatted message at warn level.
+func (l *Logger) Warnf(template string, args ...interface{}) {
+	l.log(zapcore.WarnLevel, template, args, nil)
+}
+
+// Warnw records a message with fields at warn level.
+func (l *Logger) Warnw(msg string, keysAndValues ...interface{}) {
+	l.log(zapcore.WarnLevel, msg, nil, keysAndValues)
+}
+
+// Error records a message at error level.
+func (l *Logger) Error(args ...interface{}) { l.log(zapcore.ErrorLevel, "", args, nil) }
+
+// Errorf records a formatted message at error level.
+func (l *Logger) Errorf(template string, args ...interface{}) {
+	l.log(zapcore.ErrorLevel, template, args, nil)
+}
+
+// Errorw records a message with fields at error level.
+func (l *Logger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.log(zapcore.ErrorLevel, msg, nil, keysAndValues)
+}
+
+// Fatal records a message at fatal level, then panics with a FatalPanic.
+func (l *Logger) Fatal(args ...interface{}) { l.fatal("", args, nil) }
+
+// Fatalf records a formatted message at fatal level, then panics with a FatalPanic.
+func (l *Logger) Fatalf(template string, args ...interface{}) {
+	l.fatal(template, args, nil)
+}
+
+// Fatalw records a message with fields at fatal level, then panics with a FatalPanic.
+func (l *Logger) Fatalw(msg string, keysAndValues ...interface{}) {
+	l.fatal(msg, nil, keysAndValues)
+}
+
+// Indent returns a Logger whose entries have name appended to their Name.
+func (l *Logger) Indent(name string) logger.LeveledLogger {
+	derived := *l
+	if derived.name == "" {
+		derived.name = name
+	} else if name != "" {
+		derived.name += "." + name
+	}
+
+	return &derived
+}
+
+// With returns a Logger that adds the given key value pairs to every entry.
+func (l *Logger) With(keysAndValues ...interface{}) logger.LeveledLogger {
+	derived := *l
+	derived.fields = append(append([]interface{}{}, l.fields...), keysAndValues...)
+
+	return &derived
+}
+
+// Level returns the level shared by the Logger and every Logger derived
+// from it, entries below it are not recorded.
+func (l *Logger) Level() zap.AtomicLevel {
+	return l.atom
+}
+
+// fatal records an entry at fatal level and panics with it.
+func (l *Logger) fatal(template string, args []interface{}, keysAndValues []interface{}) {
+	e := l.entry(zapcore.FatalLevel, message(template, args), keysAndValues)
+	l.record(e)
+	panic(FatalPanic{Entry: e})
+}
+
+// log records an entry if the level is enabled. Like zap, the message is
+// only formatted if it is.
+func (l *Logger) log(lvl zapcore.Level, template string, args []interface{}, keysAndValues []interface{}) {
+	if l.atom.Enabled(lvl) {
+		l.record(l.entry(lvl, message(template, args), keysAndValues))
+	}
+}
+
+// message formats a message the way zap's SugaredLogger does.
+func message(template string, args []interface{}) string {
+	switch {
+	case len(args) == 0:
+		return template
+	case template == "":
+		return fmt.Sprint(args...)
+	default:
+		return fmt.Sprintf(template, args...)
+	}
+}
+
+func (l *Logger) record(e Entry) {
+	l.rec.mu.Lock()
+	defer l.rec.mu.Unlock()
+
+	l.rec.entries = append(l.rec.entries, e)
+}
+
+// entry builds an Entry, pairing up the keys and values like zap's
+// SugaredLogger: a key that is not a string is formatted, and a key without
+// a value is kept under "!BADKEY".
+func (l *Logger) entry(lvl zapcore.Level, msg string, keysAndValues []interface{}) Entry {
+	e := Entry{Level: lvl, Name: l.name, Message: msg, Fields: map[string]interface{}{}}
+
+	kvs := append(append([]interface{}{}, l.fields...), keysAndValues...)
+	for i := 0; i < len(kvs); i += 2 {
+		if i+1 == len(kvs) {
+			e.Fields["!BADKEY"] = kvs[i]
+			break
+		}
+
+		key, ok := kvs[i].(string)
+		if !ok {
+			key = fmt.Sprint(kvs[i])
+		}
+		e.Fields[key] = kvs[i+1]
+	}
+
+	return e
+}
+
+// Entries is a list of recorded entries, with helpers to narrow it down:
+//
+//	warnings := log.Entries().Level(zapcore.WarnLevel).Name("nodeClient.batchWrite")
+type Entries []Entry
+
+// Level returns the entries at the given level.
+func (es Entries) Level(lvl zapcore.Level) Entries {
+	return es.Filter(func(e Entry) bool { return e.Level == lvl })
+}
+
+// AtLeast returns the entries at the given level or above.
+func (es Entries) AtLeast(lvl zapcore.Level) Entries {
+	return es.Filter(func(e Entry) bool { return e.Level >= lvl })
+}
+
+// Name returns the entries logged with exactly the given name.
+func (es Entries) Name(name string) Entries {
+	return es.Filter(func(e Entry) bool { return e.Name == name })
+}
+
+// Under returns the entries whose name is name, or starts with name and a dot.
+func (es Entries) Under(name string) Entries {
+	return es.Filter(func(e Entry) bool {
+		return e.Name == name || strings.HasPrefix(e.Name, name+".")
+	})
+}
+
+// Contains returns the entries whose message contains s.
+func (es Entries) Contains(s string) Entries {
+	return es.Filter(func(e Entry) bool { return strings.Contains(e.Message, s) })
+}
+
+// Field returns the entries with the given field set to value.
+func (es Entries) Field(key string, value interface{}) Entries {
+	return es.Filter(func(e Entry) bool {
+		v, ok := e.Fields[key]
+		return ok && reflect.DeepEqual(v, value)
+	})
+}
+
+// Filter returns the entries for which keep returns true.
+func (es Entries) Filter(keep func(Entry) bool) Entries {
+	out := Entries{}
+	for _, e := range es {
+		if keep(e) {
+			out = append(out, e)
+		}
+	}
+
+	return out
+}
+
+// Messages returns the message of every entry.
+func (es Entries) Messages() []string {
+	out := []string{}
+	for _, e := range es {
+		out = append(out, e.Message)
+	}
+
+	return out
+}
+
+// Len returns the number of entries.
+func (es Entries) Len() int {
+	return len(es)
+}
@@ -0,0 +1,145 @@
+package loggertest
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// counter counts how many times it is formatted.
+type counter struct {
+	n int
+}
+
+func (c *counter) String() string {
+	c.n++
+	return "counter"
+}
+
+func TestLoggerRecords(t *testing.T) {
+	l := New()
+
+	l.Debug("a", 1)
+	l.Indent("nodeClient").Indent("Get").Infof("fetching %s...", "x")
+	l.With("request", "r1").Indent("nodeClient").Warnw("retrying", "attempt", 2)
+	l.Errorw("odd", "key")
+
+	want := Entries{
+		{Level: zapcore.DebugLevel, Message: "a1", Fields: map[string]interface{}{}},
+		{Level: zapcore.InfoLevel, Name: "nodeClient.Get", Message: "fetching x...", Fields: map[string]interface{}{}},
+		{Level: zapcore.WarnLevel, Name: "nodeClient", Message: "retrying", Fields: map[string]interface{}{"request": "r1", "attempt": 2}},
+		{Level: zapcore.ErrorLevel, Message: "odd", Fields: map[string]interface{}{"!BADKEY": "key"}},
+	}
+	if got := l.Entries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Entries = %+v, want %+v", got, want)
+	}
+
+	l.Reset()
+	if l.Entries().Len() != 0 {
+		t.Errorf("Entries after Reset = %+v, want none", l.Entries())
+	}
+}
+
+func TestLoggerLevel(t *testing.T) {
+	l := New()
+	derived := l.Indent("nodeClient")
+
+	// The level is shared with every derived Logger.
+	derived.Level().SetLevel(zapcore.InfoLevel)
+
+	c := &counter{}
+	l.Debugf("%s", c)
+	derived.Debug(c)
+	derived.Infof("%s", c)
+
+	if got := l.Entries().Messages(); !reflect.DeepEqual(got, []string{"counter"}) {
+		t.Errorf("Messages = %v, want only the info entry", got)
+	}
+	if c.n != 1 {
+		t.Errorf("arguments were formatted %d times, want once, for the entry that was recorded", c.n)
+	}
+}
+
+func TestLoggerFatal(t *testing.T) {
+	l := New()
+
+	defer func() {
+		p, ok := recover().(FatalPanic)
+		if !ok {
+			t.Fatalf("Fatalf did not panic with a FatalPanic")
+		}
+		if p.Entry.Level != zapcore.FatalLevel || p.Entry.Message != "giving up: 3" {
+			t.Errorf("FatalPanic holds %+v", p.Entry)
+		}
+		if l.Entries().Level(zapcore.FatalLevel).Len() != 1 {
+			t.Errorf("the fatal entry was not recorded")
+		}
+	}()
+
+	l.Fatalf("giving up: %d", 3)
+}
+
+func TestLoggerConcurrent(t *testing.T) {
+	l := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.Indent("worker").Infow("working", "worker", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := l.Entries().Name("worker").Len(); got != 800 {
+		t.Errorf("recorded %d entries, want 800", got)
+	}
+}
+
+func TestEntries(t *testing.T) {
+	es := Entries{
+		{Level: zapcore.DebugLevel, Name: "nodeClient", Message: "called..."},
+		{Level: zapcore.WarnLevel, Name: "nodeClient.batchWrite", Message: "retrying 1 unprocessed items", Fields: map[string]interface{}{"request": "r1"}},
+		{Level: zapcore.ErrorLevel, Name: "nodeClientX", Message: "failed"},
+	}
+
+	tests := []struct {
+		name string
+		got  Entries
+		want []string
+	}{
+		{"Level", es.Level(zapcore.WarnLevel), []string{"retrying 1 unprocessed items"}},
+		{"AtLeast", es.AtLeast(zapcore.WarnLevel), []string{"retrying 1 unprocessed items", "failed"}},
+		{"Name", es.Name("nodeClient"), []string{"called..."}},
+		{"Under", es.Under("nodeClient"), []string{"called...", "retrying 1 unprocessed items"}},
+		{"Contains", es.Contains("unprocessed"), []string{"retrying 1 unprocessed items"}},
+		{"Field", es.Field("request", "r1"), []string{"retrying 1 unprocessed items"}},
+		{"no match", es.Field("request", "r2"), []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.got.Messages(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNop(t *testing.T) {
+	l := Nop().Indent("nodeClient").With("request", "r1")
+
+	c := &counter{}
+	l.Errorf("%s", c)
+	l.Fatal(c)
+
+	if c.n != 0 {
+		t.Errorf("Nop formatted its arguments %d times", c.n)
+	}
+	if l.Level().Enabled(zapcore.FatalLevel) {
+		t.Error("Nop reports a level that logs fatal entries")
+	}
+}
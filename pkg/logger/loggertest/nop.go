@@ -0,0 +1,40 @@
+package loggertest
+
+import (
+	"github.com/erumble/dynamo-playground/pkg/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// nop is a logger.LeveledLogger that discards everything.
+type nop struct {
+	atom zap.AtomicLevel
+}
+
+// Nop returns a logger.LeveledLogger that discards everything, without
+// formatting it, e.g. to keep logging out of benchmarks. Its Fatal methods
+// do not exit.
+func Nop() logger.LeveledLogger {
+	// Nothing is ever logged, so report a level above every real one.
+	return nop{atom: zap.NewAtomicLevelAt(zapcore.FatalLevel + 1)}
+}
+
+func (nop) Debug(args ...interface{})                       {}
+func (nop) Debugf(template string, args ...interface{})     {}
+func (nop) Debugw(msg string, keysAndValues ...interface{}) {}
+func (nop) Info(args ...interface{})                        {}
+func (nop) Infof(template string, args ...interface{})      {}
+func (nop) Infow(msg string, keysAndValues ...interface{})  {}
+func (nop) Warn(args ...interface{})                        {}
+func (nop) Warnf(template string, args ...interface{})      {}
+func (nop) Warnw(msg string, keysAndValues ...interface{})  {}
+func (nop) Error(args ...interface{})                       {}
+func (nop) Errorf(template string, args ...interface{})     {}
+func (nop) Errorw(msg string, keysAndValues ...interface{}) {}
+func (nop) Fatal(args ...interface{})                       {}
+func (nop) Fatalf(template string, args ...interface{})     {}
+func (nop) Fatalw(msg string, keysAndValues ...interface{}) {}
+
+func (n nop) Indent(name string) logger.LeveledLogger                { return n }
+func (n nop) With(keysAndValues ...interface{}) logger.LeveledLogger { return n }
+func (n nop) Level() zap.AtomicLevel                                 { return n.atom }
@@ -0,0 +1,154 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Encoding selects how log entries are written.
+type Encoding string
+
+const (
+	// ConsoleEncoding writes human readable, tab separated entries. It is the default.
+	ConsoleEncoding Encoding = "console"
+
+	// JSONEncoding writes one JSON object per entry, for log pipelines.
+	JSONEncoding Encoding = "json"
+)
+
+// Option configures a logger built with New.
+type Option func(*config)
+
+type config struct {
+	level     string
+	encoding  Encoding
+	output    io.Writer
+	errOutput io.Writer
+	fields    []interface{}
+}
+
+// WithLevel sets the minimum level logged, e.g. "debug" or "error".
+// The default is "info".
+func WithLevel(level string) Option {
+	return func(c *config) {
+		c.level = level
+	}
+}
+
+// WithEncoding sets how log entries are written.
+func WithEncoding(e Encoding) Option {
+	return func(c *config) {
+		c.encoding = e
+	}
+}
+
+// WithOutput sets where entries below error level are written, the default
+// is os.Stdout. Use a RotatingFile to write to a file that is rotated.
+func WithOutput(w io.Writer) Option {
+	return func(c *config) {
+		c.output = w
+	}
+}
+
+// WithErrorOutput sets where error level entries are written. The default
+// is os.Stderr, unless WithOutput is given, in which case errors are written
+// to the same output as everything else.
+func WithErrorOutput(w io.Writer) Option {
+	return func(c *config) {
+		c.errOutput = w
+	}
+}
+
+// WithFields adds the given key value pairs, e.g. "service", "nodes", to
+// every entry logged.
+func WithFields(keysAndValues ...interface{}) Option {
+	return func(c *config) {
+		c.fields = append(c.fields, keysAndValues...)
+	}
+}
+
+// New builds a LeveledLogger from the given Options. It returns an error if
+// the level or encoding is not recognised.
+func New(opts ...Option) (LeveledLogger, error) {
+	c := config{level: "info", encoding: ConsoleEncoding}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	atom := zap.NewAtomicLevel()
+	if err := (&atom).UnmarshalText([]byte(c.level)); err != nil {
+		return nil, errors.Wrap(err, "logger.New")
+	}
+
+	var encoder zapcore.Encoder
+	switch c.encoding {
+	case ConsoleEncoding:
+		encoder = zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+	case JSONEncoding:
+		cfg := zap.NewProductionEncoderConfig()
+		cfg.EncodeTime = zapcore.ISO8601TimeEncoder
+		encoder = zapcore.NewJSONEncoder(cfg)
+	default:
+		return nil, errors.Errorf("logger.New: unknown encoding %q", c.encoding)
+	}
+
+	var output, errOutput io.Writer = os.Stdout, os.Stderr
+	if c.output != nil {
+		output, errOutput = c.output, c.output
+	}
+	if c.errOutput != nil {
+		errOutput = c.errOutput
+	}
+
+	return build(atom, encoder, output, errOutput, c.fields), nil
+}
+
+// build creates the logger, writing entries at error level and above to
+// errOutput, and the rest to output.
+func build(atom zap.AtomicLevel, encoder zapcore.Encoder, output, errOutput io.Writer, fields []interface{}) LeveledLogger {
+	// Both cores must share the lock of a writer they share, or entries
+	// written at the same time interleave.
+	lockedOutput := zapcore.Lock(zapcore.AddSync(output))
+	lockedErrOutput := lockedOutput
+	if !sameWriter(output, errOutput) {
+		lockedErrOutput = zapcore.Lock(zapcore.AddSync(errOutput))
+	}
+
+	highPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+		return lvl >= zapcore.ErrorLevel && lvl >= atom.Level()
+	})
+	lowPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+		return lvl < zapcore.ErrorLevel && lvl >= atom.Level()
+	})
+
+	core := zapcore.NewTee(
+		zapcore.NewCore(encoder, lockedOutput, lowPriority),
+		zapcore.NewCore(encoder, lockedErrOutput, highPriority),
+	)
+
+	logger := zap.New(core)
+	logger = logger.WithOptions(
+		zap.AddCaller(),
+		// zap.AddCallerSkip(1),
+	)
+
+	sugaredLogger := logger.Sugar().With(fields...)
+	defer sugaredLogger.Sync()
+
+	sugaredLogger.Info("logger constructed")
+	sugaredLogger.Infof("Log level set to: %s", atom.Level())
+
+	return &leveledLogger{sugaredLogger, atom}
+}
+
+// sameWriter returns true if a and b are the same writer. Writers that can't
+// be compared, e.g. structs holding a slice, are never the same.
+func sameWriter(a, b io.Writer) bool {
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t != nil && t.Comparable() && a == b
+}
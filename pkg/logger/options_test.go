@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewSharedOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l, err := New(WithOutput(buf), WithEncoding(JSONEncoding))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// Info and error entries go through different cores, which must not
+	// write to the buffer at the same time.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if i%2 == 0 {
+					l.Infof("info %d %d", i, j)
+				} else {
+					l.Errorf("error %d %d", i, j)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if want := 2 + 8*100; len(lines) != want {
+		t.Errorf("logged %d lines, want %d", len(lines), want)
+	}
+	for _, line := range lines {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("entries were interleaved, %q is not JSON: %v", line, err)
+		}
+	}
+}
+
+func TestSameWriter(t *testing.T) {
+	a, b := &bytes.Buffer{}, &bytes.Buffer{}
+
+	tests := []struct {
+		name string
+		a, b io.Writer
+		want bool
+	}{
+		{"same", a, a, true},
+		{"different", a, b, false},
+		{"not comparable", sliceWriter{}, sliceWriter{}, false},
+	}
+
+	for _, tt := range tests {
+		if got := sameWriter(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: sameWriter = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+// sliceWriter is a writer that can't be compared with ==.
+type sliceWriter struct {
+	lines []string
+}
+
+func (sliceWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
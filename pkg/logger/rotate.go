@@ -0,0 +1,211 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// backupTimeFormat is appended to the name of a rotated file, it sorts in
+// the order the files were rotated.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// Rotation configures when a RotatingFile is rotated. A limit that is not
+// positive is not enforced.
+type Rotation struct {
+	// MaxSize is the size in bytes the file can grow to before it is rotated.
+	MaxSize int64
+
+	// Interval is how long the file is written to before it is rotated,
+	// counted from when it was opened.
+	Interval time.Duration
+
+	// MaxBackups is the number of rotated files kept, the oldest are removed.
+	MaxBackups int
+}
+
+// RotatingFile is a log file that is renamed with a timestamp suffix, and
+// replaced by an empty file, when it reaches the size or age set by its
+// Rotation. It is safe for concurrent use, pass it to WithOutput or
+// WithErrorOutput.
+type RotatingFile struct {
+	path     string
+	rotation Rotation
+
+	mu     sync.Mutex
+	file   *os.File
+	closed bool
+	size   int64
+	opened time.Time
+}
+
+// NewRotatingFile opens the file at path for appending, creating it if it
+// does not exist.
+func NewRotatingFile(path string, r Rotation) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, rotation: r}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Write writes p to the file, rotating it first if p would take it past
+// MaxSize, or it is older than Interval. An entry is never split across files.
+// If the file can't be rotated, p is still written to it, the error is
+// returned, and rotation is tried again later.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, errors.Errorf("log file %s is closed", f.path)
+	}
+
+	// A previous rotation may have failed to open the new file.
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	var rotateErr error
+	if f.due(int64(len(p))) {
+		rotateErr = f.rotate()
+		if f.file == nil {
+			return 0, rotateErr
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+
+	return n, err
+}
+
+// Sync flushes the file to disk.
+func (f *RotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	return f.file.Sync()
+}
+
+// Close closes the file, any later Write fails.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+
+	return err
+}
+
+// due returns true if the file has to be rotated before writing n bytes.
+// An empty file is never rotated.
+func (f *RotatingFile) due(n int64) bool {
+	if f.size == 0 {
+		return false
+	}
+
+	if f.rotation.MaxSize > 0 && f.size+n > f.rotation.MaxSize {
+		return true
+	}
+
+	return f.rotation.Interval > 0 && time.Since(f.opened) >= f.rotation.Interval
+}
+
+// open opens the file at the path, picking up the size of what is in it.
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrap(err, "error opening log file")
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Wrap(err, "error opening log file")
+	}
+
+	f.file, f.size, f.opened = file, info.Size(), time.Now()
+
+	return nil
+}
+
+// rotate renames the current file, opens a new one, and removes backups
+// beyond MaxBackups. If the file can't be renamed, it is opened again, so
+// entries keep being written to it.
+func (f *RotatingFile) rotate() error {
+	err := f.file.Close()
+	f.file = nil
+	if err != nil {
+		return errors.Wrap(err, "error rotating log file")
+	}
+
+	// Never overwrite a backup, even when rotating several times a
+	// millisecond. The counter is padded so backups still sort in order.
+	stamp := time.Now().Format(backupTimeFormat)
+	backup := f.path + "." + stamp
+	for i := 1; exists(backup); i++ {
+		backup = fmt.Sprintf("%s.%s-%03d", f.path, stamp, i)
+	}
+	if err := os.Rename(f.path, backup); err != nil {
+		if oerr := f.open(); oerr != nil {
+			return errors.Wrapf(oerr, "error reopening log file after failing to rotate it: %v", err)
+		}
+		return errors.Wrap(err, "error rotating log file")
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	return f.prune()
+}
+
+// prune removes the oldest backups, keeping MaxBackups of them.
+func (f *RotatingFile) prune() error {
+	if f.rotation.MaxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := filepath.Glob(f.path + ".*-*-*T*")
+	if err != nil {
+		return errors.Wrap(err, "error removing old log files")
+	}
+	sort.Strings(backups)
+
+	for len(backups) > f.rotation.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return errors.Wrap(err, "error removing old log files")
+		}
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// exists returns true if there is a file at path.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestRotatingFileBackupsSortInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	// Every entry rotates the file, many of them within a millisecond.
+	f, err := NewRotatingFile(path, Rotation{MaxSize: 1})
+	if err != nil {
+		t.Fatalf("NewRotatingFile: %v", err)
+	}
+	defer f.Close()
+
+	for i := 0; i < 25; i++ {
+		if _, err := fmt.Fprintf(f, "entry %02d\n", i); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	backups, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	sort.Strings(backups)
+
+	if len(backups) != 24 {
+		t.Fatalf("found %d backups, want 24", len(backups))
+	}
+	for i, backup := range backups {
+		b, err := ioutil.ReadFile(backup)
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		if want := fmt.Sprintf("entry %02d\n", i); string(b) != want {
+			t.Errorf("backup %d %s holds %q, want %q", i, filepath.Base(backup), b, want)
+		}
+	}
+}
+
+func TestRotatingFileRenameFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	f, err := NewRotatingFile(path, Rotation{MaxSize: 1})
+	if err != nil {
+		t.Fatalf("NewRotatingFile: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write([]byte("a\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	// The file is removed from under it, so it can't be renamed.
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	n, err := f.Write([]byte("b\n"))
+	if err == nil {
+		t.Error("Write when the file can't be rotated returned no error")
+	}
+	if n != 2 {
+		t.Errorf("Write when the file can't be rotated wrote %d bytes, want 2", n)
+	}
+
+	if _, err := f.Write([]byte("c\n")); err != nil {
+		t.Fatalf("Write after a failed rotation: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(b) != "c\n" {
+		t.Errorf("log file holds %q, want the entry written after rotating again", b)
+	}
+}
+
+func TestRotatingFileClosed(t *testing.T) {
+	f, err := NewRotatingFile(filepath.Join(t.TempDir(), "app.log"), Rotation{})
+	if err != nil {
+		t.Fatalf("NewRotatingFile: %v", err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if _, err := f.Write([]byte("a\n")); err == nil {
+		t.Error("Write after Close returned no error")
+	}
+}
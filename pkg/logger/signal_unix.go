@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package logger
+
+import (
+	"os"
+	"syscall"
+)
+
+// DefaultToggleSignals are the signals ToggleDebugOnSignal listens for when
+// none are given.
+var DefaultToggleSignals = []os.Signal{syscall.SIGUSR1}
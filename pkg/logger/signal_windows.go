@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package logger
+
+import (
+	"os"
+)
+
+// DefaultToggleSignals are the signals ToggleDebugOnSignal listens for when
+// none are given. Windows has no SIGUSR1, so there are none.
+var DefaultToggleSignals = []os.Signal{}
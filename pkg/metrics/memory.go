@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// InMemory is a Recorder that keeps everything it is given in memory so it
+// can be inspected, it is primarily intended for tests.
+type InMemory struct {
+	mu sync.Mutex
+
+	calls     map[string]int
+	errors    map[string]int
+	retries   map[string]int
+	latencies map[string][]time.Duration
+	capacity  map[capacityKey]float64
+}
+
+type capacityKey struct {
+	table string
+	index string
+}
+
+// NewInMemory creates an empty InMemory recorder.
+func NewInMemory() *InMemory {
+	m := &InMemory{}
+	m.Reset()
+	return m
+}
+
+// ObserveCall implements Recorder.
+func (m *InMemory) ObserveCall(operation string, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls[operation]++
+	m.latencies[operation] = append(m.latencies[operation], duration)
+	if err != nil {
+		m.errors[operation]++
+	}
+}
+
+// ObserveRetry implements Recorder.
+func (m *InMemory) ObserveRetry(operation string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.retries[operation]++
+}
+
+// ObserveConsumedCapacity implements Recorder.
+func (m *InMemory) ObserveConsumedCapacity(operation, table, index string, units float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.capacity[capacityKey{table: table, index: index}] += units
+}
+
+// Calls returns the number of calls made to the named operation.
+func (m *InMemory) Calls(operation string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.calls[operation]
+}
+
+// Errors returns the number of calls to the named operation that failed.
+func (m *InMemory) Errors(operation string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.errors[operation]
+}
+
+// Retries returns the number of times the named operation was resubmitted.
+func (m *InMemory) Retries(operation string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.retries[operation]
+}
+
+// Latencies returns the duration of every call made to the named operation,
+// in the order they were observed.
+func (m *InMemory) Latencies(operation string) []time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]time.Duration{}, m.latencies[operation]...)
+}
+
+// ConsumedCapacity returns the total capacity units consumed against the given
+// table, or against one of its indexes when index is non-empty.
+func (m *InMemory) ConsumedCapacity(table, index string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.capacity[capacityKey{table: table, index: index}]
+}
+
+// Reset discards everything recorded so far.
+func (m *InMemory) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls = map[string]int{}
+	m.errors = map[string]int{}
+	m.retries = map[string]int{}
+	m.latencies = map[string][]time.Duration{}
+	m.capacity = map[capacityKey]float64{}
+}
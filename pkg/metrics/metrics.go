@@ -0,0 +1,36 @@
+// Package metrics provides the instrumentation used to measure calls made
+// against DynamoDB, including the capacity units each call consumed.
+package metrics
+
+import (
+	"time"
+)
+
+// Recorder represents the sink that instrumented DynamoDB calls are reported to.
+// Implementations must be safe for concurrent use.
+type Recorder interface {
+	// ObserveCall records a single call to the named DynamoDB operation, how
+	// long it took, and the error it returned, if any.
+	ObserveCall(operation string, duration time.Duration, err error)
+
+	// ObserveRetry records that the named operation had to be resubmitted,
+	// e.g. because DynamoDB returned unprocessed items.
+	ObserveRetry(operation string)
+
+	// ObserveConsumedCapacity records the capacity units consumed by the named
+	// operation against a table, or against one of its indexes when index is
+	// non-empty.
+	ObserveConsumedCapacity(operation, table, index string, units float64)
+}
+
+// Nop is a Recorder that discards everything it is given.
+type Nop struct{}
+
+// ObserveCall implements Recorder.
+func (Nop) ObserveCall(string, time.Duration, error) {}
+
+// ObserveRetry implements Recorder.
+func (Nop) ObserveRetry(string) {}
+
+// ObserveConsumedCapacity implements Recorder.
+func (Nop) ObserveConsumedCapacity(string, string, string, float64) {}
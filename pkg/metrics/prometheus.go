@@ -0,0 +1,212 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the latency histogram
+// buckets used when none are given to NewPrometheus.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Prometheus is a Recorder that renders what it is given in the Prometheus
+// text exposition format. It implements http.Handler so it can be mounted
+// directly as a scrape endpoint.
+type Prometheus struct {
+	namespace string
+	buckets   []float64
+
+	mu       sync.Mutex
+	calls    map[string]float64
+	errors   map[string]float64
+	retries  map[string]float64
+	latency  map[string]*histogram
+	capacity map[capacityLabels]float64
+}
+
+type capacityLabels struct {
+	operation string
+	table     string
+	index     string
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewPrometheus creates a Prometheus recorder, every metric name is prefixed
+// with the given namespace. If buckets is empty DefaultBuckets is used.
+func NewPrometheus(namespace string, buckets []float64) *Prometheus {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	b := append([]float64{}, buckets...)
+	sort.Float64s(b)
+
+	return &Prometheus{
+		namespace: namespace,
+		buckets:   b,
+		calls:     map[string]float64{},
+		errors:    map[string]float64{},
+		retries:   map[string]float64{},
+		latency:   map[string]*histogram{},
+		capacity:  map[capacityLabels]float64{},
+	}
+}
+
+// ObserveCall implements Recorder.
+func (p *Prometheus) ObserveCall(operation string, duration time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls[operation]++
+	if err != nil {
+		p.errors[operation]++
+	}
+
+	h, ok := p.latency[operation]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(p.buckets))}
+		p.latency[operation] = h
+	}
+
+	s := duration.Seconds()
+	for i, upper := range p.buckets {
+		if s <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += s
+}
+
+// ObserveRetry implements Recorder.
+func (p *Prometheus) ObserveRetry(operation string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.retries[operation]++
+}
+
+// ObserveConsumedCapacity implements Recorder.
+func (p *Prometheus) ObserveConsumedCapacity(operation, table, index string, units float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.capacity[capacityLabels{operation: operation, table: table, index: index}] += units
+}
+
+// ServeHTTP implements http.Handler.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = p.WriteTo(w)
+}
+
+// WriteTo writes every metric to w in the Prometheus text exposition format.
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	buf := &bytes.Buffer{}
+
+	p.writeCounter(buf, "dynamodb_calls_total", "Total number of calls made to DynamoDB.", p.calls)
+	p.writeCounter(buf, "dynamodb_errors_total", "Total number of calls to DynamoDB that returned an error.", p.errors)
+	p.writeCounter(buf, "dynamodb_retries_total", "Total number of calls to DynamoDB that were resubmitted.", p.retries)
+	p.writeLatency(buf)
+	p.writeCapacity(buf)
+
+	return buf.WriteTo(w)
+}
+
+func (p *Prometheus) name(metric string) string {
+	if p.namespace == "" {
+		return metric
+	}
+
+	return p.namespace + "_" + metric
+}
+
+func (p *Prometheus) writeCounter(buf *bytes.Buffer, metric, help string, values map[string]float64) {
+	name := p.name(metric)
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+
+	for _, op := range sortedKeys(values) {
+		fmt.Fprintf(buf, "%s{operation=\"%s\"} %s\n", name, escape(op), formatFloat(values[op]))
+	}
+}
+
+func (p *Prometheus) writeLatency(buf *bytes.Buffer) {
+	name := p.name("dynamodb_call_duration_seconds")
+	fmt.Fprintf(buf, "# HELP %s Latency of calls made to DynamoDB.\n# TYPE %s histogram\n", name, name)
+
+	ops := []string{}
+	for op := range p.latency {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	for _, op := range ops {
+		h := p.latency[op]
+		label := escape(op)
+
+		for i, upper := range p.buckets {
+			fmt.Fprintf(buf, "%s_bucket{operation=\"%s\",le=\"%s\"} %d\n", name, label, formatFloat(upper), h.counts[i])
+		}
+		fmt.Fprintf(buf, "%s_bucket{operation=\"%s\",le=\"+Inf\"} %d\n", name, label, h.count)
+		fmt.Fprintf(buf, "%s_sum{operation=\"%s\"} %s\n", name, label, formatFloat(h.sum))
+		fmt.Fprintf(buf, "%s_count{operation=\"%s\"} %d\n", name, label, h.count)
+	}
+}
+
+func (p *Prometheus) writeCapacity(buf *bytes.Buffer) {
+	name := p.name("dynamodb_consumed_capacity_units_total")
+	fmt.Fprintf(buf, "# HELP %s Total capacity units consumed in DynamoDB.\n# TYPE %s counter\n", name, name)
+
+	keys := []capacityLabels{}
+	for k := range p.capacity {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].operation != keys[j].operation {
+			return keys[i].operation < keys[j].operation
+		}
+		if keys[i].table != keys[j].table {
+			return keys[i].table < keys[j].table
+		}
+		return keys[i].index < keys[j].index
+	})
+
+	for _, k := range keys {
+		fmt.Fprintf(buf, "%s{operation=\"%s\",table=\"%s\",index=\"%s\"} %s\n",
+			name, escape(k.operation), escape(k.table), escape(k.index), formatFloat(p.capacity[k]))
+	}
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// escape escapes a label value as required by the text exposition format.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,252 @@
+package node
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultAncestorCacheTTL is how long ancestor chains are cached by default.
+const DefaultAncestorCacheTTL = time.Minute
+
+// WithAncestorCacheTTL sets how long the ancestor chains used by
+// LowestCommonAncestor, IsAncestor and PathBetween are cached. A TTL that is
+// not positive disables the cache. Writes made through the Client evict the
+// chains they affect, but writes made elsewhere are only seen once the TTL
+// has passed.
+func WithAncestorCacheTTL(ttl time.Duration) ClientOption {
+	return func(c *Client) {
+		c.ancestors = newAncestorCache(ttl)
+	}
+}
+
+// Ancestors returns the IDs of the ancestors of the Node with the given ID,
+// starting with its parent and ending with the root of its tree.
+// It returns ErrNotFound if the Node does not exist.
+func (c Client) Ancestors(id string) ([]string, error) {
+	lineage, err := c.lineage(id)
+	if err != nil {
+		return nil, errors.Wrap(err, "Client.Ancestors")
+	}
+
+	return lineage[1:], nil
+}
+
+// IsAncestor returns true if the Node with ID a is an ancestor of the Node
+// with ID b. A Node is not its own ancestor.
+func (c Client) IsAncestor(a, b string) (bool, error) {
+	lineage, err := c.lineage(b)
+	if err != nil {
+		return false, errors.Wrap(err, "Client.IsAncestor")
+	}
+
+	return indexOf(lineage[1:], a) >= 0, nil
+}
+
+// LowestCommonAncestor returns the ID of the deepest Node that both Nodes
+// descend from, where a Node counts as descending from itself, i.e. if a is
+// an ancestor of b, a is returned. It returns false if the Nodes are in
+// different trees.
+func (c Client) LowestCommonAncestor(a, b string) (string, bool, error) {
+	lineageA, lineageB, err := c.lineages(a, b)
+	if err != nil {
+		return "", false, errors.Wrap(err, "Client.LowestCommonAncestor")
+	}
+
+	i, _ := commonAncestor(lineageA, lineageB)
+	if i < 0 {
+		return "", false, nil
+	}
+
+	return lineageA[i], true, nil
+}
+
+// PathBetween returns the IDs of the Nodes on the path from a to b,
+// inclusive, going up from a to their lowest common ancestor and then down to
+// b. The distance between the Nodes is one less than the length of the path.
+// It returns an error if the Nodes are in different trees.
+func (c Client) PathBetween(a, b string) ([]string, error) {
+	lineageA, lineageB, err := c.lineages(a, b)
+	if err != nil {
+		return nil, errors.Wrap(err, "Client.PathBetween")
+	}
+
+	i, j := commonAncestor(lineageA, lineageB)
+	if i < 0 {
+		return nil, errors.Errorf("Client.PathBetween: %s and %s are not in the same tree", a, b)
+	}
+
+	path := append([]string{}, lineageA[:i+1]...)
+	for k := j - 1; k >= 0; k-- {
+		path = append(path, lineageB[k])
+	}
+
+	return path, nil
+}
+
+func (c Client) lineages(a, b string) ([]string, []string, error) {
+	lineageA, err := c.lineage(a)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lineageB, err := c.lineage(b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return lineageA, lineageB, nil
+}
+
+// lineage returns the ID of the Node with the given ID followed by the IDs of
+// all of its ancestors, up to the root. Cached chains are reused, so walking
+// up stops at the first Node whose lineage is already known.
+func (c Client) lineage(id string) ([]string, error) {
+	log := c.log.Indent("lineage")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	if id == "" {
+		return nil, errors.Wrap(ErrNotFound, "empty node ID")
+	}
+
+	lineage := []string{}
+	var limit time.Time
+	fetched := 0
+	for next := id; next != ""; {
+		if cached, expires, ok := c.ancestors.get(next); ok {
+			lineage = append(lineage, cached...)
+			limit = expires
+			break
+		}
+
+		// A corrupt table could contain a cycle, which would never reach a root.
+		if indexOf(lineage, next) >= 0 {
+			return nil, errors.Errorf("cycle detected at node %s", next)
+		}
+
+		log.Debugf("fetching %s...", next)
+		n, err := c.getExisting(next, StructureOnly())
+		if err != nil {
+			return nil, err
+		}
+
+		lineage = append(lineage, n.ID)
+		fetched++
+		next = n.ParentID
+	}
+
+	c.ancestors.put(lineage, fetched, limit)
+
+	return lineage, nil
+}
+
+// commonAncestor returns the index into each lineage of their lowest common
+// ancestor, or -1 for both if there is none.
+func commonAncestor(a, b []string) (int, int) {
+	i, j := len(a)-1, len(b)-1
+	if i < 0 || j < 0 || a[i] != b[j] {
+		return -1, -1
+	}
+
+	// Both lineages end at the same root, walk down until they diverge.
+	for i > 0 && j > 0 && a[i-1] == b[j-1] {
+		i--
+		j--
+	}
+
+	return i, j
+}
+
+// ancestorCache caches the lineage of Nodes. A nil *ancestorCache caches
+// nothing. It is safe for concurrent use, and is shared by copies of a Client.
+type ancestorCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]ancestorEntry
+}
+
+type ancestorEntry struct {
+	lineage []string
+	expires time.Time
+}
+
+func newAncestorCache(ttl time.Duration) *ancestorCache {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return &ancestorCache{
+		ttl:     ttl,
+		entries: map[string]ancestorEntry{},
+	}
+}
+
+// get returns the cached lineage of the Node with the given ID, and when it
+// expires.
+func (a *ancestorCache) get(id string) ([]string, time.Time, bool) {
+	if a == nil {
+		return nil, time.Time{}, false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e, ok := a.entries[id]
+	if !ok || time.Now().After(e.expires) {
+		delete(a.entries, id)
+		return nil, time.Time{}, false
+	}
+
+	// Callers own what they get, so they can't change the cached lineage.
+	return append([]string{}, e.lineage...), e.expires, true
+}
+
+// put caches the lineage of the first n Nodes of the given lineage, the rest
+// of which came from the cache, expiring at limit. The new entries expire
+// after the TTL, or at limit if that is sooner, so none outlives the cached
+// entries it was built from. A zero limit means nothing came from the cache.
+func (a *ancestorCache) put(lineage []string, n int, limit time.Time) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	lineage = append([]string{}, lineage...)
+	expires := time.Now().Add(a.ttl)
+	if !limit.IsZero() && limit.Before(expires) {
+		expires = limit
+	}
+	for i := 0; i < n && i < len(lineage); i++ {
+		a.entries[lineage[i]] = ancestorEntry{lineage: lineage[i:], expires: expires}
+	}
+}
+
+// evict drops every cached lineage that includes any of the given IDs, as
+// writing those Nodes may have moved them.
+func (a *ancestorCache) evict(ids ...string) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	evicted := map[string]bool{}
+	for _, id := range ids {
+		evicted[id] = true
+	}
+
+	for key, e := range a.entries {
+		for _, id := range e.lineage {
+			if evicted[id] {
+				delete(a.entries, key)
+				break
+			}
+		}
+	}
+}
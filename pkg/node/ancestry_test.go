@@ -0,0 +1,267 @@
+package node
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/erumble/dynamo-playground/pkg/logger/loggertest"
+	"github.com/erumble/dynamo-playground/pkg/node/dynamotest"
+	"github.com/pkg/errors"
+)
+
+func TestAncestorCacheCopies(t *testing.T) {
+	a := newAncestorCache(time.Minute)
+
+	lineage := []string{"c", "b", "a"}
+	a.put(lineage, len(lineage), time.Time{})
+	lineage[1] = "changed"
+
+	got, _, ok := a.get("c")
+	if !ok {
+		t.Fatal("get(c) found nothing")
+	}
+	got[2] = "changed"
+
+	for _, id := range []string{"c", "b"} {
+		got, _, _ := a.get(id)
+		if want := map[string]string{"c": "[c b a]", "b": "[b a]"}[id]; fmt.Sprint(got) != want {
+			t.Errorf("get(%s) = %v, want %s", id, got, want)
+		}
+	}
+}
+
+// countReads returns a middleware that counts the calls to GetItem.
+func countReads(n *int) Middleware {
+	return Intercept(func(operation string, input interface{}, next Invoker) (interface{}, error) {
+		if operation == "GetItem" {
+			*n++
+		}
+		return next(operation, input)
+	})
+}
+
+func TestAncestors(t *testing.T) {
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents")
+	nodes := storeTree(t, c)
+	root, a, b, a1 := nodes[0].ID, nodes[1].ID, nodes[2].ID, nodes[3].ID
+
+	got, err := c.Ancestors(a1)
+	if err != nil {
+		t.Fatalf("Ancestors: %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{a, root}) {
+		t.Errorf("Ancestors(a1) = %v, want [a root]", got)
+	}
+
+	if got, err := c.Ancestors(root); err != nil || len(got) != 0 {
+		t.Errorf("Ancestors(root) = %v, %v, want none", got, err)
+	}
+
+	for _, id := range []string{"", "missing"} {
+		if _, err := c.Ancestors(id); errors.Cause(err) != ErrNotFound {
+			t.Errorf("Ancestors(%q) = %v, want ErrNotFound", id, err)
+		}
+		if _, err := c.IsAncestor(root, id); errors.Cause(err) != ErrNotFound {
+			t.Errorf("IsAncestor(root, %q) = %v, want ErrNotFound", id, err)
+		}
+	}
+
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{root, a1, true},
+		{a, a1, true},
+		{b, a1, false},
+		{a1, a, false},
+		{a, a, false},
+	}
+
+	for _, tt := range tests {
+		if got, err := c.IsAncestor(tt.a, tt.b); err != nil || got != tt.want {
+			t.Errorf("IsAncestor(%s, %s) = %v, %v, want %v", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+}
+
+func TestLowestCommonAncestor(t *testing.T) {
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents")
+	nodes := storeTree(t, c)
+	root, a, b, a1, a2 := nodes[0].ID, nodes[1].ID, nodes[2].ID, nodes[3].ID, nodes[4].ID
+
+	if err := c.Put(Node{ID: "other"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		a, b  string
+		want  string
+		found bool
+	}{
+		{"siblings", a1, a2, a, true},
+		{"cousins", a1, b, root, true},
+		{"ancestor", a, a2, a, true},
+		{"descendant", a2, root, root, true},
+		{"same node", a1, a1, a1, true},
+		{"different trees", a1, "other", "", false},
+	}
+
+	for _, tt := range tests {
+		got, found, err := c.LowestCommonAncestor(tt.a, tt.b)
+		if err != nil || got != tt.want || found != tt.found {
+			t.Errorf("%s: LowestCommonAncestor = %q, %v, %v, want %q, %v", tt.name, got, found, err, tt.want, tt.found)
+		}
+	}
+
+	if _, _, err := c.LowestCommonAncestor(a1, "missing"); errors.Cause(err) != ErrNotFound {
+		t.Errorf("LowestCommonAncestor with a missing node = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPathBetween(t *testing.T) {
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents")
+	nodes := storeTree(t, c)
+	root, a, b, a1, a2 := nodes[0].ID, nodes[1].ID, nodes[2].ID, nodes[3].ID, nodes[4].ID
+
+	if err := c.Put(Node{ID: "other"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{"siblings", a1, a2, []string{a1, a, a2}},
+		{"cousins", a1, b, []string{a1, a, root, b}},
+		{"down", root, a2, []string{root, a, a2}},
+		{"up", a2, root, []string{a2, a, root}},
+		{"same node", a, a, []string{a}},
+	}
+
+	for _, tt := range tests {
+		got, err := c.PathBetween(tt.a, tt.b)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: PathBetween = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+
+	if _, err := c.PathBetween(a1, "other"); err == nil {
+		t.Error("PathBetween nodes in different trees returned no error")
+	}
+}
+
+func TestAncestorCacheTTL(t *testing.T) {
+	reads := 0
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents", WithMiddleware(countReads(&reads)))
+	nodes := storeTree(t, c)
+	a1 := nodes[3].ID
+
+	for i, want := range []int{3, 0} {
+		reads = 0
+		if _, err := c.Ancestors(a1); err != nil {
+			t.Fatalf("Ancestors: %v", err)
+		}
+		if reads != want {
+			t.Errorf("call %d read %d nodes, want %d", i, reads, want)
+		}
+	}
+
+	// Once the entries expire, the lineage is read again.
+	for id, e := range c.ancestors.entries {
+		e.expires = time.Now().Add(-time.Second)
+		c.ancestors.entries[id] = e
+	}
+
+	reads = 0
+	if _, err := c.Ancestors(a1); err != nil {
+		t.Fatalf("Ancestors: %v", err)
+	}
+	if reads != 3 {
+		t.Errorf("read %d nodes after the cache expired, want 3", reads)
+	}
+
+	// Without a TTL nothing is cached.
+	reads = 0
+	uncached := NewClient(loggertest.Nop(), c.dataStore, "nodes", "parents", WithAncestorCacheTTL(0))
+	for i := 0; i < 2; i++ {
+		if _, err := uncached.Ancestors(a1); err != nil {
+			t.Fatalf("Ancestors: %v", err)
+		}
+	}
+	if reads != 6 {
+		t.Errorf("read %d nodes without a cache, want 6", reads)
+	}
+}
+
+// TestAncestorCacheKeepsExpiry checks that a lineage built on a cached one
+// expires with it, rather than keeping it alive.
+func TestAncestorCacheKeepsExpiry(t *testing.T) {
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents")
+	nodes := storeTree(t, c)
+	root, a, a1 := nodes[0].ID, nodes[1].ID, nodes[3].ID
+
+	if _, err := c.Ancestors(a); err != nil {
+		t.Fatalf("Ancestors: %v", err)
+	}
+
+	soon := time.Now().Add(time.Second)
+	for _, id := range []string{root, a} {
+		e := c.ancestors.entries[id]
+		e.expires = soon
+		c.ancestors.entries[id] = e
+	}
+
+	if _, err := c.Ancestors(a1); err != nil {
+		t.Fatalf("Ancestors: %v", err)
+	}
+
+	for _, id := range []string{root, a, a1} {
+		if e := c.ancestors.entries[id]; !e.expires.Equal(soon) {
+			t.Errorf("the lineage of %s expires at %v, want %v", id, e.expires, soon)
+		}
+	}
+}
+
+func TestAncestorCacheEviction(t *testing.T) {
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents")
+	nodes := storeTree(t, c)
+	root, a, b, a1 := nodes[0], nodes[1], nodes[2], nodes[3]
+
+	if got, _ := c.Ancestors(a1.ID); !reflect.DeepEqual(got, []string{a.ID, root.ID}) {
+		t.Fatalf("Ancestors(a1) = %v, want [a root]", got)
+	}
+
+	// Moving a under b changes the lineage of a1 too.
+	cs, err := a.Reparent(root, b)
+	if err != nil {
+		t.Fatalf("Reparent: %v", err)
+	}
+	if err := c.ApplyChanges(cs); err != nil {
+		t.Fatalf("ApplyChanges: %v", err)
+	}
+
+	if got, _ := c.Ancestors(a1.ID); !reflect.DeepEqual(got, []string{a.ID, b.ID, root.ID}) {
+		t.Errorf("Ancestors(a1) after moving a = %v, want [a b root]", got)
+	}
+
+	// As does detaching it with Put.
+	a.ParentID = ""
+	if err := c.Put(*a); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got, _ := c.Ancestors(a1.ID); !reflect.DeepEqual(got, []string{a.ID}) {
+		t.Errorf("Ancestors(a1) after detaching a = %v, want [a]", got)
+	}
+
+	// And deleting it.
+	if err := c.Delete(a); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := c.Ancestors(a1.ID); errors.Cause(err) != ErrNotFound {
+		t.Errorf("Ancestors(a1) after deleting a = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,119 @@
+package node
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// BackfillPositions gives a Position to the children stored without one,
+// e.g. by a version of this package that predates ordered children. Such
+// children are not in the GSI, so GetChildren and GetSiblings miss them.
+// Every child of an affected parent is renumbered: first those listed in
+// the parent's ChildIDs, in that order, then any others by their current
+// Position and ID. Parents that count fewer children than they have get a
+// LastChildPosition. It returns the number of Nodes written.
+//
+// It reads the whole table into memory, like GetAll, and rewrites Nodes
+// with BatchPut, so run it once after upgrading, while nothing else writes
+// to the table. Until then, children stored inline can still be read with
+// ConsistentRead(true), which finds them through the parent's ChildIDs
+// rather than the GSI.
+func (c Client) BackfillPositions() (int, error) {
+	log := c.log.Indent("BackfillPositions")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	log.Debug("scanning table...")
+	nodes, err := c.GetAll(ConsistentRead(true))
+	if err != nil {
+		return 0, errors.Wrap(err, "Client.BackfillPositions: error scanning table")
+	}
+
+	byID := map[string]*Node{}
+	children := map[string][]*Node{}
+	unpositioned := map[string]bool{}
+	for _, n := range nodes {
+		byID[n.ID] = n
+		if n.ParentID == "" {
+			continue
+		}
+
+		children[n.ParentID] = append(children[n.ParentID], n)
+		if n.Position == "" {
+			unpositioned[n.ParentID] = true
+		}
+	}
+
+	// A Node can be both a renumbered child and a parent, it is written once.
+	changed := map[string]bool{}
+	for parentID := range unpositioned {
+		parent, found := byID[parentID]
+		listed := []string{}
+		if found {
+			listed = parent.ChildIDs
+		}
+
+		ordered := backfillOrder(listed, children[parentID])
+		for i, child := range ordered {
+			if p := positionAt(i); child.Position != p {
+				child.Position = p
+				changed[child.ID] = true
+			}
+		}
+
+		// The parent might count fewer children than it has, make sure it
+		// does not give their positions out again.
+		if last := positionAt(len(ordered) - 1); found && last > parent.lastChildPosition() {
+			parent.notePosition(last)
+			changed[parent.ID] = true
+		}
+	}
+
+	toWrite := []*Node{}
+	for id := range changed {
+		toWrite = append(toWrite, byID[id])
+	}
+
+	if len(toWrite) == 0 {
+		return 0, nil
+	}
+
+	log.Debugf("storing %d nodes, under %d parents...", len(toWrite), len(unpositioned))
+	if err := c.BatchPut(toWrite); err != nil {
+		return 0, errors.Wrap(err, "Client.BackfillPositions: error storing nodes")
+	}
+
+	return len(toWrite), nil
+}
+
+// backfillOrder orders children by their place in listed, then by Position
+// and ID, an empty Position sorting last.
+func backfillOrder(listed []string, children []*Node) []*Node {
+	rank := map[string]int{}
+	for i, id := range listed {
+		rank[id] = i
+	}
+
+	ordered := append([]*Node{}, children...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		ra, aListed := rank[a.ID]
+		rb, bListed := rank[b.ID]
+
+		switch {
+		case aListed && bListed:
+			return ra < rb
+		case aListed != bListed:
+			return aListed
+		case (a.Position == "") != (b.Position == ""):
+			return b.Position == ""
+		case a.Position != b.Position:
+			return a.Position < b.Position
+		default:
+			return a.ID < b.ID
+		}
+	})
+
+	return ordered
+}
@@ -0,0 +1,37 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBackfillOrder(t *testing.T) {
+	children := []*Node{
+		{ID: "e"},
+		{ID: "d", Position: "0000011"},
+		{ID: "c"},
+		{ID: "b", Position: "0000001"},
+		{ID: "a"},
+	}
+
+	tests := []struct {
+		name   string
+		listed []string
+		want   string
+	}{
+		{"listed", []string{"e", "d", "c", "b", "a"}, "[e d c b a]"},
+		{"partly listed", []string{"c", "a"}, "[c a b d e]"},
+		{"not listed", nil, "[b d a c e]"},
+	}
+
+	for _, tt := range tests {
+		ids := []string{}
+		for _, n := range backfillOrder(tt.listed, children) {
+			ids = append(ids, n.ID)
+		}
+
+		if got := fmt.Sprint(ids); got != tt.want {
+			t.Errorf("%s: backfillOrder = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
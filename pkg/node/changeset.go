@@ -0,0 +1,140 @@
+package node
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrNotChild is returned when a Node is expected to be the child of
+// another Node, but is not.
+var ErrNotChild = errors.New("node is not a child of this parent")
+
+// ChangeSet lists the Nodes modified by RemoveChild, Detach or Reparent, so
+// they can be stored together with Client.ApplyChanges.
+type ChangeSet struct {
+	// Nodes are the modified Nodes, each listed once, in the order they were
+	// first modified.
+	Nodes []*Node
+
+	// Reparented are the IDs of the Nodes whose parent changed.
+	Reparented []string
+}
+
+// Merge returns a ChangeSet with the changes of both the receiver and other.
+// When both hold a Node with the same ID, the receiver's is kept.
+func (cs ChangeSet) Merge(other ChangeSet) ChangeSet {
+	merged := ChangeSet{}
+	merged.add(cs.Nodes...)
+	merged.add(other.Nodes...)
+
+	for _, ids := range [][]string{cs.Reparented, other.Reparented} {
+		for _, id := range ids {
+			if indexOf(merged.Reparented, id) < 0 {
+				merged.Reparented = append(merged.Reparented, id)
+			}
+		}
+	}
+
+	return merged
+}
+
+// IDs returns the IDs of the modified Nodes.
+func (cs ChangeSet) IDs() []string {
+	ids := []string{}
+	for _, n := range cs.Nodes {
+		ids = append(ids, n.ID)
+	}
+
+	return ids
+}
+
+// Empty returns true if nothing was modified.
+func (cs ChangeSet) Empty() bool {
+	return len(cs.Nodes) == 0
+}
+
+// add appends the given Nodes, skipping any already listed.
+func (cs *ChangeSet) add(nodes ...*Node) {
+	for _, n := range nodes {
+		if indexOf(cs.IDs(), n.ID) < 0 {
+			cs.Nodes = append(cs.Nodes, n)
+		}
+	}
+}
+
+// ApplyChanges stores every Node in the ChangeSet with a single BatchPut.
+// Before writing, it makes sure no reparented Node would become its own
+// ancestor, returning ErrSelfParent if one would.
+func (c Client) ApplyChanges(cs ChangeSet) error {
+	log := c.log.Indent("ApplyChanges")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	if cs.Empty() {
+		return nil
+	}
+
+	log.Debug("checking for cycles...")
+	for _, id := range cs.Reparented {
+		if err := c.checkAncestry(cs, id); err != nil {
+			return errors.Wrap(err, "Client.ApplyChanges")
+		}
+	}
+
+	log.Debug("storing nodes...")
+	if err := c.BatchPut(cs.Nodes); err != nil {
+		return errors.Wrap(err, "Client.ApplyChanges")
+	}
+
+	return nil
+}
+
+// checkAncestry walks up from the Node with the given ID as it will be once
+// the ChangeSet is stored, using the Nodes in the ChangeSet where it can and
+// the table elsewhere. It returns ErrSelfParent if the walk comes back to id.
+func (c Client) checkAncestry(cs ChangeSet, id string) error {
+	seen := map[string]bool{id: true}
+
+	for next := id; next != ""; {
+		n := cs.node(next)
+		if n == nil {
+			lineage, err := c.lineage(next)
+			if err != nil {
+				return errors.Wrapf(err, "error checking ancestors of %s", id)
+			}
+
+			// Follow the stored lineage until it reaches a Node that changes.
+			next = ""
+			for _, ancestor := range lineage[1:] {
+				if seen[ancestor] {
+					return errors.Wrapf(ErrSelfParent, "node %s would be its own ancestor", ancestor)
+				}
+				seen[ancestor] = true
+
+				if cs.node(ancestor) != nil {
+					next = ancestor
+					break
+				}
+			}
+			continue
+		}
+
+		if n.ParentID != "" && seen[n.ParentID] {
+			return errors.Wrapf(ErrSelfParent, "node %s would be its own ancestor", n.ParentID)
+		}
+		seen[n.ParentID] = true
+		next = n.ParentID
+	}
+
+	return nil
+}
+
+// node returns the Node with the given ID, or nil.
+func (cs ChangeSet) node(id string) *Node {
+	for _, n := range cs.Nodes {
+		if n.ID == id {
+			return n
+		}
+	}
+
+	return nil
+}
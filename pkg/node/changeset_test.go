@@ -0,0 +1,218 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/erumble/dynamo-playground/pkg/logger/loggertest"
+	"github.com/erumble/dynamo-playground/pkg/node/dynamotest"
+	"github.com/pkg/errors"
+)
+
+func TestRemoveChild(t *testing.T) {
+	parent, children := family(1, 3)
+	a, b, c := children[0], children[1], children[2]
+
+	cs, err := parent.RemoveChild(b)
+	if err != nil {
+		t.Fatalf("RemoveChild: %v", err)
+	}
+
+	if got := cs.IDs(); !sameIDs(got, []string{parent.ID, b.ID}) {
+		t.Errorf("ChangeSet holds %v, want the parent and the child", got)
+	}
+	if !sameIDs(cs.Reparented, []string{b.ID}) {
+		t.Errorf("Reparented = %v, want the child", cs.Reparented)
+	}
+	if b.HasParent() || b.Position != "" {
+		t.Errorf("removed child has parent %q and Position %q, want neither", b.ParentID, b.Position)
+	}
+	if !sameIDs(parent.ChildIDs, []string{a.ID, c.ID}) || parent.NumChildren() != 2 {
+		t.Errorf("parent has %d children %v, want 2", parent.NumChildren(), parent.ChildIDs)
+	}
+
+	if _, err := parent.RemoveChild(b); errors.Cause(err) != ErrNotChild {
+		t.Errorf("RemoveChild of a removed child = %v, want ErrNotChild", err)
+	}
+	if parent.NumChildren() != 2 {
+		t.Errorf("NumChildren = %d after the failed RemoveChild, want 2", parent.NumChildren())
+	}
+}
+
+func TestReparent(t *testing.T) {
+	p, children := family(1, 2)
+	q, _ := family(2, 0)
+	a, b := children[0], children[1]
+
+	cs, err := a.Reparent(p, q)
+	if err != nil {
+		t.Fatalf("Reparent: %v", err)
+	}
+
+	if got := cs.IDs(); !sameIDs(got, []string{p.ID, a.ID, q.ID}) {
+		t.Errorf("ChangeSet holds %v, want both parents and the child", got)
+	}
+	if !sameIDs(cs.Reparented, []string{a.ID}) {
+		t.Errorf("Reparented = %v, want the child", cs.Reparented)
+	}
+	if a.ParentID != q.ID || !sameIDs(q.ChildIDs, []string{a.ID}) || !sameIDs(p.ChildIDs, []string{b.ID}) {
+		t.Errorf("after Reparent a has parent %s, q children %v and p children %v", a.ParentID, q.ChildIDs, p.ChildIDs)
+	}
+
+	// A Node without a parent can be given one.
+	orphan := &Node{ID: "orphan"}
+	if _, err := orphan.Reparent(nil, q); err != nil || orphan.ParentID != q.ID {
+		t.Errorf("Reparent of an orphan = %v, with parent %q, want q", err, orphan.ParentID)
+	}
+}
+
+func TestReparentErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		move func(p, q *Node, children []*Node) error
+		want error
+	}{
+		{"no previous parent given", func(p, q *Node, children []*Node) error {
+			_, err := children[0].Reparent(nil, q)
+			return err
+		}, ErrHasParent},
+		{"wrong previous parent", func(p, q *Node, children []*Node) error {
+			_, err := children[0].Reparent(q, q)
+			return err
+		}, ErrNotChild},
+		{"under itself", func(p, q *Node, children []*Node) error {
+			_, err := children[0].Reparent(p, children[0])
+			return err
+		}, ErrSelfParent},
+		{"already listed", func(p, q *Node, children []*Node) error {
+			q.ChildIDs = []string{children[0].ID}
+			_, err := children[0].Reparent(p, q)
+			return err
+		}, ErrDuplicateChild},
+	}
+
+	for _, tt := range tests {
+		p, children := family(1, 2)
+		q, _ := family(2, 0)
+
+		if err := tt.move(p, q, children); errors.Cause(err) != tt.want {
+			t.Errorf("%s: Reparent = %v, want %v", tt.name, err, tt.want)
+		}
+
+		// Nothing is changed.
+		if !sameIDs(p.ChildIDs, idsOf(children)) || p.NumChildren() != 2 || children[0].ParentID != p.ID {
+			t.Errorf("%s: p has children %v and the child parent %s after the failed Reparent", tt.name, p.ChildIDs, children[0].ParentID)
+		}
+	}
+}
+
+func TestChangeSetMerge(t *testing.T) {
+	a, b := &Node{ID: "a", Metadata: "first"}, &Node{ID: "b"}
+	other := &Node{ID: "a", Metadata: "second"}
+
+	merged := ChangeSet{Nodes: []*Node{a, b}, Reparented: []string{"b"}}.
+		Merge(ChangeSet{Nodes: []*Node{other, {ID: "c"}}, Reparented: []string{"c", "b"}})
+
+	if got := merged.IDs(); !sameIDs(got, []string{"a", "b", "c"}) {
+		t.Errorf("Merge holds %v, want [a b c]", got)
+	}
+	if merged.node("a") != a {
+		t.Errorf("Merge kept %+v for a, want the receiver's", merged.node("a"))
+	}
+	if !sameIDs(merged.Reparented, []string{"b", "c"}) {
+		t.Errorf("Reparented = %v, want [b c]", merged.Reparented)
+	}
+
+	if !(ChangeSet{}).Empty() || merged.Empty() {
+		t.Error("Empty is wrong")
+	}
+}
+
+// getAll fetches the Nodes with the given IDs.
+func getAll(t *testing.T, c Client, ids ...string) []*Node {
+	t.Helper()
+
+	nodes := []*Node{}
+	for _, id := range ids {
+		n, err := c.getExisting(id)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", id, err)
+		}
+		nodes = append(nodes, n)
+	}
+
+	return nodes
+}
+
+func TestApplyChanges(t *testing.T) {
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents")
+	ids := idsOf(storeTree(t, c))
+
+	// Move a1 from a to b, and remove a2 from the tree.
+	nodes := getAll(t, c, ids...)
+	a, b, a1, a2 := nodes[1], nodes[2], nodes[3], nodes[4]
+
+	moved, err := a1.Reparent(a, b)
+	if err != nil {
+		t.Fatalf("Reparent: %v", err)
+	}
+	removed, err := a.RemoveChild(a2)
+	if err != nil {
+		t.Fatalf("RemoveChild: %v", err)
+	}
+
+	if err := c.ApplyChanges(moved.Merge(removed)); err != nil {
+		t.Fatalf("ApplyChanges: %v", err)
+	}
+
+	checkChildren(t, c, a.ID, []string{})
+	checkChildren(t, c, b.ID, []string{a1.ID})
+	if stored := getAll(t, c, a2.ID)[0]; stored.HasParent() {
+		t.Errorf("removed child is stored with parent %s", stored.ParentID)
+	}
+}
+
+func TestApplyChangesCycles(t *testing.T) {
+	writes := 0
+	count := Intercept(func(operation string, input interface{}, next Invoker) (interface{}, error) {
+		if operation == "BatchWriteItem" {
+			writes++
+		}
+		return next(operation, input)
+	})
+
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents", WithMiddleware(count))
+	ids := idsOf(storeTree(t, c))
+	writes = 0
+
+	tests := []struct {
+		name string
+		move func(nodes []*Node) (ChangeSet, error)
+	}{
+		// a1, the new parent, is in the ChangeSet.
+		{"under a child", func(nodes []*Node) (ChangeSet, error) {
+			return nodes[1].Reparent(nodes[0], nodes[3])
+		}},
+		// a2's parent a is not, so the walk continues in the table.
+		{"under a grandchild", func(nodes []*Node) (ChangeSet, error) {
+			return nodes[0].Reparent(nil, nodes[4])
+		}},
+	}
+
+	for _, tt := range tests {
+		cs, err := tt.move(getAll(t, c, ids...))
+		if err != nil {
+			t.Fatalf("%s: Reparent: %v", tt.name, err)
+		}
+
+		if err := c.ApplyChanges(cs); errors.Cause(err) != ErrSelfParent {
+			t.Errorf("%s: ApplyChanges = %v, want ErrSelfParent", tt.name, err)
+		}
+	}
+
+	if err := c.ApplyChanges(ChangeSet{}); err != nil {
+		t.Errorf("ApplyChanges of an empty ChangeSet = %v", err)
+	}
+	if writes != 0 {
+		t.Errorf("ApplyChanges made %d writes, want none", writes)
+	}
+}
@@ -0,0 +1,248 @@
+package node
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/pkg/errors"
+)
+
+// ChildStorage determines how a parent keeps track of its children in DynamoDB.
+type ChildStorage int
+
+const (
+	// InlineChildren stores every child ID in the parent's ChildIDs list, as
+	// well as in the GSI. It is the default. The number of children a parent
+	// can have is limited by DynamoDB's 400 KB item size.
+	InlineChildren ChildStorage = iota
+
+	// IndexedChildren only keeps track of children in the GSI, the parent's
+	// ChildIDs are never stored, only its ChildCount. A parent can have any
+	// number of children, but they can not be read consistently.
+	//
+	// Put and BatchPut store a parent's ChildCount as it was read, so writing
+	// a parent while AddChild or InsertChild adds to it loses their increment.
+	// Change the children of a stored parent with those instead.
+	IndexedChildren
+)
+
+// WithChildStorage sets how parents keep track of their children, the
+// default is InlineChildren.
+func WithChildStorage(s ChildStorage) ClientOption {
+	return func(c *Client) {
+		c.childStorage = s
+	}
+}
+
+// AddChild stores child as the last child of the Node with the given
+// parentID. Unlike storing both with BatchPut, the parent is updated in
+// place, so concurrent calls for the same parent do not overwrite each other.
+// It returns ErrNotFound if the parent does not exist, and any of the errors
+// from Validate or the Client's Limits before anything is written.
+//
+// The child is written before the parent is updated, and deleted again if
+// the parent can't be, so a failure never leaves the parent counting a child
+// that was not stored.
+func (c Client) AddChild(parentID string, child *Node) error {
+	log := c.log.Indent("AddChild")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	log.Debug("validating child...")
+	candidate := *child
+	candidate.ParentID = parentID
+	if err := c.validateWrite([]*Node{&candidate}); err != nil {
+		return errors.Wrap(err, "Client.AddChild")
+	}
+
+	for attempt := 0; ; attempt++ {
+		log.Debug("fetching parent...")
+		parent, err := c.getExisting(parentID, ConsistentRead(true), StructureOnly())
+		if err != nil {
+			return errors.Wrap(err, "Client.AddChild: error fetching parent")
+		}
+
+		if err := c.checkAddChild(parent, child.ID); err != nil {
+			return errors.Wrap(err, "Client.AddChild")
+		}
+
+		stored := *parent
+		child.ParentID = parentID
+		child.Position = parent.appendPosition()
+
+		log.Debug("storing child...")
+		if err := c.Put(*child); err != nil {
+			return errors.Wrap(err, "Client.AddChild: error storing child")
+		}
+
+		log.Debug("registering child with parent...")
+		err = c.incrementChildCount(&stored, child.ID, child.Position)
+		if errors.Cause(err) == errParentChanged && attempt < maxBatchRetries {
+			log.Warnf("parent %s changed while adding child %s, attempt %d of %d...", parentID, child.ID, attempt+1, maxBatchRetries)
+			continue
+		}
+		if err != nil {
+			log.Debug("removing child...")
+			if derr := c.Delete(child); derr != nil {
+				log.Errorf("error removing child %s of %s after failing to update the parent: %v", child.ID, parentID, derr)
+			}
+			return errors.Wrap(err, "Client.AddChild: error updating parent")
+		}
+
+		return nil
+	}
+}
+
+// checkAddChild returns the error that adding childID to parent would run
+// into, if any: ErrDuplicateChild, or an error from the Client's MaxFanOut.
+func (c Client) checkAddChild(parent *Node, childID string) error {
+	if indexOf(parent.ChildIDs, childID) >= 0 {
+		return errors.Wrapf(ErrDuplicateChild, "node %s is already a child of %s", childID, parent.ID)
+	}
+
+	return c.limits.checkFanOut(&Node{ID: parent.ID, ChildCount: parent.NumChildren() + 1})
+}
+
+// errParentChanged is returned by incrementChildCount when the parent was
+// changed by someone else since it was read.
+var errParentChanged = errors.New("parent changed")
+
+// incrementChildCount atomically increments the ChildCount of the given
+// parent, appending childID to its ChildIDs when they are stored inline, and
+// storing lastPosition as its LastChildPosition if it is not empty.
+// The update is conditional on the parent's ChildCount and LastChildPosition
+// being what they were when parent was read, on childID not already being
+// listed, and on the Client's MaxFanOut not being reached. It returns
+// errParentChanged if the parent changed in the meantime.
+func (c Client) incrementChildCount(parent *Node, childID, lastPosition string) error {
+	log := c.log.Indent("incrementChildCount")
+
+	log.Debug("generating UpdateItemInput...")
+	expression := "ADD ChildCount :one"
+	condition := "attribute_exists(ID)"
+	values := map[string]*dynamodb.AttributeValue{
+		":one": {N: aws.String("1")},
+	}
+
+	// A ChildCount of 0 is not stored. A parent stored before ChildCount was
+	// maintained only has its ChildIDs, NumChildren accounts for that.
+	if parent.ChildCount == 0 {
+		condition += " AND attribute_not_exists(ChildCount)"
+	} else {
+		condition += " AND ChildCount = :count"
+		values[":count"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(parent.ChildCount))}
+	}
+
+	if parent.LastChildPosition == "" {
+		condition += " AND attribute_not_exists(LastChildPosition)"
+	} else {
+		condition += " AND LastChildPosition = :previous"
+		values[":previous"] = &dynamodb.AttributeValue{S: aws.String(parent.LastChildPosition)}
+	}
+
+	set := []string{}
+	if lastPosition != "" && lastPosition != parent.LastChildPosition {
+		set = append(set, "LastChildPosition = :last")
+		values[":last"] = &dynamodb.AttributeValue{S: aws.String(lastPosition)}
+	}
+
+	if c.childStorage == InlineChildren {
+		set = append(set, "ChildIDs = list_append(if_not_exists(ChildIDs, :empty), :ids)")
+		condition += " AND NOT contains(ChildIDs, :id)"
+		values[":empty"] = &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}}
+		values[":ids"] = &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{{S: aws.String(childID)}}}
+		values[":id"] = &dynamodb.AttributeValue{S: aws.String(childID)}
+	}
+
+	if len(set) > 0 {
+		expression += " SET " + strings.Join(set, ", ")
+	}
+
+	if c.limits.MaxFanOut > 0 {
+		condition += " AND (attribute_not_exists(ChildCount) OR ChildCount < :max)"
+		values[":max"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(c.limits.MaxFanOut))}
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeValues: values,
+		Key: map[string]*dynamodb.AttributeValue{
+			"ID": {S: aws.String(parent.ID)},
+		},
+		TableName:        aws.String(c.tableName),
+		UpdateExpression: aws.String(expression),
+	}
+
+	log.Debugf("UpdateItemInput:\n%v", c.dump(input))
+
+	log.Debug("calling UpdateItem...")
+	res, err := c.dataStore.UpdateItem(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return c.explainConditionFailure(parent.ID, childID)
+		}
+		return err
+	}
+
+	log.Debugf("UpdateItemOutput:\n%v", c.dump(res))
+
+	return nil
+}
+
+// orderedChildren returns the children of the given parent in order, as
+// consistently as the ChildStorage allows.
+func (c Client) orderedChildren(parent *Node) ([]*Node, error) {
+	if c.childStorage == IndexedChildren {
+		return c.query(parent.ID, "ParentID")
+	}
+
+	return c.resolveChildren(parent.ID, []ReadOption{ConsistentRead(true)})
+}
+
+// marshalNode marshals the given Node into the attribute value map that is
+// stored in DynamoDB, leaving out the ChildIDs unless they are stored inline,
+// and adding the attributes of any MetadataIndex.
+func (c Client) marshalNode(n *Node) (map[string]*dynamodb.AttributeValue, error) {
+	av, err := dynamodbattribute.MarshalMap(n)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.childStorage == IndexedChildren {
+		delete(av, "ChildIDs")
+	}
+
+	for _, idx := range c.metadataIndexes {
+		if err := idx.validate(); err != nil {
+			return nil, err
+		}
+
+		if v, ok := idx.Extract(n); ok && v != "" {
+			av[idx.Attribute] = &dynamodb.AttributeValue{S: aws.String(v)}
+		}
+	}
+
+	return av, nil
+}
+
+// explainConditionFailure works out which of the conditions in
+// incrementChildCount stopped childID from being added to parentID.
+func (c Client) explainConditionFailure(parentID, childID string) error {
+	parent, found, err := c.get(parentID, []ReadOption{ConsistentRead(true), Project("ID", "ChildIDs", "ChildCount")})
+	switch {
+	case err != nil:
+		return err
+	case !found:
+		return errors.Wrapf(ErrNotFound, "parent %s", parentID)
+	}
+
+	if err := c.checkAddChild(parent, childID); err != nil {
+		return err
+	}
+
+	return errors.Wrapf(errParentChanged, "parent %s changed while adding child %s", parentID, childID)
+}
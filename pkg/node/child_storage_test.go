@@ -0,0 +1,280 @@
+package node
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/erumble/dynamo-playground/pkg/logger/loggertest"
+	"github.com/erumble/dynamo-playground/pkg/node/dynamotest"
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+var childStorages = []struct {
+	name    string
+	storage ChildStorage
+}{
+	{"inline", InlineChildren},
+	{"indexed", IndexedChildren},
+}
+
+// idsOf returns the IDs of the given Nodes, in order.
+func idsOf(nodes []*Node) []string {
+	ids := []string{}
+	for _, n := range nodes {
+		ids = append(ids, n.ID)
+	}
+
+	return ids
+}
+
+// storeParent stores a Node without children, and returns it along with n
+// Nodes to add to it.
+func storeParent(t *testing.T, c Client, n int) (*Node, []*Node) {
+	t.Helper()
+
+	parent := &Node{ID: "parent"}
+	if err := c.Put(*parent); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	children := []*Node{}
+	for i := 0; i < n; i++ {
+		children = append(children, &Node{ID: fmt.Sprintf("child%d", i)})
+	}
+
+	return parent, children
+}
+
+// checkChildren fails the test if the stored parent does not count and, when
+// they are stored inline, list the given children, or GetChildren does not
+// return them in order.
+func checkChildren(t *testing.T, c Client, parentID string, want []string) {
+	t.Helper()
+
+	parent, err := c.getExisting(parentID)
+	if err != nil {
+		t.Fatalf("Get parent: %v", err)
+	}
+
+	if parent.ChildCount != len(want) {
+		t.Errorf("parent ChildCount = %d, want %d", parent.ChildCount, len(want))
+	}
+
+	if c.childStorage == InlineChildren && !sameIDs(parent.ChildIDs, want) {
+		t.Errorf("parent ChildIDs = %v, want %v", parent.ChildIDs, want)
+	}
+	if c.childStorage == IndexedChildren && len(parent.ChildIDs) != 0 {
+		t.Errorf("parent ChildIDs = %v, want them not stored", parent.ChildIDs)
+	}
+
+	children, err := c.GetChildren(*parent)
+	if err != nil {
+		t.Fatalf("GetChildren: %v", err)
+	}
+	if got := idsOf(children); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetChildren = %v, want %v", got, want)
+	}
+	checkPositions(t, children...)
+
+	if len(children) > 0 && parent.LastChildPosition < children[len(children)-1].Position {
+		t.Errorf("parent LastChildPosition %q is before its last child's Position %q", parent.LastChildPosition, children[len(children)-1].Position)
+	}
+}
+
+func TestAddChild(t *testing.T) {
+	for _, cs := range childStorages {
+		t.Run(cs.name, func(t *testing.T) {
+			c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents", WithChildStorage(cs.storage))
+
+			parent, children := storeParent(t, c, 3)
+
+			for _, child := range children {
+				if err := c.AddChild(parent.ID, child); err != nil {
+					t.Fatalf("AddChild: %v", err)
+				}
+			}
+
+			checkChildren(t, c, parent.ID, idsOf(children))
+		})
+	}
+}
+
+func TestAddChildMissingParent(t *testing.T) {
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents")
+
+	child := &Node{ID: "child"}
+	if err := c.AddChild("missing", child); errors.Cause(err) != ErrNotFound {
+		t.Errorf("AddChild = %v, want ErrNotFound", err)
+	}
+	if _, err := c.getExisting(child.ID); errors.Cause(err) != ErrNotFound {
+		t.Errorf("the child was stored without its parent: %v", err)
+	}
+}
+
+func TestAddChildDuplicate(t *testing.T) {
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents")
+
+	parent, children := storeParent(t, c, 1)
+	if err := c.AddChild(parent.ID, children[0]); err != nil {
+		t.Fatalf("AddChild: %v", err)
+	}
+
+	if err := c.AddChild(parent.ID, children[0]); errors.Cause(err) != ErrDuplicateChild {
+		t.Errorf("AddChild of an existing child = %v, want ErrDuplicateChild", err)
+	}
+
+	checkChildren(t, c, parent.ID, idsOf(children))
+}
+
+// TestAddChildParentChanged checks that AddChild starts over when another
+// child is added while it is adding its own, and that neither is lost.
+func TestAddChildParentChanged(t *testing.T) {
+	for _, cs := range childStorages {
+		t.Run(cs.name, func(t *testing.T) {
+			db := dynamotest.New()
+			other := NewClient(loggertest.Nop(), db, "nodes", "parents", WithChildStorage(cs.storage))
+
+			parent, children := storeParent(t, other, 2)
+
+			// The first UpdateItem finds the parent changed by another client.
+			interfered := false
+			interfere := Intercept(func(operation string, input interface{}, next Invoker) (interface{}, error) {
+				if operation == "UpdateItem" && !interfered {
+					interfered = true
+					if err := other.AddChild(parent.ID, children[1]); err != nil {
+						t.Fatalf("concurrent AddChild: %v", err)
+					}
+				}
+				return next(operation, input)
+			})
+
+			log := loggertest.New()
+			c := NewClient(log, db, "nodes", "parents", WithChildStorage(cs.storage), WithMiddleware(interfere))
+
+			if err := c.AddChild(parent.ID, children[0]); err != nil {
+				t.Fatalf("AddChild: %v", err)
+			}
+
+			checkChildren(t, c, parent.ID, []string{children[1].ID, children[0].ID})
+
+			if log.Entries().Level(zapcore.WarnLevel).Name("nodeClient.AddChild").Len() != 1 {
+				t.Errorf("AddChild logged warnings %v, want one for the retry", log.Entries().Level(zapcore.WarnLevel).Messages())
+			}
+		})
+	}
+}
+
+func TestAddChildGivesUp(t *testing.T) {
+	db := dynamotest.New()
+	other := NewClient(loggertest.Nop(), db, "nodes", "parents")
+
+	parent, _ := storeParent(t, other, 0)
+
+	// Every UpdateItem finds the parent changed.
+	added := []*Node{}
+	interfere := Intercept(func(operation string, input interface{}, next Invoker) (interface{}, error) {
+		if operation == "UpdateItem" {
+			n := &Node{ID: fmt.Sprintf("other%d", len(added))}
+			if err := other.AddChild(parent.ID, n); err != nil {
+				t.Fatalf("concurrent AddChild: %v", err)
+			}
+			added = append(added, n)
+		}
+		return next(operation, input)
+	})
+
+	c := NewClient(loggertest.Nop(), db, "nodes", "parents", WithMiddleware(interfere))
+
+	child := &Node{ID: "child"}
+	if err := c.AddChild(parent.ID, child); errors.Cause(err) != errParentChanged {
+		t.Fatalf("AddChild = %v, want errParentChanged", err)
+	}
+	if len(added) != maxBatchRetries+1 {
+		t.Errorf("AddChild tried %d times, want %d", len(added), maxBatchRetries+1)
+	}
+
+	if _, err := c.getExisting(child.ID); errors.Cause(err) != ErrNotFound {
+		t.Errorf("the child was left behind: %v", err)
+	}
+	checkChildren(t, c, parent.ID, idsOf(added))
+}
+
+func TestAddChildRemovesChildOnFailure(t *testing.T) {
+	for _, cs := range childStorages {
+		t.Run(cs.name, func(t *testing.T) {
+			db := dynamotest.New()
+			c := NewClient(loggertest.Nop(), db, "nodes", "parents", WithChildStorage(cs.storage))
+
+			parent, children := storeParent(t, c, 2)
+			if err := c.AddChild(parent.ID, children[0]); err != nil {
+				t.Fatalf("AddChild: %v", err)
+			}
+
+			db.Fail["UpdateItem"] = errors.New("boom")
+			if err := c.AddChild(parent.ID, children[1]); err == nil {
+				t.Fatal("AddChild returned no error")
+			}
+			delete(db.Fail, "UpdateItem")
+
+			if _, err := c.getExisting(children[1].ID); errors.Cause(err) != ErrNotFound {
+				t.Errorf("the child was left behind: %v", err)
+			}
+			checkChildren(t, c, parent.ID, idsOf(children[:1]))
+		})
+	}
+}
+
+func TestInsertChildRemovesChildOnFailure(t *testing.T) {
+	db := dynamotest.New()
+	c := NewClient(loggertest.Nop(), db, "nodes", "parents", WithChildStorage(IndexedChildren))
+
+	parent, children := storeParent(t, c, 2)
+	if err := c.AddChild(parent.ID, children[0]); err != nil {
+		t.Fatalf("AddChild: %v", err)
+	}
+
+	db.Fail["UpdateItem"] = errors.New("boom")
+	if err := c.InsertChild(parent.ID, children[1], 0); err == nil {
+		t.Fatal("InsertChild returned no error")
+	}
+	delete(db.Fail, "UpdateItem")
+
+	if _, err := c.getExisting(children[1].ID); errors.Cause(err) != ErrNotFound {
+		t.Errorf("the child was left behind: %v", err)
+	}
+	checkChildren(t, c, parent.ID, idsOf(children[:1]))
+}
+
+func TestInsertChild(t *testing.T) {
+	for _, cs := range childStorages {
+		t.Run(cs.name, func(t *testing.T) {
+			c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents", WithChildStorage(cs.storage))
+
+			parent, children := storeParent(t, c, 2)
+			for _, child := range children {
+				if err := c.AddChild(parent.ID, child); err != nil {
+					t.Fatalf("AddChild: %v", err)
+				}
+			}
+
+			first, last := &Node{ID: "first"}, &Node{ID: "last"}
+			middle := &Node{ID: "middle"}
+			for _, insert := range []struct {
+				child    *Node
+				position int
+			}{{middle, 1}, {first, 0}, {last, 10}} {
+				if err := c.InsertChild(parent.ID, insert.child, insert.position); err != nil {
+					t.Fatalf("InsertChild(%s, %d): %v", insert.child.ID, insert.position, err)
+				}
+			}
+
+			checkChildren(t, c, parent.ID, []string{first.ID, children[0].ID, middle.ID, children[1].ID, last.ID})
+
+			if err := c.InsertChild(parent.ID, middle, 0); err == nil {
+				t.Error("InsertChild of an existing child returned no error")
+			}
+		})
+	}
+}
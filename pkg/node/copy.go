@@ -0,0 +1,85 @@
+package node
+
+import (
+	"github.com/pkg/errors"
+)
+
+// CopyOptions configures CopySubtree.
+type CopyOptions struct {
+	// TransformMetadata, if set, is given each original Node and returns the
+	// Metadata for its copy. The original Metadata is copied when it is nil.
+	TransformMetadata func(original *Node) string
+}
+
+// CopySubtree copies the Node with the given srcID, and all of its
+// descendants, to be the last child of the Node with the given dstParentID.
+// An empty dstParentID makes the copy a new root. Every copy is given a new
+// ID, and the structure of the subtree, including the order of siblings, is
+// preserved. It returns a map of each original ID to the ID of its copy.
+//
+// The descendants are written first and the copy of the root last, so the
+// copy only becomes reachable from the destination once it is complete.
+func (c Client) CopySubtree(srcID, dstParentID string, opts CopyOptions) (map[string]string, error) {
+	log := c.log.Indent("CopySubtree")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	// Check the destination up front, rather than leaving orphaned copies
+	// behind when it turns out not to exist.
+	if dstParentID != "" {
+		log.Debug("checking destination...")
+		if _, err := c.getExisting(dstParentID, KeysOnly()); err != nil {
+			return nil, errors.Wrap(err, "Client.CopySubtree: error fetching destination")
+		}
+	}
+
+	log.Debug("fetching source subtree...")
+	src, err := c.GetSubtree(srcID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Client.CopySubtree: error fetching source")
+	}
+
+	log.Debug("copying nodes...")
+	ids := map[string]string{}
+	copies := map[string]*Node{}
+	ordered := []*Node{}
+
+	// GetSubtree returns every parent before its children, and siblings in
+	// order, so each copy can be registered with its parent's copy as it goes.
+	for _, n := range src {
+		var cp *Node
+		if n.ID == srcID {
+			cp = NewWithGenerator(nil, c.idGen)
+		} else {
+			cp = New(copies[n.ParentID])
+		}
+
+		cp.Metadata = n.Metadata
+		if opts.TransformMetadata != nil {
+			cp.Metadata = opts.TransformMetadata(n)
+		}
+
+		ids[n.ID] = cp.ID
+		copies[n.ID] = cp
+		ordered = append(ordered, cp)
+	}
+
+	root, descendants := ordered[0], ordered[1:]
+
+	log.Debugf("storing %d descendants...", len(descendants))
+	if err := c.BatchPut(descendants); err != nil {
+		return nil, errors.Wrap(err, "Client.CopySubtree: error storing descendants")
+	}
+
+	log.Debug("storing root...")
+	if dstParentID == "" {
+		err = c.Put(*root)
+	} else {
+		err = c.AddChild(dstParentID, root)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Client.CopySubtree: error storing root")
+	}
+
+	return ids, nil
+}
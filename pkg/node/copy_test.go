@@ -0,0 +1,130 @@
+package node
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/erumble/dynamo-playground/pkg/logger/loggertest"
+	"github.com/erumble/dynamo-playground/pkg/node/dynamotest"
+	"github.com/pkg/errors"
+)
+
+// storeTree stores a root with two children, the first of which has two
+// children of its own, and returns them all, parents first.
+func storeTree(t *testing.T, c Client) []*Node {
+	t.Helper()
+
+	root := NewWithGenerator(nil, NewDeterministicGenerator(1))
+	root.Metadata = "root"
+	a, b := root.CreateChild(), root.CreateChild()
+	a.Metadata, b.Metadata = "a", "b"
+	a1, a2 := a.CreateChild(), a.CreateChild()
+	a1.Metadata, a2.Metadata = "a1", "a2"
+
+	nodes := []*Node{root, a, b, a1, a2}
+	if err := c.BatchPut(nodes); err != nil {
+		t.Fatalf("BatchPut: %v", err)
+	}
+
+	return nodes
+}
+
+func TestCopySubtree(t *testing.T) {
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents", WithIDGenerator(NewDeterministicGenerator(2)))
+
+	src := storeTree(t, c)
+	dst, existing := storeParent(t, c, 1)
+	if err := c.AddChild(dst.ID, existing[0]); err != nil {
+		t.Fatalf("AddChild: %v", err)
+	}
+
+	ids, err := c.CopySubtree(src[1].ID, dst.ID, CopyOptions{TransformMetadata: func(n *Node) string {
+		return strings.ToUpper(n.Metadata)
+	}})
+	if err != nil {
+		t.Fatalf("CopySubtree: %v", err)
+	}
+
+	// a and its two children are copied, under new IDs.
+	if len(ids) != 3 {
+		t.Fatalf("CopySubtree copied %v, want a, a1 and a2", ids)
+	}
+	for from, to := range ids {
+		if from == to {
+			t.Errorf("the copy of %s kept its ID", from)
+		}
+	}
+
+	copied, err := c.GetSubtree(ids[src[1].ID])
+	if err != nil {
+		t.Fatalf("GetSubtree: %v", err)
+	}
+
+	want := []string{ids[src[1].ID], ids[src[3].ID], ids[src[4].ID]}
+	if got := idsOf(copied); !reflect.DeepEqual(got, want) {
+		t.Errorf("copied subtree = %v, want %v, in the original order", got, want)
+	}
+	for i, n := range copied {
+		if original := []*Node{src[1], src[3], src[4]}[i]; n.Metadata != strings.ToUpper(original.Metadata) {
+			t.Errorf("copy of %s has Metadata %q, want it transformed", original.ID, n.Metadata)
+		}
+	}
+	if copied[1].ParentID != copied[0].ID {
+		t.Errorf("copied a1 has parent %s, want the copy of a", copied[1].ParentID)
+	}
+
+	checkChildren(t, c, dst.ID, []string{existing[0].ID, ids[src[1].ID]})
+
+	// The source is left as it was.
+	original, err := c.GetSubtree(src[0].ID)
+	if err != nil {
+		t.Fatalf("GetSubtree: %v", err)
+	}
+	if got := idsOf(original); !reflect.DeepEqual(got, idsOf(src)) {
+		t.Errorf("source subtree = %v after copying, want %v", got, idsOf(src))
+	}
+}
+
+func TestCopySubtreeToRoot(t *testing.T) {
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents")
+
+	src := storeTree(t, c)
+
+	ids, err := c.CopySubtree(src[0].ID, "", CopyOptions{})
+	if err != nil {
+		t.Fatalf("CopySubtree: %v", err)
+	}
+
+	copied, err := c.GetSubtree(ids[src[0].ID])
+	if err != nil {
+		t.Fatalf("GetSubtree: %v", err)
+	}
+
+	if len(copied) != len(src) || copied[0].HasParent() {
+		t.Errorf("copied %d nodes under %q, want %d under a new root", len(copied), copied[0].ParentID, len(src))
+	}
+	for i, n := range copied {
+		if n.ID != ids[src[i].ID] || n.Metadata != src[i].Metadata {
+			t.Errorf("node %d of the copy is %s %q, want the copy of %s %q", i, n.ID, n.Metadata, src[i].ID, src[i].Metadata)
+		}
+	}
+}
+
+func TestCopySubtreeMissing(t *testing.T) {
+	db := dynamotest.New()
+	c := NewClient(loggertest.Nop(), db, "nodes", "parents")
+
+	src := storeTree(t, c)
+
+	if _, err := c.CopySubtree(src[0].ID, "missing", CopyOptions{}); errors.Cause(err) != ErrNotFound {
+		t.Errorf("CopySubtree to a missing destination = %v, want ErrNotFound", err)
+	}
+	if _, err := c.CopySubtree("missing", "", CopyOptions{}); errors.Cause(err) != ErrNotFound {
+		t.Errorf("CopySubtree of a missing source = %v, want ErrNotFound", err)
+	}
+
+	if db.Len() != len(src) {
+		t.Errorf("the table holds %d items, want the %d originals and no copies", db.Len(), len(src))
+	}
+}
@@ -0,0 +1,293 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const (
+	// DefaultDumpSize is the most bytes of a request or response logged at
+	// debug level, see WithDumpSize.
+	DefaultDumpSize = 4096
+
+	// maxDumpIDs is the most IDs listed when summarising a batch.
+	maxDumpIDs = 10
+
+	// redacted replaces the values masked by a Redactor.
+	redacted = "[REDACTED]"
+)
+
+// Redactor masks sensitive values in an item before it is logged. It is
+// given a copy of each item, key and set of expression values in a request
+// or response, and changes it in place.
+type Redactor func(item map[string]*dynamodb.AttributeValue)
+
+// WithDumpSize sets the most bytes of each request and response the Client
+// logs at debug level, anything beyond it is cut off. A size that is not
+// positive logs them in full.
+func WithDumpSize(size int) ClientOption {
+	return func(c *Client) {
+		c.dumpSize = size
+	}
+}
+
+// WithRedactor masks sensitive values in the requests and responses the
+// Client logs at debug level. It can be given multiple times, every
+// Redactor is applied.
+func WithRedactor(r Redactor) ClientOption {
+	return func(c *Client) {
+		c.redactors = append(c.redactors, r)
+	}
+}
+
+// RedactAttributes masks the whole value of the given attributes, e.g. those
+// of a MetadataIndex that holds a sensitive field.
+func RedactAttributes(attributes ...string) Redactor {
+	return func(item map[string]*dynamodb.AttributeValue) {
+		for _, attr := range attributes {
+			if _, ok := item[attr]; ok {
+				item[attr] = &dynamodb.AttributeValue{S: aws.String(redacted)}
+			}
+		}
+	}
+}
+
+// RedactMetadataFields masks the given top level fields of Metadata that
+// holds a JSON object. Metadata that is not a JSON object is left alone.
+func RedactMetadataFields(fields ...string) Redactor {
+	return func(item map[string]*dynamodb.AttributeValue) {
+		av, ok := item["Metadata"]
+		if !ok || av.S == nil {
+			return
+		}
+
+		metadata := map[string]json.RawMessage{}
+		if err := json.Unmarshal([]byte(*av.S), &metadata); err != nil {
+			return
+		}
+
+		masked := false
+		for _, field := range fields {
+			if _, ok := metadata[field]; ok {
+				metadata[field] = json.RawMessage(`"` + redacted + `"`)
+				masked = true
+			}
+		}
+
+		if masked {
+			// This can't error, every value was already valid JSON.
+			b, _ := json.Marshal(metadata)
+			item["Metadata"] = &dynamodb.AttributeValue{S: aws.String(string(b))}
+		}
+	}
+}
+
+// dump returns a fmt.Stringer that formats a DynamoDB request or response
+// for debug logging. Nothing is formatted unless the String method is
+// called, which the logger only does when debug logging is enabled.
+func (c Client) dump(v interface{}) fmt.Stringer {
+	return lazyDump{value: v, size: c.dumpSize, redactors: c.redactors}
+}
+
+type lazyDump struct {
+	value     interface{}
+	size      int
+	redactors []Redactor
+}
+
+// String summarises batches and query results, redacts everything else, and
+// truncates the result. Batches list their tables by name, so the output is
+// the same for the same request.
+func (d lazyDump) String() string {
+	s := ""
+	switch v := d.value.(type) {
+	case *dynamodb.BatchGetItemInput:
+		s = summariseBatchGet(v)
+	case *dynamodb.BatchGetItemOutput:
+		s = summariseBatchGetOutput(v)
+	case *dynamodb.BatchWriteItemInput:
+		s = summariseBatchWrite(v.RequestItems)
+	case *dynamodb.BatchWriteItemOutput:
+		s = "UnprocessedItems: " + summariseBatchWrite(v.UnprocessedItems)
+	case *dynamodb.QueryOutput:
+		s = summariseItems(v.Items, v.ScannedCount, v.LastEvaluatedKey)
+	case *dynamodb.ScanOutput:
+		s = summariseItems(v.Items, v.ScannedCount, v.LastEvaluatedKey)
+	default:
+		s = fmt.Sprint(d.redact(v))
+	}
+
+	if d.size > 0 && len(s) > d.size {
+		// Back off to the start of a rune, rather than cut one in half.
+		cut := d.size
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		s = fmt.Sprintf("%s... (%d more bytes)", s[:cut], len(s)-cut)
+	}
+
+	return s
+}
+
+// redact returns a copy of v with every Redactor applied to its items.
+func (d lazyDump) redact(v interface{}) interface{} {
+	if len(d.redactors) == 0 {
+		return v
+	}
+
+	v = awsutil.CopyOf(v)
+
+	items := []map[string]*dynamodb.AttributeValue{}
+	switch v := v.(type) {
+	case *dynamodb.GetItemInput:
+		items = append(items, v.Key)
+	case *dynamodb.GetItemOutput:
+		items = append(items, v.Item)
+	case *dynamodb.PutItemInput:
+		items = append(items, v.Item, v.ExpressionAttributeValues)
+	case *dynamodb.DeleteItemInput:
+		items = append(items, v.Key, v.ExpressionAttributeValues)
+	case *dynamodb.UpdateItemInput:
+		items = append(items, v.Key, v.ExpressionAttributeValues)
+	case *dynamodb.UpdateItemOutput:
+		items = append(items, v.Attributes)
+	case *dynamodb.QueryInput:
+		items = append(items, v.ExpressionAttributeValues, v.ExclusiveStartKey)
+	case *dynamodb.ScanInput:
+		items = append(items, v.ExpressionAttributeValues, v.ExclusiveStartKey)
+	}
+
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		for _, r := range d.redactors {
+			r(item)
+		}
+	}
+
+	return v
+}
+
+// summariseBatchGet lists the tables, key counts and IDs of a BatchGetItemInput.
+func summariseBatchGet(in *dynamodb.BatchGetItemInput) string {
+	tables := []string{}
+	for table := range in.RequestItems {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	parts := []string{}
+	for _, table := range tables {
+		ka := in.RequestItems[table]
+		parts = append(parts, fmt.Sprintf("%s: %d keys %s, consistent: %t, projection: %s",
+			table, len(ka.Keys), listIDs(ka.Keys), aws.BoolValue(ka.ConsistentRead), projectionNames(ka.ProjectionExpression, ka.ExpressionAttributeNames)))
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// summariseBatchGetOutput counts the items and unprocessed keys of a BatchGetItemOutput.
+func summariseBatchGetOutput(out *dynamodb.BatchGetItemOutput) string {
+	tables := []string{}
+	for table := range out.Responses {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	parts := []string{}
+	for _, table := range tables {
+		items := out.Responses[table]
+		parts = append(parts, fmt.Sprintf("%s: %d items %s", table, len(items), listIDs(items)))
+	}
+
+	tables = tables[:0]
+	for table := range out.UnprocessedKeys {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	for _, table := range tables {
+		ka := out.UnprocessedKeys[table]
+		parts = append(parts, fmt.Sprintf("%s: %d unprocessed keys %s", table, len(ka.Keys), listIDs(ka.Keys)))
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// summariseBatchWrite counts the puts and deletes in a set of write requests.
+func summariseBatchWrite(requests map[string][]*dynamodb.WriteRequest) string {
+	tables := []string{}
+	for table := range requests {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	parts := []string{}
+	for _, table := range tables {
+		wr := requests[table]
+		puts, deletes := []map[string]*dynamodb.AttributeValue{}, []map[string]*dynamodb.AttributeValue{}
+		for _, r := range wr {
+			if r.PutRequest != nil {
+				puts = append(puts, r.PutRequest.Item)
+			}
+			if r.DeleteRequest != nil {
+				deletes = append(deletes, r.DeleteRequest.Key)
+			}
+		}
+
+		parts = append(parts, fmt.Sprintf("%s: %d puts %s, %d deletes %s", table, len(puts), listIDs(puts), len(deletes), listIDs(deletes)))
+	}
+
+	if len(parts) == 0 {
+		return "none"
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// summariseItems counts the items of a page of query or scan results.
+func summariseItems(items []map[string]*dynamodb.AttributeValue, scanned *int64, last map[string]*dynamodb.AttributeValue) string {
+	return fmt.Sprintf("%d items %s, %d scanned, more pages: %t", len(items), listIDs(items), aws.Int64Value(scanned), len(last) > 0)
+}
+
+// listIDs lists the IDs of the given items, up to maxDumpIDs of them.
+func listIDs(items []map[string]*dynamodb.AttributeValue) string {
+	ids := []string{}
+	for i, item := range items {
+		if i == maxDumpIDs {
+			ids = append(ids, fmt.Sprintf("+%d more", len(items)-maxDumpIDs))
+			break
+		}
+		if av, ok := item["ID"]; ok {
+			ids = append(ids, aws.StringValue(av.S))
+		}
+	}
+
+	return "[" + strings.Join(ids, " ") + "]"
+}
+
+// projectionNames resolves the aliases in a ProjectionExpression, or returns
+// "all" if there is none.
+func projectionNames(projection *string, names map[string]*string) string {
+	if projection == nil {
+		return "all"
+	}
+
+	attrs := []string{}
+	for _, alias := range strings.Split(*projection, ", ") {
+		if name, ok := names[alias]; ok {
+			alias = aws.StringValue(name)
+		}
+		attrs = append(attrs, alias)
+	}
+
+	return strings.Join(attrs, ", ")
+}
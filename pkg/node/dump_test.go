@@ -0,0 +1,212 @@
+package node
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/erumble/dynamo-playground/pkg/logger/loggertest"
+	"github.com/erumble/dynamo-playground/pkg/node/dynamotest"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRedactor(t *testing.T) {
+	// Record the Metadata each PutItem reaches DynamoDB with.
+	sent := []string{}
+	record := Intercept(func(operation string, input interface{}, next Invoker) (interface{}, error) {
+		if in, ok := input.(*dynamodb.PutItemInput); ok {
+			sent = append(sent, aws.StringValue(in.Item["Metadata"].S))
+		}
+		return next(operation, input)
+	})
+
+	log := loggertest.New()
+	c := NewClient(log, dynamotest.New(), "nodes", "parents", WithRedactor(RedactMetadataFields("password")), WithMiddleware(record))
+
+	metadata := `{"password":"hunter2","user":"alice"}`
+	if err := c.Put(Node{ID: "a", Metadata: metadata}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	logged := log.Entries().Contains("PutItemInput:\n")
+	if logged.Len() != 1 {
+		t.Fatalf("logged %d PutItemInputs, want 1", logged.Len())
+	}
+	if msg := logged.Messages()[0]; strings.Contains(msg, "hunter2") || !strings.Contains(msg, redacted) || !strings.Contains(msg, "alice") {
+		t.Errorf("logged %s, want only the password masked", msg)
+	}
+
+	// The request itself is left alone.
+	if len(sent) != 1 || sent[0] != metadata {
+		t.Errorf("sent Metadata %v, want %s", sent, metadata)
+	}
+	stored, err := c.Get("a")
+	if err != nil || stored.Metadata != metadata {
+		t.Errorf("Get = %+v, %v, want Metadata %s", stored, err, metadata)
+	}
+}
+
+func TestRedactAttributes(t *testing.T) {
+	item := map[string]*dynamodb.AttributeValue{
+		"ID":       {S: aws.String("a")},
+		"Metadata": {S: aws.String("secret")},
+	}
+
+	RedactAttributes("Metadata", "Missing")(item)
+
+	if aws.StringValue(item["Metadata"].S) != redacted || aws.StringValue(item["ID"].S) != "a" {
+		t.Errorf("item = %v, want only Metadata masked", item)
+	}
+	if _, ok := item["Missing"]; ok {
+		t.Error("RedactAttributes added a missing attribute")
+	}
+}
+
+// TestDumpLazy checks that requests are only formatted, and redacted, when
+// debug logging is enabled.
+func TestDumpLazy(t *testing.T) {
+	redactions := 0
+	count := func(map[string]*dynamodb.AttributeValue) { redactions++ }
+
+	log := loggertest.New()
+	log.Level().SetLevel(zapcore.InfoLevel)
+	c := NewClient(log, dynamotest.New(), "nodes", "parents", WithRedactor(count))
+
+	if err := c.Put(Node{ID: "a"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := c.Get("a"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if redactions != 0 {
+		t.Errorf("requests were formatted %d times at info level, want none", redactions)
+	}
+
+	log.Level().SetLevel(zapcore.DebugLevel)
+	if _, err := c.Get("a"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if redactions == 0 {
+		t.Error("requests were not formatted at debug level")
+	}
+}
+
+func TestDumpSize(t *testing.T) {
+	// Every é is 2 bytes, so an odd size falls in the middle of one.
+	input := &dynamodb.GetItemInput{Key: map[string]*dynamodb.AttributeValue{"ID": {S: aws.String(strings.Repeat("é", 100))}}}
+	full := lazyDump{value: input}.String()
+
+	for _, size := range []int{1, 51, 52, len(full) - 1} {
+		s := lazyDump{value: input, size: size}.String()
+
+		kept := s[:strings.LastIndex(s, "... (")]
+		if len(kept) > size || len(kept) < size-1 {
+			t.Errorf("size %d kept %d bytes", size, len(kept))
+		}
+		if !utf8.ValidString(s) {
+			t.Errorf("size %d cut a rune in half: %q", size, s)
+		}
+		if want := fmt.Sprintf("... (%d more bytes)", len(full)-len(kept)); !strings.HasPrefix(full, kept) || !strings.HasSuffix(s, want) {
+			t.Errorf("size %d = %q, want a prefix of the full dump followed by %q", size, s, want)
+		}
+	}
+
+	for _, size := range []int{0, -1, len(full)} {
+		if s := (lazyDump{value: input, size: size}).String(); s != full {
+			t.Errorf("size %d = %q, want it in full", size, s)
+		}
+	}
+
+	// WithDumpSize applies to what the Client logs.
+	log := loggertest.New()
+	c := NewClient(log, dynamotest.New(), "nodes", "parents", WithDumpSize(20))
+	if _, err := c.Get("a"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	logged := log.Entries().Contains("GetItemInput:\n")
+	if logged.Len() != 1 {
+		t.Fatalf("logged %d GetItemInputs, want 1", logged.Len())
+	}
+	dump := strings.TrimPrefix(logged.Messages()[0], "GetItemInput:\n")
+	if i := strings.Index(dump, "... ("); i < 0 || i > 20 {
+		t.Errorf("logged %q, want it cut off after 20 bytes", dump)
+	}
+}
+
+// items returns n items with the IDs prefix0, prefix1, and so on.
+func items(prefix string, n int) []map[string]*dynamodb.AttributeValue {
+	items := []map[string]*dynamodb.AttributeValue{}
+	for i := 0; i < n; i++ {
+		items = append(items, map[string]*dynamodb.AttributeValue{"ID": {S: aws.String(fmt.Sprintf("%s%d", prefix, i))}})
+	}
+
+	return items
+}
+
+func TestDumpBatches(t *testing.T) {
+	puts := []*dynamodb.WriteRequest{}
+	for _, item := range items("p", 15) {
+		puts = append(puts, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item}})
+	}
+	deletes := []*dynamodb.WriteRequest{}
+	for _, key := range items("d", 2) {
+		deletes = append(deletes, &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: key}})
+	}
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{
+			name:  "BatchWriteItemInput",
+			value: &dynamodb.BatchWriteItemInput{RequestItems: map[string][]*dynamodb.WriteRequest{"b": deletes, "a": puts}},
+			want: "a: 15 puts [p0 p1 p2 p3 p4 p5 p6 p7 p8 p9 +5 more], 0 deletes []\n" +
+				"b: 0 puts [], 2 deletes [d0 d1]",
+		},
+		{
+			name:  "BatchWriteItemOutput",
+			value: &dynamodb.BatchWriteItemOutput{},
+			want:  "UnprocessedItems: none",
+		},
+		{
+			name: "BatchGetItemInput",
+			value: &dynamodb.BatchGetItemInput{RequestItems: map[string]*dynamodb.KeysAndAttributes{
+				"b": {Keys: items("k", 1)},
+				"a": {Keys: items("k", 11), ConsistentRead: aws.Bool(true), ProjectionExpression: aws.String("#p0, Metadata"), ExpressionAttributeNames: map[string]*string{"#p0": aws.String("ID")}},
+			}},
+			want: "a: 11 keys [k0 k1 k2 k3 k4 k5 k6 k7 k8 k9 +1 more], consistent: true, projection: ID, Metadata\n" +
+				"b: 1 keys [k0], consistent: false, projection: all",
+		},
+		{
+			name: "BatchGetItemOutput",
+			value: &dynamodb.BatchGetItemOutput{
+				Responses:       map[string][]map[string]*dynamodb.AttributeValue{"c": items("i", 1), "a": items("i", 10)},
+				UnprocessedKeys: map[string]*dynamodb.KeysAndAttributes{"b": {Keys: items("u", 2)}, "a": {Keys: items("u", 1)}},
+			},
+			want: "a: 10 items [i0 i1 i2 i3 i4 i5 i6 i7 i8 i9]\n" +
+				"c: 1 items [i0]\n" +
+				"a: 1 unprocessed keys [u0]\n" +
+				"b: 2 unprocessed keys [u0 u1]",
+		},
+		{
+			name:  "QueryOutput",
+			value: &dynamodb.QueryOutput{Items: items("q", 12), ScannedCount: aws.Int64(20), LastEvaluatedKey: items("q", 1)[0]},
+			want:  "12 items [q0 q1 q2 q3 q4 q5 q6 q7 q8 q9 +2 more], 20 scanned, more pages: true",
+		},
+	}
+
+	for _, tt := range tests {
+		// Map order varies, so format each a few times.
+		for i := 0; i < 5; i++ {
+			if got := (lazyDump{value: tt.value}).String(); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,408 @@
+// Package dynamotest provides an in-memory fake of DynamoDB, to test code
+// that uses a node.Client without a table:
+//
+//	db := dynamotest.New()
+//	c := node.NewClient(logger, db, "nodes", "parents")
+package dynamotest
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/pkg/errors"
+)
+
+// Fake is an in-memory node.DynamoDBIFace holding a single table keyed by
+// ID. It understands just enough of the requests a node.Client makes to
+// test it without DynamoDB: projections, the conditions, filters and updates
+// described by expression, pagination, and queries of the form "#a = :v"
+// against a GSI. The ParentID index is sorted by Position, and like a GSI
+// leaves out items without one, any other index is sorted by ID.
+//
+// Its fields can be set between calls, but not while calls are in flight.
+type Fake struct {
+	// Unprocessed is the number of BatchGetItem and BatchWriteItem calls
+	// that leave their last key or item unprocessed, to exercise retries.
+	Unprocessed int
+
+	// PageSize, if positive, is the most items a Query or Scan reads, as if
+	// it reached DynamoDB's 1 MB limit, to exercise pagination.
+	PageSize int
+
+	// Fail holds the error returned by every call to the named operation.
+	Fail map[string]error
+
+	mu    sync.Mutex
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+// New returns an empty Fake.
+func New() *Fake {
+	return &Fake{
+		Fail:  map[string]error{},
+		items: map[string]map[string]*dynamodb.AttributeValue{},
+	}
+}
+
+// Len returns the number of items in the table.
+func (f *Fake) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.items)
+}
+
+// failure returns the error the named operation was set to fail with.
+func (f *Fake) failure(operation string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.Fail[operation]
+}
+
+var errUnsupported = errors.New("dynamotest: not supported")
+
+// errConditionFailed is what DynamoDB returns when a ConditionExpression is
+// not met.
+var errConditionFailed = awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+
+// check returns errConditionFailed if the item with the given ID does not
+// meet the condition, the caller must hold the lock.
+func (f *Fake) check(id string, condition *string, names map[string]*string, values map[string]*dynamodb.AttributeValue) error {
+	ok, err := evalCondition(condition, names, values, f.items[id])
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errConditionFailed
+	}
+
+	return nil
+}
+
+func (f *Fake) BatchGetItem(in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	if err := f.failure("BatchGetItem"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &dynamodb.BatchGetItemOutput{
+		Responses:       map[string][]map[string]*dynamodb.AttributeValue{},
+		UnprocessedKeys: map[string]*dynamodb.KeysAndAttributes{},
+	}
+
+	for table, ka := range in.RequestItems {
+		keys := ka.Keys
+		if f.Unprocessed > 0 && len(keys) > 0 {
+			f.Unprocessed--
+			left := *ka
+			left.Keys = keys[len(keys)-1:]
+			out.UnprocessedKeys[table] = &left
+			keys = keys[:len(keys)-1]
+		}
+
+		for _, key := range keys {
+			if item, ok := f.items[aws.StringValue(key["ID"].S)]; ok {
+				out.Responses[table] = append(out.Responses[table], projectItem(item, ka.ProjectionExpression, ka.ExpressionAttributeNames))
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func (f *Fake) BatchWriteItem(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	if err := f.failure("BatchWriteItem"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &dynamodb.BatchWriteItemOutput{
+		UnprocessedItems: map[string][]*dynamodb.WriteRequest{},
+	}
+
+	for table, wr := range in.RequestItems {
+		if f.Unprocessed > 0 && len(wr) > 0 {
+			f.Unprocessed--
+			out.UnprocessedItems[table] = wr[len(wr)-1:]
+			wr = wr[:len(wr)-1]
+		}
+
+		for _, r := range wr {
+			switch {
+			case r.PutRequest != nil:
+				f.put(r.PutRequest.Item)
+			case r.DeleteRequest != nil:
+				delete(f.items, aws.StringValue(r.DeleteRequest.Key["ID"].S))
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func (f *Fake) DeleteItem(in *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	if err := f.failure("DeleteItem"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := aws.StringValue(in.Key["ID"].S)
+	if err := f.check(id, in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
+
+	delete(f.items, id)
+
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (f *Fake) DescribeTable(*dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+	return nil, errUnsupported
+}
+
+func (f *Fake) GetItem(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	if err := f.failure("GetItem"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &dynamodb.GetItemOutput{}
+	if item, ok := f.items[aws.StringValue(in.Key["ID"].S)]; ok {
+		out.Item = projectItem(item, in.ProjectionExpression, in.ExpressionAttributeNames)
+	}
+
+	return out, nil
+}
+
+func (f *Fake) PutItem(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	if err := f.failure("PutItem"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.check(aws.StringValue(in.Item["ID"].S), in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
+
+	f.put(in.Item)
+
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *Fake) Query(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	if err := f.failure("Query"); err != nil {
+		return nil, err
+	}
+
+	if in.IndexName == nil {
+		return nil, errUnsupported
+	}
+
+	parts := strings.Split(aws.StringValue(in.KeyConditionExpression), " = ")
+	if len(parts) != 2 {
+		return nil, errUnsupported
+	}
+	attr := resolveName(parts[0], in.ExpressionAttributeNames)
+	value := in.ExpressionAttributeValues[parts[1]]
+
+	// Only the ParentID index has a range key.
+	sortBy := "ID"
+	if attr == "ParentID" {
+		sortBy = "Position"
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	candidates := []map[string]*dynamodb.AttributeValue{}
+	for _, item := range f.items {
+		if item[sortBy] == nil || !awsutil.DeepEqual(item[attr], value) {
+			continue
+		}
+		candidates = append(candidates, item)
+	}
+	sortItems(candidates, sortBy)
+
+	items, last, err := f.page(candidates, in.ExclusiveStartKey, in.Limit, in.FilterExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, attr, sortBy)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &dynamodb.QueryOutput{LastEvaluatedKey: last}
+	for _, item := range items {
+		out.Items = append(out.Items, projectItem(item, in.ProjectionExpression, in.ExpressionAttributeNames))
+	}
+
+	return out, nil
+}
+
+func (f *Fake) Scan(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	if err := f.failure("Scan"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	candidates := []map[string]*dynamodb.AttributeValue{}
+	for _, item := range f.items {
+		candidates = append(candidates, item)
+	}
+	sortItems(candidates, "ID")
+
+	items, last, err := f.page(candidates, in.ExclusiveStartKey, in.Limit, in.FilterExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &dynamodb.ScanOutput{LastEvaluatedKey: last}
+	for _, item := range items {
+		out.Items = append(out.Items, projectItem(item, in.ProjectionExpression, in.ExpressionAttributeNames))
+	}
+
+	return out, nil
+}
+
+func (f *Fake) UpdateItem(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	if err := f.failure("UpdateItem"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := aws.StringValue(in.Key["ID"].S)
+	if err := f.check(id, in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
+
+	// Like DynamoDB, updating an item that does not exist creates it.
+	item, ok := f.items[id]
+	if !ok {
+		item = in.Key
+	}
+	item = copyItem(item)
+
+	if err := evalUpdate(aws.StringValue(in.UpdateExpression), in.ExpressionAttributeNames, in.ExpressionAttributeValues, item); err != nil {
+		return nil, err
+	}
+	f.put(item)
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+// page returns the items of a Query or Scan that follow the exclusiveStartKey
+// and match the filter, reading at most limit or pageSize of them. If more
+// are left, it also returns the LastEvaluatedKey, made of the ID and the
+// given keys of the last item read. The caller must hold the lock.
+func (f *Fake) page(candidates []map[string]*dynamodb.AttributeValue, exclusiveStartKey map[string]*dynamodb.AttributeValue, limit *int64, filter *string, names map[string]*string, values map[string]*dynamodb.AttributeValue, keys ...string) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, error) {
+	if exclusiveStartKey != nil {
+		for i, item := range candidates {
+			if awsutil.DeepEqual(item["ID"], exclusiveStartKey["ID"]) {
+				candidates = candidates[i+1:]
+				break
+			}
+		}
+	}
+
+	// Like DynamoDB, the limit counts the items read, before filtering.
+	n := int(aws.Int64Value(limit))
+	if f.PageSize > 0 && (n <= 0 || f.PageSize < n) {
+		n = f.PageSize
+	}
+
+	var last map[string]*dynamodb.AttributeValue
+	if n > 0 && n < len(candidates) {
+		candidates = candidates[:n]
+		last = map[string]*dynamodb.AttributeValue{}
+		for _, key := range append([]string{"ID"}, keys...) {
+			last[key] = candidates[n-1][key]
+		}
+		last = copyItem(last)
+	}
+
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, item := range candidates {
+		ok, err := evalCondition(filter, names, values, item)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			items = append(items, item)
+		}
+	}
+
+	return items, last, nil
+}
+
+// sortItems sorts items by the given string attribute.
+func sortItems(items []map[string]*dynamodb.AttributeValue, attr string) {
+	sort.Slice(items, func(i, j int) bool {
+		return aws.StringValue(items[i][attr].S) < aws.StringValue(items[j][attr].S)
+	})
+}
+
+// put stores a copy of item, the caller must hold the lock.
+func (f *Fake) put(item map[string]*dynamodb.AttributeValue) {
+	f.items[aws.StringValue(item["ID"].S)] = copyItem(item)
+}
+
+// projectItem returns a copy of item, holding only the attributes in
+// projection, or all of them if it is nil.
+func projectItem(item map[string]*dynamodb.AttributeValue, projection *string, names map[string]*string) map[string]*dynamodb.AttributeValue {
+	out := copyItem(item)
+	if projection == nil {
+		return out
+	}
+
+	keep := map[string]bool{}
+	for _, name := range strings.Split(aws.StringValue(projection), ",") {
+		keep[resolveName(strings.TrimSpace(name), names)] = true
+	}
+
+	for attr := range out {
+		if !keep[attr] {
+			delete(out, attr)
+		}
+	}
+
+	return out
+}
+
+// resolveName returns the attribute name an alias like "#proj0" stands for.
+func resolveName(name string, names map[string]*string) string {
+	if alias, ok := names[name]; ok {
+		return aws.StringValue(alias)
+	}
+
+	return name
+}
+
+// copyItem deep copies item, so neither the caller nor the fake can change
+// what the other holds.
+func copyItem(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	out := map[string]*dynamodb.AttributeValue{}
+	for k, v := range item {
+		cp := &dynamodb.AttributeValue{}
+		awsutil.Copy(cp, v)
+		out[k] = cp
+	}
+
+	return out
+}
@@ -0,0 +1,363 @@
+package dynamotest
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/pkg/errors"
+)
+
+// expression evaluates the condition, filter and update expressions a
+// node.Client sends against a single item. It supports AND, OR, NOT,
+// parentheses, the comparison operators, attribute_exists,
+// attribute_not_exists, contains and begins_with in conditions, and SET with
+// list_append and if_not_exists, ADD of numbers, and REMOVE in updates.
+type expression struct {
+	expression string
+	tokens     []string
+	pos        int
+	names      map[string]*string
+	values     map[string]*dynamodb.AttributeValue
+	err        error
+}
+
+func newExpression(s string, names map[string]*string, values map[string]*dynamodb.AttributeValue) *expression {
+	return &expression{
+		expression: s,
+		tokens:     tokenize(s),
+		names:      names,
+		values:     values,
+	}
+}
+
+// evalCondition reports whether item, which is nil if it does not exist,
+// satisfies the condition or filter expression. A nil expression is always
+// satisfied.
+func evalCondition(condition *string, names map[string]*string, values map[string]*dynamodb.AttributeValue, item map[string]*dynamodb.AttributeValue) (bool, error) {
+	if condition == nil {
+		return true, nil
+	}
+
+	e := newExpression(*condition, names, values)
+	ok := e.or(item)
+	if e.pos < len(e.tokens) {
+		e.fail("unexpected %q", e.peek())
+	}
+
+	return ok, e.err
+}
+
+// evalUpdate applies the update expression to item, in place. Every value is
+// read from item as it was before the update, as in DynamoDB.
+func evalUpdate(update string, names map[string]*string, values map[string]*dynamodb.AttributeValue, item map[string]*dynamodb.AttributeValue) error {
+	e := newExpression(update, names, values)
+	old := copyItem(item)
+
+	for e.err == nil && e.pos < len(e.tokens) {
+		switch clause := e.next(); clause {
+		case "SET":
+			e.list(func() {
+				attr := e.path()
+				e.expect("=")
+				if v := e.setValue(old); v != nil {
+					item[attr] = v
+				}
+			})
+		case "ADD":
+			e.list(func() {
+				attr := e.path()
+				item[attr] = e.add(old[attr], e.operand(old))
+			})
+		case "REMOVE":
+			e.list(func() {
+				delete(item, e.path())
+			})
+		default:
+			e.fail("unsupported clause %q", clause)
+		}
+	}
+
+	return e.err
+}
+
+// tokenize splits an expression into names, placeholders, operators and
+// punctuation.
+func tokenize(s string) []string {
+	tokens := []string{}
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == ' ':
+			i++
+		case strings.IndexByte("(),", s[i]) >= 0:
+			tokens = append(tokens, s[i:i+1])
+			i++
+		default:
+			operator := strings.IndexByte("=<>", s[i]) >= 0
+			j := i + 1
+			for j < len(s) && s[j] != ' ' && strings.IndexByte("(),", s[j]) < 0 && (strings.IndexByte("=<>", s[j]) >= 0) == operator {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+
+	return tokens
+}
+
+func (e *expression) fail(format string, args ...interface{}) {
+	if e.err == nil {
+		e.err = errors.Wrapf(errUnsupported, "%q: %s", e.expression, errors.Errorf(format, args...))
+	}
+}
+
+func (e *expression) peek() string {
+	if e.pos >= len(e.tokens) {
+		return ""
+	}
+
+	return e.tokens[e.pos]
+}
+
+func (e *expression) next() string {
+	tok := e.peek()
+	if tok == "" {
+		e.fail("unexpected end")
+	}
+	e.pos++
+
+	return tok
+}
+
+func (e *expression) expect(tok string) {
+	if got := e.next(); got != tok {
+		e.fail("got %q, want %q", got, tok)
+	}
+}
+
+// list calls f for each of the comma separated items of a clause.
+func (e *expression) list(f func()) {
+	f()
+	for e.err == nil && e.peek() == "," {
+		e.next()
+		f()
+	}
+}
+
+func (e *expression) or(item map[string]*dynamodb.AttributeValue) bool {
+	ok := e.and(item)
+	for e.err == nil && e.peek() == "OR" {
+		e.next()
+		other := e.and(item)
+		ok = ok || other
+	}
+
+	return ok
+}
+
+func (e *expression) and(item map[string]*dynamodb.AttributeValue) bool {
+	ok := e.not(item)
+	for e.err == nil && e.peek() == "AND" {
+		e.next()
+		other := e.not(item)
+		ok = ok && other
+	}
+
+	return ok
+}
+
+func (e *expression) not(item map[string]*dynamodb.AttributeValue) bool {
+	if e.peek() == "NOT" {
+		e.next()
+		return !e.not(item)
+	}
+
+	return e.primary(item)
+}
+
+func (e *expression) primary(item map[string]*dynamodb.AttributeValue) bool {
+	if e.peek() == "(" {
+		e.next()
+		ok := e.or(item)
+		e.expect(")")
+		return ok
+	}
+
+	tok := e.next()
+	switch tok {
+	case "attribute_exists", "attribute_not_exists":
+		e.expect("(")
+		_, exists := item[e.path()]
+		e.expect(")")
+		return exists == (tok == "attribute_exists")
+
+	case "contains", "begins_with":
+		e.expect("(")
+		v := item[e.path()]
+		e.expect(",")
+		arg := e.operand(item)
+		e.expect(")")
+		if v == nil || arg == nil {
+			return false
+		}
+		if tok == "begins_with" {
+			return v.S != nil && arg.S != nil && strings.HasPrefix(*v.S, *arg.S)
+		}
+		return contains(v, arg)
+	}
+
+	left := e.valueOf(tok, item)
+	op := e.next()
+	right := e.operand(item)
+
+	return e.compare(left, op, right)
+}
+
+// path returns the attribute name the next token stands for.
+func (e *expression) path() string {
+	return resolveName(e.next(), e.names)
+}
+
+// operand returns the value the next token stands for, nil if it is an
+// attribute item does not have.
+func (e *expression) operand(item map[string]*dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	return e.valueOf(e.next(), item)
+}
+
+func (e *expression) valueOf(tok string, item map[string]*dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	if !strings.HasPrefix(tok, ":") {
+		return item[resolveName(tok, e.names)]
+	}
+
+	v, ok := e.values[tok]
+	if !ok {
+		e.fail("no value for %s", tok)
+	}
+
+	return v
+}
+
+// setValue evaluates the right hand side of a SET action.
+func (e *expression) setValue(item map[string]*dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	switch tok := e.next(); tok {
+	case "list_append":
+		e.expect("(")
+		a := e.setValue(item)
+		e.expect(",")
+		b := e.setValue(item)
+		e.expect(")")
+		if a == nil || b == nil {
+			e.fail("list_append of a missing attribute")
+			return nil
+		}
+		l := append([]*dynamodb.AttributeValue{}, a.L...)
+		return &dynamodb.AttributeValue{L: append(l, b.L...)}
+
+	case "if_not_exists":
+		e.expect("(")
+		v := item[e.path()]
+		e.expect(",")
+		def := e.setValue(item)
+		e.expect(")")
+		if v != nil {
+			return v
+		}
+		return def
+
+	default:
+		return e.valueOf(tok, item)
+	}
+}
+
+// add returns the result of an ADD action adding v to cur.
+func (e *expression) add(cur, v *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	if v == nil || v.N == nil {
+		e.fail("only numbers can be added")
+		return cur
+	}
+	if cur == nil {
+		return v
+	}
+
+	a, b := number(cur), number(v)
+	return &dynamodb.AttributeValue{N: aws.String(strconv.FormatFloat(a+b, 'f', -1, 64))}
+}
+
+// compare applies a comparison operator. Comparisons with an attribute the
+// item does not have are false.
+func (e *expression) compare(a *dynamodb.AttributeValue, op string, b *dynamodb.AttributeValue) bool {
+	if a == nil || b == nil {
+		return false
+	}
+
+	var cmp int
+	switch {
+	case a.N != nil && b.N != nil:
+		x, y := number(a), number(b)
+		switch {
+		case x < y:
+			cmp = -1
+		case x > y:
+			cmp = 1
+		}
+	case a.S != nil && b.S != nil:
+		cmp = strings.Compare(*a.S, *b.S)
+	case op == "=" || op == "<>":
+		if !awsutil.DeepEqual(a, b) {
+			cmp = 1
+		}
+	default:
+		e.fail("can't compare %v %s %v", a, op, b)
+		return false
+	}
+
+	switch op {
+	case "=":
+		return cmp == 0
+	case "<>":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+
+	e.fail("unsupported operator %q", op)
+	return false
+}
+
+// contains reports whether the string v contains the string arg, or the list
+// or set v holds arg.
+func contains(v, arg *dynamodb.AttributeValue) bool {
+	switch {
+	case v.S != nil:
+		return arg.S != nil && strings.Contains(*v.S, *arg.S)
+	case v.SS != nil:
+		for _, s := range v.SS {
+			if arg.S != nil && aws.StringValue(s) == *arg.S {
+				return true
+			}
+		}
+	case v.L != nil:
+		for _, elem := range v.L {
+			if awsutil.DeepEqual(elem, arg) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// number parses a number attribute, treating anything unparsable as 0.
+func number(v *dynamodb.AttributeValue) float64 {
+	f, _ := strconv.ParseFloat(aws.StringValue(v.N), 64)
+	return f
+}
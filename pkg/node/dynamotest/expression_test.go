@@ -0,0 +1,67 @@
+package dynamotest
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestExpression(t *testing.T) {
+	item := map[string]*dynamodb.AttributeValue{
+		"ID":         {S: aws.String("a")},
+		"ChildCount": {N: aws.String("2")},
+		"ChildIDs":   {L: []*dynamodb.AttributeValue{{S: aws.String("b")}, {S: aws.String("c")}}},
+	}
+	names := map[string]*string{"#count": aws.String("ChildCount")}
+	values := map[string]*dynamodb.AttributeValue{
+		":two": {N: aws.String("2")},
+		":ten": {N: aws.String("10")},
+		":b":   {S: aws.String("b")},
+		":a":   {S: aws.String("a")},
+	}
+
+	tests := []struct {
+		condition string
+		want      bool
+	}{
+		{"attribute_exists(ID)", true},
+		{"attribute_not_exists(Metadata)", true},
+		{"#count = :two AND ChildCount < :ten", true},
+		{"ChildCount > :ten OR contains(ChildIDs, :b)", true},
+		{"NOT contains(ChildIDs, :a)", true},
+		{"(ChildCount = :ten OR ID = :b) AND attribute_exists(ID)", false},
+		{"begins_with(ID, :a) AND Metadata = :a", false},
+	}
+
+	for _, tt := range tests {
+		got, err := evalCondition(aws.String(tt.condition), names, values, item)
+		if err != nil || got != tt.want {
+			t.Errorf("%s = %v, %v, want %v", tt.condition, got, err, tt.want)
+		}
+	}
+
+	if _, err := evalCondition(aws.String("ChildCount = :missing"), names, values, item); err == nil {
+		t.Error("a condition with an unknown value succeeded")
+	}
+
+	err := evalUpdate("ADD #count :two SET Metadata = if_not_exists(Metadata, :a), ChildIDs = list_append(ChildIDs, :ids) REMOVE ID", names,
+		map[string]*dynamodb.AttributeValue{
+			":two": {N: aws.String("2")},
+			":a":   {S: aws.String("a")},
+			":ids": {L: []*dynamodb.AttributeValue{{S: aws.String("d")}}},
+		}, item)
+	if err != nil {
+		t.Fatalf("evalUpdate: %v", err)
+	}
+
+	want := map[string]*dynamodb.AttributeValue{
+		"ChildCount": {N: aws.String("4")},
+		"Metadata":   {S: aws.String("a")},
+		"ChildIDs":   {L: []*dynamodb.AttributeValue{{S: aws.String("b")}, {S: aws.String("c")}, {S: aws.String("d")}}},
+	}
+	if !awsutil.DeepEqual(item, want) {
+		t.Errorf("updated item = %v, want %v", item, want)
+	}
+}
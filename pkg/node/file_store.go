@@ -0,0 +1,129 @@
+package node
+
+import (
+	"bufio"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// FileStore is a Store kept in a single NDJSON file, see WriteNDJSON. It is
+// not an embedded database like BoltDB or SQLite: every Node is held in
+// memory, and every write rewrites the whole file, so writes get slower as
+// the tree grows, and the tree must fit in memory. It suits small trees,
+// e.g. for local development. It is safe for concurrent use within a
+// process, but not across processes.
+type FileStore struct {
+	path string
+	mem  *MemoryStore
+}
+
+// OpenFileStore opens the FileStore kept at path, which is created on the
+// first write if it does not exist.
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, mem: NewMemoryStore()}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "OpenFileStore")
+	}
+	defer f.Close()
+
+	nodes, err := ReadNDJSON(bufio.NewReader(f))
+	if err != nil {
+		return nil, errors.Wrapf(err, "OpenFileStore: error reading %s", path)
+	}
+
+	s.mem.apply(nodes, nil)
+
+	return s, nil
+}
+
+// Get returns the Node with the given ID.
+func (s *FileStore) Get(id string, opts ...ReadOption) (*Node, error) {
+	return s.mem.Get(id, opts...)
+}
+
+// BatchGet returns the Nodes with the given IDs.
+func (s *FileStore) BatchGet(ids []string, opts ...ReadOption) ([]*Node, error) {
+	return s.mem.BatchGet(ids, opts...)
+}
+
+// GetChildren returns the children of n, in Position order.
+func (s *FileStore) GetChildren(n Node, opts ...ReadOption) ([]*Node, error) {
+	return s.mem.GetChildren(n, opts...)
+}
+
+// GetSiblings returns the children of the parent of n, in Position order.
+func (s *FileStore) GetSiblings(n Node, opts ...ReadOption) ([]*Node, error) {
+	return s.mem.GetSiblings(n, opts...)
+}
+
+// Put stores the given Node.
+func (s *FileStore) Put(in Node) error {
+	return s.BatchPut([]*Node{&in})
+}
+
+// BatchPut stores the given Nodes. Either all of them are stored, or none.
+func (s *FileStore) BatchPut(in []*Node) error {
+	if err := checkStorable(in); err != nil {
+		return err
+	}
+
+	return s.write(in, nil)
+}
+
+// Delete removes the given Node.
+func (s *FileStore) Delete(in *Node) error {
+	return s.write(nil, []string{in.ID})
+}
+
+// write applies the change in memory and saves the file, undoing the change
+// if the file cannot be saved.
+func (s *FileStore) write(put []*Node, del []string) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	undo := s.mem.apply(put, del)
+	if err := s.save(); err != nil {
+		undo()
+		return err
+	}
+
+	return nil
+}
+
+// save writes every Node to a temporary file and renames it over the
+// store's file, so a crash never leaves a truncated file behind. The caller
+// must hold the write lock.
+func (s *FileStore) save() error {
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return errors.Wrap(err, "error saving file store")
+	}
+
+	w := bufio.NewWriter(f)
+	if err := WriteNDJSON(w, s.mem.snapshot()); err != nil {
+		f.Close()
+		return errors.Wrap(err, "error saving file store")
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return errors.Wrap(err, "error saving file store")
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return errors.Wrap(err, "error saving file store")
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "error saving file store")
+	}
+
+	return errors.Wrap(os.Rename(tmp, s.path), "error saving file store")
+}
@@ -0,0 +1,318 @@
+package node
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/pkg/errors"
+)
+
+// Filter selects the Nodes FindInSubtree matches.
+type Filter struct {
+	// Expression, if set, is a DynamoDB filter expression that is pushed into
+	// each query of the GSI, e.g. "begins_with(#m, :prefix)". It should only
+	// use the placeholders in Names and Values. Names must start with # and
+	// Values with :, and neither can use the placeholders of the query
+	// itself: #pkey, :id, or anything starting with #proj, #find_ or :find_.
+	Expression string
+	Names      map[string]string
+	Values     map[string]interface{}
+
+	// Match, if set, is applied to every Node that passed the Expression, for
+	// anything the Expression can not express.
+	Match func(*Node) bool
+
+	// MaxDepth, if positive, is the deepest level searched, the root's
+	// children are at depth 1.
+	MaxDepth int
+
+	// Limit, if positive, is the most matches returned.
+	Limit int
+}
+
+// Matches iterates over the results of FindInSubtree, fetching them as they
+// are needed. It follows the same pattern as bufio.Scanner:
+//
+//	m := client.FindInSubtree(rootID, filter)
+//	for m.Next() {
+//		n := m.Node()
+//	}
+//	if err := m.Err(); err != nil {
+//	}
+type Matches struct {
+	client Client
+	filter Filter
+
+	// expression is the compiled Filter.Expression, nil if there is none.
+	expression *filterExpression
+
+	// frontier holds the Nodes whose children are still to be searched, and
+	// pending holds matches found but not returned yet.
+	frontier []searchNode
+	pending  []*Node
+
+	node  *Node
+	found int
+	err   error
+}
+
+type searchNode struct {
+	*Node
+	depth int
+}
+
+// FindInSubtree searches the descendants of the Node with the given ID, level
+// by level, for those that match the given Filter.
+//
+// Filter expressions reduce the data read from DynamoDB, but not the
+// capacity consumed, as DynamoDB applies them after reading. A Node that does
+// not match still has to be read if it has children, so the search can
+// continue below it. Those are fetched alongside the matches, and checked
+// against the expression with a second, keys only, query.
+func (c Client) FindInSubtree(rootID string, filter Filter) *Matches {
+	m := &Matches{client: c, filter: filter}
+
+	root, err := c.getExisting(rootID, StructureOnly())
+	if err != nil {
+		m.err = errors.Wrap(err, "Client.FindInSubtree: error fetching root")
+		return m
+	}
+
+	if filter.Expression != "" {
+		m.expression, err = compileFilter(filter)
+		if err != nil {
+			m.err = errors.Wrap(err, "Client.FindInSubtree")
+			return m
+		}
+	}
+
+	m.frontier = []searchNode{{Node: root}}
+
+	return m
+}
+
+// Next advances to the next match, which is then available through Node. It
+// returns false when there are no more matches, or an error occurred.
+func (m *Matches) Next() bool {
+	for m.err == nil {
+		if m.filter.Limit > 0 && m.found >= m.filter.Limit {
+			return false
+		}
+
+		if len(m.pending) > 0 {
+			m.node, m.pending = m.pending[0], m.pending[1:]
+			m.found++
+			return true
+		}
+
+		if len(m.frontier) == 0 {
+			return false
+		}
+
+		parent := m.frontier[0]
+		m.frontier = m.frontier[1:]
+		m.err = m.search(parent)
+	}
+
+	return false
+}
+
+// Node returns the most recent match.
+func (m *Matches) Node() *Node {
+	return m.node
+}
+
+// Err returns the error that stopped the search, if any.
+func (m *Matches) Err() error {
+	return m.err
+}
+
+// All returns every remaining match.
+func (m *Matches) All() ([]*Node, error) {
+	nodes := []*Node{}
+	for m.Next() {
+		nodes = append(nodes, m.Node())
+	}
+
+	return nodes, m.Err()
+}
+
+// search fetches the children of parent, queueing the matches and the
+// children to search next.
+func (m *Matches) search(parent searchNode) error {
+	if !parent.HasChildren() {
+		return nil
+	}
+
+	depth := parent.depth + 1
+	descend := m.filter.MaxDepth <= 0 || depth < m.filter.MaxDepth
+
+	children, matched, err := m.children(parent.Node, descend)
+	if err != nil {
+		return errors.Wrapf(err, "Client.FindInSubtree: error searching children of %s", parent.ID)
+	}
+
+	for _, child := range children {
+		if matched(child) && (m.filter.Match == nil || m.filter.Match(child)) {
+			m.pending = append(m.pending, child)
+		}
+
+		if descend && child.HasChildren() {
+			m.frontier = append(m.frontier, searchNode{Node: child, depth: depth})
+		}
+	}
+
+	return nil
+}
+
+// children fetches the children of parent that may be relevant, and returns
+// a function reporting whether each of them matched the filter expression.
+func (m *Matches) children(parent *Node, descend bool) ([]*Node, func(*Node) bool, error) {
+	all := func(*Node) bool { return true }
+
+	if m.expression == nil {
+		children, err := m.client.query(parent.ID, "ParentID")
+		return children, all, err
+	}
+
+	if !descend {
+		children, err := m.client.query(parent.ID, "ParentID", withFilter(m.expression))
+		return children, all, err
+	}
+
+	// Fetch the matches along with every child that has children, as the
+	// search has to continue below those whether they match or not.
+	children, err := m.client.query(parent.ID, "ParentID", withFilter(m.expression.or(hasChildrenFilter())))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	internal := false
+	for _, child := range children {
+		if child.HasChildren() {
+			internal = true
+			break
+		}
+	}
+
+	// Children without children of their own were only returned because they
+	// matched, the others need to be checked.
+	if !internal {
+		return children, all, nil
+	}
+
+	internalMatches, err := m.client.query(parent.ID, "ParentID", KeysOnly(), withFilter(m.expression.and(hasChildrenFilter())))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ids := map[string]bool{}
+	for _, n := range internalMatches {
+		ids[n.ID] = true
+	}
+
+	return children, func(n *Node) bool { return !n.HasChildren() || ids[n.ID] }, nil
+}
+
+// compileFilter marshals the names and values of the Filter's expression,
+// rejecting placeholders that would replace those of the query.
+func compileFilter(f Filter) (*filterExpression, error) {
+	fe := &filterExpression{
+		expression: f.Expression,
+		names:      map[string]*string{},
+		values:     map[string]*dynamodb.AttributeValue{},
+	}
+
+	for k, v := range f.Names {
+		if err := checkPlaceholder(k, "#"); err != nil {
+			return nil, err
+		}
+		fe.names[k] = aws.String(v)
+	}
+
+	for k, v := range f.Values {
+		if err := checkPlaceholder(k, ":"); err != nil {
+			return nil, err
+		}
+
+		av, err := dynamodbattribute.Marshal(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error marshalling filter value %s", k)
+		}
+		fe.values[k] = av
+	}
+
+	return fe, nil
+}
+
+var (
+	// reservedPlaceholders are used by query, reservedPrefixes start the
+	// placeholders of projections and hasChildrenFilter.
+	reservedPlaceholders = []string{"#pkey", ":id"}
+	reservedPrefixes     = []string{"#proj", "#find_", ":find_"}
+)
+
+// checkPlaceholder returns an error if p does not start with prefix, or is
+// one of the reservedPlaceholders or starts with one of the reservedPrefixes.
+func checkPlaceholder(p, prefix string) error {
+	if !strings.HasPrefix(p, prefix) || len(p) == len(prefix) {
+		return errors.Errorf("filter placeholder %q must start with %s", p, prefix)
+	}
+
+	for _, r := range reservedPlaceholders {
+		if p == r {
+			return errors.Errorf("filter placeholder %q is reserved", p)
+		}
+	}
+
+	for _, r := range reservedPrefixes {
+		if strings.HasPrefix(p, r) {
+			return errors.Errorf("filter placeholder %q is reserved", p)
+		}
+	}
+
+	return nil
+}
+
+// hasChildrenFilter matches Nodes that have children, however they are stored.
+func hasChildrenFilter() *filterExpression {
+	return &filterExpression{
+		expression: "(attribute_exists(#find_childIDs) OR #find_childCount > :find_zero)",
+		names: map[string]*string{
+			"#find_childIDs":   aws.String("ChildIDs"),
+			"#find_childCount": aws.String("ChildCount"),
+		},
+		values: map[string]*dynamodb.AttributeValue{
+			":find_zero": {N: aws.String("0")},
+		},
+	}
+}
+
+func (f *filterExpression) or(other *filterExpression) *filterExpression {
+	return f.combine("OR", other)
+}
+
+func (f *filterExpression) and(other *filterExpression) *filterExpression {
+	return f.combine("AND", other)
+}
+
+func (f *filterExpression) combine(op string, other *filterExpression) *filterExpression {
+	c := &filterExpression{
+		expression: "(" + f.expression + ") " + op + " " + other.expression,
+		names:      map[string]*string{},
+		values:     map[string]*dynamodb.AttributeValue{},
+	}
+
+	for _, fe := range []*filterExpression{f, other} {
+		for k, v := range fe.names {
+			c.names[k] = v
+		}
+		for k, v := range fe.values {
+			c.values[k] = v
+		}
+	}
+
+	return c
+}
@@ -0,0 +1,201 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/erumble/dynamo-playground/pkg/logger/loggertest"
+	"github.com/erumble/dynamo-playground/pkg/node/dynamotest"
+	"github.com/pkg/errors"
+)
+
+func TestCompileFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		names  map[string]string
+		values map[string]interface{}
+		ok     bool
+	}{
+		{"valid", map[string]string{"#m": "Metadata"}, map[string]interface{}{":prefix": "a"}, true},
+		{"key name", map[string]string{"#pkey": "Metadata"}, nil, false},
+		{"key value", nil, map[string]interface{}{":id": "a"}, false},
+		{"projection name", map[string]string{"#proj0": "Metadata"}, nil, false},
+		{"internal name", map[string]string{"#find_childIDs": "Metadata"}, nil, false},
+		{"internal value", nil, map[string]interface{}{":find_zero": 1}, false},
+		{"name without #", map[string]string{"m": "Metadata"}, nil, false},
+		{"value without :", nil, map[string]interface{}{"prefix": "a"}, false},
+		{"bare prefix", map[string]string{"#": "Metadata"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		_, err := compileFilter(Filter{Expression: "x", Names: tt.names, Values: tt.values})
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: compileFilter error = %v, want ok %t", tt.name, err, tt.ok)
+		}
+	}
+}
+
+// recordQueries returns a middleware that records the ID each Query is for.
+func recordQueries(ids *[]string) Middleware {
+	return Intercept(func(operation string, input interface{}, next Invoker) (interface{}, error) {
+		if q, ok := input.(*dynamodb.QueryInput); ok {
+			*ids = append(*ids, aws.StringValue(q.ExpressionAttributeValues[":id"].S))
+		}
+		return next(operation, input)
+	})
+}
+
+func TestFindInSubtree(t *testing.T) {
+	metadata := func(prefix string) Filter {
+		return Filter{
+			Expression: "begins_with(#m, :prefix)",
+			Names:      map[string]string{"#m": "Metadata"},
+			Values:     map[string]interface{}{":prefix": prefix},
+		}
+	}
+	withMatch := func(f Filter, match func(*Node) bool) Filter {
+		f.Match = match
+		return f
+	}
+	withDepth := func(f Filter, depth int) Filter {
+		f.MaxDepth = depth
+		return f
+	}
+	notA2 := func(n *Node) bool { return n.Metadata != "a2" }
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"everything", Filter{}, []string{"a", "b", "a1", "a2"}},
+		{"match", Filter{Match: notA2}, []string{"a", "b", "a1"}},
+		{"expression", metadata("a"), []string{"a", "a1", "a2"}},
+		{"below a non-match", metadata("a2"), []string{"a2"}},
+		{"leaf", metadata("b"), []string{"b"}},
+		{"nothing", metadata("c"), []string{}},
+		{"expression and match", withMatch(metadata("a"), notA2), []string{"a", "a1"}},
+		{"max depth", Filter{MaxDepth: 1}, []string{"a", "b"}},
+		{"max depth expression", withDepth(metadata("a"), 1), []string{"a"}},
+		{"deeper than the tree", Filter{MaxDepth: 5}, []string{"a", "b", "a1", "a2"}},
+		{"limit", Filter{Limit: 3}, []string{"a", "b", "a1"}},
+	}
+
+	for _, cs := range childStorages {
+		for _, tt := range tests {
+			t.Run(cs.name+"/"+tt.name, func(t *testing.T) {
+				c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents", WithChildStorage(cs.storage))
+				nodes := storeTree(t, c)
+
+				matches, err := c.FindInSubtree(nodes[0].ID, tt.filter).All()
+				if err != nil {
+					t.Fatalf("FindInSubtree: %v", err)
+				}
+
+				got := []string{}
+				for _, n := range matches {
+					got = append(got, n.Metadata)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("FindInSubtree = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+// TestFindInSubtreePrunes checks that FindInSubtree only queries for the
+// children of Nodes that have some, and not below MaxDepth.
+func TestFindInSubtreePrunes(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   func(nodes []*Node) []string
+	}{
+		{"leaves", Filter{}, func(nodes []*Node) []string { return []string{nodes[0].ID, nodes[1].ID} }},
+		{"max depth", Filter{MaxDepth: 1}, func(nodes []*Node) []string { return []string{nodes[0].ID} }},
+		{"max depth expression", Filter{MaxDepth: 1, Expression: "attribute_exists(Metadata)"}, func(nodes []*Node) []string { return []string{nodes[0].ID} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queried := []string{}
+			c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents", WithMiddleware(recordQueries(&queried)))
+			nodes := storeTree(t, c)
+
+			if _, err := c.FindInSubtree(nodes[0].ID, tt.filter).All(); err != nil {
+				t.Fatalf("FindInSubtree: %v", err)
+			}
+
+			if want := tt.want(nodes); !reflect.DeepEqual(queried, want) {
+				t.Errorf("FindInSubtree queried the children of %v, want %v", queried, want)
+			}
+		})
+	}
+}
+
+// TestMatchesStopsEarly checks that Matches only queries as far as the
+// matches asked for.
+func TestMatchesStopsEarly(t *testing.T) {
+	queried := []string{}
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents", WithMiddleware(recordQueries(&queried)))
+	nodes := storeTree(t, c)
+
+	m := c.FindInSubtree(nodes[0].ID, Filter{})
+	if len(queried) != 0 {
+		t.Errorf("FindInSubtree queried %v before Next", queried)
+	}
+
+	for _, want := range []string{"a", "b"} {
+		if !m.Next() {
+			t.Fatalf("Next = false, %v, want %s", m.Err(), want)
+		}
+		if m.Node().Metadata != want {
+			t.Errorf("Node = %s, want %s", m.Node().Metadata, want)
+		}
+	}
+	if !reflect.DeepEqual(queried, []string{nodes[0].ID}) {
+		t.Errorf("Matches queried the children of %v for the first level, want only the root", queried)
+	}
+
+	// The limit stops the search before the next level is queried.
+	queried = queried[:0]
+	m = c.FindInSubtree(nodes[0].ID, Filter{Limit: 2})
+	if got, err := m.All(); err != nil || len(got) != 2 {
+		t.Errorf("All = %d matches, %v, want 2", len(got), err)
+	}
+	if m.Next() {
+		t.Error("Next = true past the limit")
+	}
+	if !reflect.DeepEqual(queried, []string{nodes[0].ID}) {
+		t.Errorf("Matches queried the children of %v, want only the root", queried)
+	}
+}
+
+func TestFindInSubtreeErrors(t *testing.T) {
+	db := dynamotest.New()
+	c := NewClient(loggertest.Nop(), db, "nodes", "parents")
+	nodes := storeTree(t, c)
+
+	m := c.FindInSubtree("missing", Filter{})
+	if m.Next() || errors.Cause(m.Err()) != ErrNotFound {
+		t.Errorf("FindInSubtree of a missing root: Err = %v, want ErrNotFound", m.Err())
+	}
+
+	m = c.FindInSubtree(nodes[0].ID, Filter{Expression: "x", Names: map[string]string{"#pkey": "Metadata"}})
+	if m.Next() || m.Err() == nil {
+		t.Error("FindInSubtree with a reserved placeholder returned no error")
+	}
+
+	boom := errors.New("boom")
+	db.Fail["Query"] = boom
+	m = c.FindInSubtree(nodes[0].ID, Filter{})
+	if m.Next() || errors.Cause(m.Err()) != boom {
+		t.Errorf("FindInSubtree with a failing Query: Err = %v, want %v", m.Err(), boom)
+	}
+	if m.Next() {
+		t.Error("Next = true after an error")
+	}
+}
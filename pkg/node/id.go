@@ -0,0 +1,223 @@
+package node
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"math/big"
+	mathrand "math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IDGenerator generates the IDs of new Nodes. Implementations must be safe
+// for concurrent use.
+//
+// The generator can be chosen per tree, with NewWithGenerator or
+// Node.SetIDGenerator, or per Client, with WithIDGenerator, in which case
+// every Node the Client reads uses it for its children.
+type IDGenerator interface {
+	NewID() string
+}
+
+// DefaultIDGenerator is used by New when neither the parent nor the caller
+// provide an IDGenerator.
+var DefaultIDGenerator IDGenerator = UUIDGenerator{}
+
+// WithIDGenerator sets the IDGenerator of every Node the Client reads, and of
+// the Nodes it creates, e.g. the copies made by CopySubtree.
+func WithIDGenerator(gen IDGenerator) ClientOption {
+	return func(c *Client) {
+		c.idGen = gen
+	}
+}
+
+// UUIDGenerator generates random (version 4) UUIDs.
+type UUIDGenerator struct{}
+
+// NewID implements IDGenerator.
+func (UUIDGenerator) NewID() string {
+	return uuid.New().String()
+}
+
+// crockford is the Crockford base32 alphabet ULIDs are encoded with, it sorts
+// in the same order as the values it encodes.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator generates ULIDs, https://github.com/ulid/spec. They are 26
+// characters long and sort by the time they were generated, so Nodes sort in
+// creation order. IDs generated within the same millisecond are monotonic.
+//
+// If the random part can't be incremented any further within a millisecond,
+// NewID waits for the next one. If crypto/rand fails, the random part comes
+// from math/rand instead, which keeps IDs unique within the process, but
+// makes them easier to guess.
+//
+// The zero value is ready to use, and like NewULIDGenerator uses crypto/rand.
+type ULIDGenerator struct {
+	mu      sync.Mutex
+	entropy io.Reader
+	lastMS  uint64
+	last    [10]byte
+}
+
+// NewULIDGenerator creates a ULIDGenerator that uses crypto/rand for the
+// random part of each ID.
+func NewULIDGenerator() *ULIDGenerator {
+	return &ULIDGenerator{entropy: rand.Reader}
+}
+
+// NewID implements IDGenerator.
+func (g *ULIDGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := unixMS(time.Now())
+
+	if ms <= g.lastMS && increment(g.last[:]) {
+		// Same millisecond, or the clock went backwards, the random part of
+		// the previous ID was incremented so the order is kept.
+		ms = g.lastMS
+	} else {
+		// Rather than wrap around, wait for the clock to move on.
+		for ms <= g.lastMS {
+			time.Sleep(time.Millisecond)
+			ms = unixMS(time.Now())
+		}
+		entropy := g.entropy
+		if entropy == nil {
+			entropy = rand.Reader
+		}
+		readRandom(entropy, g.last[:])
+	}
+	g.lastMS = ms
+
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], ms<<16)
+	copy(b[6:], g.last[:])
+
+	return encodeULID(b)
+}
+
+func unixMS(t time.Time) uint64 {
+	return uint64(t.UnixNano() / int64(time.Millisecond))
+}
+
+// increment adds one to the big endian number in b, returning false if it
+// overflowed.
+func increment(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fallbackRandom is used by readRandom when the entropy source fails.
+var fallbackRandom = struct {
+	sync.Mutex
+	rnd *mathrand.Rand
+}{rnd: mathrand.New(mathrand.NewSource(time.Now().UnixNano()))}
+
+// readRandom fills b from r, or from math/rand if r fails, as an ID that is
+// easier to guess is better than no ID at all.
+func readRandom(r io.Reader, b []byte) {
+	if _, err := io.ReadFull(r, b); err == nil {
+		return
+	}
+
+	fallbackRandom.Lock()
+	defer fallbackRandom.Unlock()
+
+	fallbackRandom.rnd.Read(b)
+}
+
+// encodeULID encodes the 128 bits of a ULID as 26 base32 characters, the
+// first of which only holds 3 bits.
+func encodeULID(b [16]byte) string {
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(out)
+}
+
+// base62 is the alphabet KSUIDs are encoded with, it sorts in the same
+// order as the values it encodes.
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// ksuidEpoch is the start of KSUID time, in Unix seconds.
+const ksuidEpoch = 1400000000
+
+// KSUIDGenerator generates KSUIDs, https://github.com/segmentio/ksuid. They
+// are 27 characters long and sort by the second they were generated in, IDs
+// generated within the same second are in random order. Like ULIDGenerator,
+// it falls back to math/rand if crypto/rand fails.
+type KSUIDGenerator struct{}
+
+// NewID implements IDGenerator.
+func (KSUIDGenerator) NewID() string {
+	var payload [16]byte
+	readRandom(rand.Reader, payload[:])
+
+	return encodeKSUID(time.Now(), payload)
+}
+
+// encodeKSUID encodes the seconds since ksuidEpoch and the payload of a
+// KSUID as 27 base62 characters.
+func encodeKSUID(t time.Time, payload [16]byte) string {
+	var b [20]byte
+	binary.BigEndian.PutUint32(b[:4], uint32(t.Unix()-ksuidEpoch))
+	copy(b[4:], payload[:])
+
+	n := new(big.Int).SetBytes(b[:])
+	base, digit := big.NewInt(int64(len(base62))), new(big.Int)
+
+	out := []byte(strings.Repeat(base62[:1], 27))
+	for i := len(out) - 1; i >= 0 && n.Sign() > 0; i-- {
+		n.DivMod(n, base, digit)
+		out[i] = base62[digit.Int64()]
+	}
+
+	return string(out)
+}
+
+// DeterministicGenerator generates the same sequence of UUID formatted IDs
+// for the same seed, so tests can produce stable IDs and golden files.
+type DeterministicGenerator struct {
+	mu  sync.Mutex
+	rnd *mathrand.Rand
+}
+
+// NewDeterministicGenerator creates a DeterministicGenerator from the given seed.
+func NewDeterministicGenerator(seed int64) *DeterministicGenerator {
+	return &DeterministicGenerator{rnd: mathrand.New(mathrand.NewSource(seed))}
+}
+
+// NewID implements IDGenerator.
+func (g *DeterministicGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var b [16]byte
+	g.rnd.Read(b[:])
+
+	// Mark it as a version 4 UUID, like the ones UUIDGenerator generates.
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	id, _ := uuid.FromBytes(b[:])
+	return id.String()
+}
@@ -0,0 +1,160 @@
+package node
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIDGeneratorsUnique(t *testing.T) {
+	tests := []struct {
+		name   string
+		gen    IDGenerator
+		format *regexp.Regexp
+	}{
+		{"UUID", UUIDGenerator{}, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		{"ULID", NewULIDGenerator(), regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)},
+		{"KSUID", KSUIDGenerator{}, regexp.MustCompile(`^[0-9A-Za-z]{27}$`)},
+		{"Deterministic", NewDeterministicGenerator(1), regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			seen := map[string]bool{}
+
+			var wg sync.WaitGroup
+			for w := 0; w < 4; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < 2500; i++ {
+						id := tt.gen.NewID()
+
+						mu.Lock()
+						if seen[id] {
+							t.Errorf("%s generated twice", id)
+						}
+						seen[id] = true
+						mu.Unlock()
+
+						if !tt.format.MatchString(id) {
+							t.Errorf("%q is not formatted as a %s", id, tt.name)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestULIDGeneratorMonotonic(t *testing.T) {
+	gen := NewULIDGenerator()
+
+	ids := []string{}
+	for i := 0; i < 10000; i++ {
+		ids = append(ids, gen.NewID())
+	}
+
+	for i := 1; i < len(ids); i++ {
+		if ids[i-1] >= ids[i] {
+			t.Fatalf("ID %d %s does not sort after ID %d %s", i, ids[i], i-1, ids[i-1])
+		}
+	}
+}
+
+func TestULIDGeneratorOverflow(t *testing.T) {
+	gen := NewULIDGenerator()
+	previous := gen.NewID()
+
+	// Max out the random part, as if it was incremented 2^80 times.
+	gen.mu.Lock()
+	for i := range gen.last {
+		gen.last[i] = 0xff
+	}
+	gen.lastMS = unixMS(time.Now().Add(5 * time.Millisecond))
+	lastMS := gen.lastMS
+	gen.mu.Unlock()
+
+	id := gen.NewID()
+	if id <= previous {
+		t.Errorf("ID after overflow %s does not sort after %s", id, previous)
+	}
+	if gen.lastMS <= lastMS {
+		t.Errorf("ID after overflow has time %d, want after %d", gen.lastMS, lastMS)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("no entropy")
+}
+
+func TestULIDGeneratorEntropyFailure(t *testing.T) {
+	gen := &ULIDGenerator{entropy: failingReader{}}
+
+	a, b := gen.NewID(), gen.NewID()
+	time.Sleep(2 * time.Millisecond)
+	c := gen.NewID()
+
+	if !(a < b && b < c) {
+		t.Errorf("IDs without entropy are not in order: %s %s %s", a, b, c)
+	}
+}
+
+func TestKSUIDOrder(t *testing.T) {
+	payloads := [][16]byte{{}, {0x80}, {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}}
+	start := time.Unix(ksuidEpoch, 0)
+
+	ids := []string{}
+	for _, offset := range []time.Duration{0, time.Second, time.Hour, 24 * 365 * time.Hour} {
+		for _, p := range payloads {
+			ids = append(ids, encodeKSUID(start.Add(offset), p))
+		}
+	}
+
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("KSUIDs do not sort by time, then payload: %v", ids)
+	}
+
+	if ids[0] != "000000000000000000000000000" {
+		t.Errorf("the smallest KSUID is %s, want all zeros", ids[0])
+	}
+}
+
+func TestDeterministicGenerator(t *testing.T) {
+	a, b, c := NewDeterministicGenerator(1), NewDeterministicGenerator(1), NewDeterministicGenerator(2)
+
+	for i := 0; i < 100; i++ {
+		idA, idB, idC := a.NewID(), b.NewID(), c.NewID()
+		if idA != idB {
+			t.Fatalf("ID %d differs for the same seed: %s, %s", i, idA, idB)
+		}
+		if idA == idC {
+			t.Fatalf("ID %d is the same for different seeds: %s", i, idA)
+		}
+	}
+}
+
+func TestULIDGeneratorZeroValue(t *testing.T) {
+	// Swap crypto/rand for a known source, to tell it apart from the
+	// math/rand fallback.
+	reader := rand.Reader
+	defer func() { rand.Reader = reader }()
+	rand.Reader = bytes.NewReader(bytes.Repeat([]byte{0xff}, 10))
+
+	gen := &ULIDGenerator{}
+	id := gen.NewID()
+
+	if random := id[10:]; random != "ZZZZZZZZZZZZZZZZ" {
+		t.Errorf("random part of %s = %s, want it read from crypto/rand", id, random)
+	}
+}
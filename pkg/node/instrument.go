@@ -0,0 +1,192 @@
+package node
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/erumble/dynamo-playground/pkg/metrics"
+)
+
+// MetricsMiddleware reports the latency, outcome and consumed capacity of every
+// call to the given metrics.Recorder. NewClient installs it automatically when
+// WithMetrics is given.
+func MetricsMiddleware(r metrics.Recorder) Middleware {
+	return func(next DynamoDBIFace) DynamoDBIFace {
+		return instrumentedDataStore{next: next, metrics: r}
+	}
+}
+
+// instrumentedDataStore wraps a DynamoDBIFace, asking DynamoDB to report the
+// capacity consumed by every call and forwarding it, along with the latency
+// and outcome of the call, to a metrics.Recorder.
+type instrumentedDataStore struct {
+	next    DynamoDBIFace
+	metrics metrics.Recorder
+}
+
+func (s instrumentedDataStore) BatchGetItem(in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	if in.ReturnConsumedCapacity == nil {
+		in.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
+	}
+
+	start := time.Now()
+	out, err := s.next.BatchGetItem(in)
+	s.metrics.ObserveCall("BatchGetItem", time.Since(start), err)
+
+	if out != nil {
+		s.observeCapacity("BatchGetItem", out.ConsumedCapacity...)
+	}
+
+	return out, err
+}
+
+func (s instrumentedDataStore) BatchWriteItem(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	if in.ReturnConsumedCapacity == nil {
+		in.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
+	}
+
+	start := time.Now()
+	out, err := s.next.BatchWriteItem(in)
+	s.metrics.ObserveCall("BatchWriteItem", time.Since(start), err)
+
+	if out != nil {
+		s.observeCapacity("BatchWriteItem", out.ConsumedCapacity...)
+	}
+
+	return out, err
+}
+
+func (s instrumentedDataStore) DeleteItem(in *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	if in.ReturnConsumedCapacity == nil {
+		in.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
+	}
+
+	start := time.Now()
+	out, err := s.next.DeleteItem(in)
+	s.metrics.ObserveCall("DeleteItem", time.Since(start), err)
+
+	if out != nil {
+		s.observeCapacity("DeleteItem", out.ConsumedCapacity)
+	}
+
+	return out, err
+}
+
+// DescribeTable does not consume capacity, so only its latency and outcome
+// are recorded.
+func (s instrumentedDataStore) DescribeTable(in *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+	start := time.Now()
+	out, err := s.next.DescribeTable(in)
+	s.metrics.ObserveCall("DescribeTable", time.Since(start), err)
+
+	return out, err
+}
+
+func (s instrumentedDataStore) GetItem(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	if in.ReturnConsumedCapacity == nil {
+		in.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
+	}
+
+	start := time.Now()
+	out, err := s.next.GetItem(in)
+	s.metrics.ObserveCall("GetItem", time.Since(start), err)
+
+	if out != nil {
+		s.observeCapacity("GetItem", out.ConsumedCapacity)
+	}
+
+	return out, err
+}
+
+func (s instrumentedDataStore) PutItem(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	if in.ReturnConsumedCapacity == nil {
+		in.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
+	}
+
+	start := time.Now()
+	out, err := s.next.PutItem(in)
+	s.metrics.ObserveCall("PutItem", time.Since(start), err)
+
+	if out != nil {
+		s.observeCapacity("PutItem", out.ConsumedCapacity)
+	}
+
+	return out, err
+}
+
+func (s instrumentedDataStore) Query(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	if in.ReturnConsumedCapacity == nil {
+		in.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
+	}
+
+	start := time.Now()
+	out, err := s.next.Query(in)
+	s.metrics.ObserveCall("Query", time.Since(start), err)
+
+	if out != nil {
+		s.observeCapacity("Query", out.ConsumedCapacity)
+	}
+
+	return out, err
+}
+
+func (s instrumentedDataStore) Scan(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	if in.ReturnConsumedCapacity == nil {
+		in.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
+	}
+
+	start := time.Now()
+	out, err := s.next.Scan(in)
+	s.metrics.ObserveCall("Scan", time.Since(start), err)
+
+	if out != nil {
+		s.observeCapacity("Scan", out.ConsumedCapacity)
+	}
+
+	return out, err
+}
+
+func (s instrumentedDataStore) UpdateItem(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	if in.ReturnConsumedCapacity == nil {
+		in.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityIndexes)
+	}
+
+	start := time.Now()
+	out, err := s.next.UpdateItem(in)
+	s.metrics.ObserveCall("UpdateItem", time.Since(start), err)
+
+	if out != nil {
+		s.observeCapacity("UpdateItem", out.ConsumedCapacity)
+	}
+
+	return out, err
+}
+
+// observeCapacity breaks the consumed capacity reported by DynamoDB down into
+// the table and each of its indexes.
+func (s instrumentedDataStore) observeCapacity(operation string, ccs ...*dynamodb.ConsumedCapacity) {
+	for _, cc := range ccs {
+		if cc == nil {
+			continue
+		}
+
+		table := aws.StringValue(cc.TableName)
+
+		// The per table breakdown is only populated when INDEXES was requested,
+		// fall back to the total if the caller asked for something else.
+		if cc.Table != nil {
+			s.metrics.ObserveConsumedCapacity(operation, table, "", aws.Float64Value(cc.Table.CapacityUnits))
+		} else if cc.CapacityUnits != nil {
+			s.metrics.ObserveConsumedCapacity(operation, table, "", aws.Float64Value(cc.CapacityUnits))
+		}
+
+		for index, c := range cc.GlobalSecondaryIndexes {
+			s.metrics.ObserveConsumedCapacity(operation, table, index, aws.Float64Value(c.CapacityUnits))
+		}
+
+		for index, c := range cc.LocalSecondaryIndexes {
+			s.metrics.ObserveConsumedCapacity(operation, table, index, aws.Float64Value(c.CapacityUnits))
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/erumble/dynamo-playground/pkg/logger/loggertest"
+	"github.com/erumble/dynamo-playground/pkg/metrics"
+	"github.com/erumble/dynamo-playground/pkg/node/dynamotest"
+	"github.com/pkg/errors"
+)
+
+func TestClientMetrics(t *testing.T) {
+	rec := metrics.NewInMemory()
+	db := dynamotest.New()
+	c := NewClient(loggertest.Nop(), db, "nodes", "parents", WithMetrics(rec))
+
+	if err := c.Put(Node{ID: "a"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	db.Unprocessed = 1
+	if err := c.BatchPut([]*Node{{ID: "b"}, {ID: "c"}}); err != nil {
+		t.Fatalf("BatchPut: %v", err)
+	}
+
+	db.Fail["GetItem"] = errors.New("boom")
+	if _, err := c.Get("a"); err == nil {
+		t.Fatal("Get returned no error")
+	}
+
+	tests := []struct {
+		operation              string
+		calls, errors, retries int
+	}{
+		{"PutItem", 1, 0, 0},
+		{"BatchWriteItem", 2, 0, 1},
+		{"GetItem", 1, 1, 0},
+		{"Query", 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		if got := rec.Calls(tt.operation); got != tt.calls {
+			t.Errorf("Calls(%s) = %d, want %d", tt.operation, got, tt.calls)
+		}
+		if got := rec.Errors(tt.operation); got != tt.errors {
+			t.Errorf("Errors(%s) = %d, want %d", tt.operation, got, tt.errors)
+		}
+		if got := rec.Retries(tt.operation); got != tt.retries {
+			t.Errorf("Retries(%s) = %d, want %d", tt.operation, got, tt.retries)
+		}
+		if got := len(rec.Latencies(tt.operation)); got != tt.calls {
+			t.Errorf("Latencies(%s) has %d entries, want one per call", tt.operation, got)
+		}
+	}
+}
+
+func TestClientWithoutMetrics(t *testing.T) {
+	db := dynamotest.New()
+	c := NewClient(loggertest.Nop(), db, "nodes", "parents")
+
+	if c.dataStore != DynamoDBIFace(db) {
+		t.Errorf("NewClient without WithMetrics wrapped the DynamoDBIFace in %T", c.dataStore)
+	}
+}
+
+func TestMetricsMiddlewareCapacity(t *testing.T) {
+	rec := metrics.NewInMemory()
+
+	// DynamoDB reports the capacity consumed by the table and each index.
+	var requested *string
+	reportCapacity := Intercept(func(operation string, input interface{}, next Invoker) (interface{}, error) {
+		requested = input.(*dynamodb.QueryInput).ReturnConsumedCapacity
+		return &dynamodb.QueryOutput{
+			ConsumedCapacity: &dynamodb.ConsumedCapacity{
+				TableName: aws.String("nodes"),
+				Table:     &dynamodb.Capacity{CapacityUnits: aws.Float64(1)},
+				GlobalSecondaryIndexes: map[string]*dynamodb.Capacity{
+					"parents": {CapacityUnits: aws.Float64(2.5)},
+				},
+			},
+		}, nil
+	})
+
+	db := chain(dynamotest.New(), MetricsMiddleware(rec), reportCapacity)
+	for i := 0; i < 2; i++ {
+		if _, err := db.Query(&dynamodb.QueryInput{}); err != nil {
+			t.Fatalf("Query: %v", err)
+		}
+	}
+
+	if aws.StringValue(requested) != dynamodb.ReturnConsumedCapacityIndexes {
+		t.Errorf("ReturnConsumedCapacity = %q, want %q", aws.StringValue(requested), dynamodb.ReturnConsumedCapacityIndexes)
+	}
+	if got := rec.ConsumedCapacity("nodes", ""); got != 2 {
+		t.Errorf("table capacity = %v, want 2", got)
+	}
+	if got := rec.ConsumedCapacity("nodes", "parents"); got != 5 {
+		t.Errorf("index capacity = %v, want 5", got)
+	}
+	if got := rec.Calls("Query"); got != 2 {
+		t.Errorf("Calls(Query) = %d, want 2", got)
+	}
+}
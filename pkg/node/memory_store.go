@@ -0,0 +1,240 @@
+package node
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryStore is a Store that keeps Nodes in memory. It is safe for
+// concurrent use.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	nodes map[string]Node
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{nodes: map[string]Node{}}
+}
+
+// Get returns the Node with the given ID.
+func (s *MemoryStore) Get(id string, opts ...ReadOption) (*Node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n, ok := s.nodes[id]
+	if !ok {
+		return &Node{ID: id}, nil
+	}
+
+	return project(n, storeReadOptions(opts)), nil
+}
+
+// BatchGet returns the Nodes with the given IDs.
+func (s *MemoryStore) BatchGet(ids []string, opts ...ReadOption) ([]*Node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ro := storeReadOptions(opts)
+	seen := map[string]bool{}
+
+	nodes := []*Node{}
+	for _, id := range ids {
+		if n, ok := s.nodes[id]; ok && !seen[id] {
+			seen[id] = true
+			nodes = append(nodes, project(n, ro))
+		}
+	}
+
+	return nodes, nil
+}
+
+// GetChildren returns the children of n, in Position order.
+func (s *MemoryStore) GetChildren(n Node, opts ...ReadOption) ([]*Node, error) {
+	if !n.HasChildren() {
+		return []*Node{}, nil
+	}
+
+	return s.children(n.ID, storeReadOptions(opts)), nil
+}
+
+// GetSiblings returns the children of the parent of n, in Position order.
+func (s *MemoryStore) GetSiblings(n Node, opts ...ReadOption) ([]*Node, error) {
+	if !n.HasParent() {
+		return []*Node{}, nil
+	}
+
+	return s.children(n.ParentID, storeReadOptions(opts)), nil
+}
+
+// Put stores the given Node.
+func (s *MemoryStore) Put(in Node) error {
+	return s.BatchPut([]*Node{&in})
+}
+
+// BatchPut stores the given Nodes. Either all of them are stored, or none.
+func (s *MemoryStore) BatchPut(in []*Node) error {
+	if err := checkStorable(in); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apply(in, nil)
+
+	return nil
+}
+
+// Delete removes the given Node.
+func (s *MemoryStore) Delete(in *Node) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apply(nil, []string{in.ID})
+
+	return nil
+}
+
+// children returns the Nodes whose parent is parentID, ordered by Position
+// then ID.
+func (s *MemoryStore) children(parentID string, ro readOptions) []*Node {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	children := []Node{}
+	for _, n := range s.nodes {
+		if n.ParentID == parentID {
+			children = append(children, n)
+		}
+	}
+
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].Position != children[j].Position {
+			return children[i].Position < children[j].Position
+		}
+		return children[i].ID < children[j].ID
+	})
+
+	nodes := []*Node{}
+	for _, n := range children {
+		nodes = append(nodes, project(n, ro))
+	}
+
+	return nodes
+}
+
+// apply stores put and removes the Nodes with the IDs in del, returning a
+// function that undoes the change. The caller must hold the write lock.
+func (s *MemoryStore) apply(put []*Node, del []string) (undo func()) {
+	previous := map[string]*Node{}
+	remember := func(id string) {
+		if _, ok := previous[id]; ok {
+			return
+		}
+		if n, ok := s.nodes[id]; ok {
+			previous[id] = &n
+		} else {
+			previous[id] = nil
+		}
+	}
+
+	for _, n := range put {
+		remember(n.ID)
+		s.nodes[n.ID] = copyNode(*n)
+	}
+	for _, id := range del {
+		remember(id)
+		delete(s.nodes, id)
+	}
+
+	return func() {
+		for id, n := range previous {
+			if n == nil {
+				delete(s.nodes, id)
+			} else {
+				s.nodes[id] = *n
+			}
+		}
+	}
+}
+
+// snapshot returns every stored Node, ordered by ID. The caller must hold at
+// least a read lock.
+func (s *MemoryStore) snapshot() []*Node {
+	nodes := []*Node{}
+	for _, n := range s.nodes {
+		n := n
+		nodes = append(nodes, &n)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+
+	return nodes
+}
+
+// storeReadOptions applies the given ReadOptions for a Store that is always
+// consistent.
+func storeReadOptions(opts []ReadOption) readOptions {
+	o := readOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// checkStorable returns an error if any of the given Nodes cannot be stored.
+func checkStorable(nodes []*Node) error {
+	for _, n := range nodes {
+		if n.Partial {
+			return errPartialWrite(n.ID)
+		}
+		if err := n.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyNode returns a copy of n that shares nothing with it, as it would be
+// after a round trip through a database.
+func copyNode(n Node) Node {
+	if n.ChildIDs != nil {
+		n.ChildIDs = append([]string{}, n.ChildIDs...)
+	}
+	n.Partial = false
+	n.idGen = nil
+
+	return n
+}
+
+// project returns a copy of n with only the attributes in the read's
+// projection, as DynamoDB would return it.
+func project(n Node, ro readOptions) *Node {
+	n = copyNode(n)
+	if !ro.partial() {
+		return &n
+	}
+
+	p := &Node{ID: n.ID, Partial: true}
+	for _, attr := range ro.projection {
+		switch attr {
+		case "ParentID":
+			p.ParentID = n.ParentID
+		case "ChildIDs":
+			p.ChildIDs = n.ChildIDs
+		case "Metadata":
+			p.Metadata = n.Metadata
+		case "ChildCount":
+			p.ChildCount = n.ChildCount
+		case "Position":
+			p.Position = n.Position
+		case "LastChildPosition":
+			p.LastChildPosition = n.LastChildPosition
+		}
+	}
+
+	return p
+}
@@ -0,0 +1,217 @@
+package node
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/pkg/errors"
+)
+
+// MetadataIndex maps a GSI to a value derived from each Node's Metadata, so
+// Nodes can be looked up by business keys, e.g. an external ID or an owner.
+type MetadataIndex struct {
+	// Name is the name of the GSI.
+	Name string
+
+	// Attribute is the top level attribute the value is stored in, it must be
+	// the partition key of the GSI, as a string.
+	Attribute string
+
+	// Extract returns the value to index for the given Node, or false if the
+	// Node should not be indexed.
+	Extract func(*Node) (string, bool)
+}
+
+// WithMetadataIndex configures an additional GSI that can be queried with
+// FindBy. Every Node the Client stores has the index's Attribute set from
+// its Metadata. It can be given multiple times. The index must have a Name,
+// an Extract function, and an Attribute that is not one of the Node's own,
+// otherwise ValidateSchema reports it and every write fails.
+func WithMetadataIndex(idx MetadataIndex) ClientOption {
+	return func(c *Client) {
+		if c.metadataIndexes == nil {
+			c.metadataIndexes = map[string]MetadataIndex{}
+		}
+		c.metadataIndexes[idx.Name] = idx
+	}
+}
+
+// validate returns an error if the MetadataIndex can't be used: it lacks a
+// Name, an Attribute or an Extract function, or its Attribute would
+// overwrite one of the attributes a Node is stored with.
+func (idx MetadataIndex) validate() error {
+	switch {
+	case idx.Name == "":
+		return errors.Errorf("metadata index on attribute %q has no name", idx.Attribute)
+	case idx.Attribute == "":
+		return errors.Errorf("metadata index %s has no attribute", idx.Name)
+	case idx.Extract == nil:
+		return errors.Errorf("metadata index %s has no Extract function", idx.Name)
+	}
+
+	for _, attr := range nodeAttributes() {
+		if idx.Attribute == attr {
+			return errors.Errorf("metadata index %s can't use attribute %s, it is part of every Node", idx.Name, attr)
+		}
+	}
+
+	return nil
+}
+
+// nodeAttributes returns the names of the attributes a Node is stored with.
+func nodeAttributes() []string {
+	attrs := []string{}
+
+	t := reflect.TypeOf(Node{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(f.Tag.Get("dynamodbav"), ",")[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		attrs = append(attrs, name)
+	}
+
+	return attrs
+}
+
+// MetadataField returns an Extract function for a MetadataIndex that treats
+// the Metadata as a JSON object, and indexes the given top level field of it.
+// Nodes whose Metadata is not a JSON object, or lacks the field, are not indexed.
+func MetadataField(field string) func(*Node) (string, bool) {
+	return func(n *Node) (string, bool) {
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(n.Metadata), &fields); err != nil {
+			return "", false
+		}
+
+		v, ok := fields[field]
+		if !ok || v == nil {
+			return "", false
+		}
+
+		if s, ok := v.(string); ok {
+			return s, true
+		}
+
+		return fmt.Sprint(v), true
+	}
+}
+
+// Page is a single page of results.
+type Page struct {
+	Nodes []*Node
+
+	// Next is given to the next call to fetch the following page, it is empty
+	// when there are no more pages.
+	Next string
+}
+
+// FindBy fetches the Nodes whose value in the named MetadataIndex is value.
+// At most limit Nodes are returned, or a single page from DynamoDB when limit
+// is not positive. Pass the Next of the previous Page as from to continue
+// where it left off, or an empty string to start from the beginning.
+func (c Client) FindBy(indexName, value string, limit int, from string, opts ...ReadOption) (Page, error) {
+	log := c.log.Indent("FindBy")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	idx, ok := c.metadataIndexes[indexName]
+	if !ok {
+		return Page{}, errors.Errorf("Client.FindBy: unknown index %s", indexName)
+	}
+
+	log.Debug("generating QueryInput...")
+	ro := c.readOptions(opts)
+	projection, names := ro.projectionExpression()
+	if names == nil {
+		names = map[string]*string{}
+	}
+	names["#attr"] = aws.String(idx.Attribute)
+
+	input := &dynamodb.QueryInput{
+		ExpressionAttributeNames: names,
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":value": {S: aws.String(value)},
+		},
+		IndexName:              aws.String(idx.Name),
+		KeyConditionExpression: aws.String("#attr = :value"),
+		ProjectionExpression:   projection,
+		TableName:              aws.String(c.tableName),
+	}
+
+	if limit > 0 {
+		input.Limit = aws.Int64(int64(limit))
+	}
+
+	if from != "" {
+		key, err := decodePageToken(from)
+		if err != nil {
+			return Page{}, errors.Wrap(err, "Client.FindBy: invalid page token")
+		}
+		input.ExclusiveStartKey = key
+	}
+
+	log.Debugf("QueryInput:\n%v", c.dump(input))
+
+	log.Debug("calling Query...")
+	res, err := c.dataStore.Query(input)
+	if err != nil {
+		return Page{}, errors.Wrap(err, "Client.FindBy: error retrieving data from DynamoDB")
+	}
+
+	log.Debugf("QueryOutput:\n%v", c.dump(res))
+
+	nodes, err := c.unmarshalList(res.Items, ro.partial())
+	if err != nil {
+		return Page{}, errors.Wrap(err, "Client.FindBy: error unmarshalling results into type Node")
+	}
+
+	next, err := encodePageToken(res.LastEvaluatedKey)
+	if err != nil {
+		return Page{}, errors.Wrap(err, "Client.FindBy: error generating page token")
+	}
+
+	return Page{Nodes: nodes, Next: next}, nil
+}
+
+// encodePageToken encodes a LastEvaluatedKey as an opaque string.
+func encodePageToken(key map[string]*dynamodb.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	b, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodePageToken decodes a string from encodePageToken.
+func decodePageToken(token string) (map[string]*dynamodb.AttributeValue, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+
+	key := map[string]*dynamodb.AttributeValue{}
+	if err := json.Unmarshal(b, &key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
@@ -0,0 +1,48 @@
+package node
+
+import (
+	"testing"
+)
+
+func TestMetadataIndexValidate(t *testing.T) {
+	extract := MetadataField("owner")
+
+	tests := []struct {
+		name string
+		idx  MetadataIndex
+		ok   bool
+	}{
+		{"valid", MetadataIndex{Name: "owner-index", Attribute: "Owner", Extract: extract}, true},
+		{"no name", MetadataIndex{Attribute: "Owner", Extract: extract}, false},
+		{"no attribute", MetadataIndex{Name: "owner-index", Extract: extract}, false},
+		{"no Extract", MetadataIndex{Name: "owner-index", Attribute: "Owner"}, false},
+		{"ID", MetadataIndex{Name: "owner-index", Attribute: "ID", Extract: extract}, false},
+		{"ParentID", MetadataIndex{Name: "owner-index", Attribute: "ParentID", Extract: extract}, false},
+		{"Position", MetadataIndex{Name: "owner-index", Attribute: "Position", Extract: extract}, false},
+		{"ChildIDs", MetadataIndex{Name: "owner-index", Attribute: "ChildIDs", Extract: extract}, false},
+		{"ChildCount", MetadataIndex{Name: "owner-index", Attribute: "ChildCount", Extract: extract}, false},
+		{"Partial is not stored", MetadataIndex{Name: "owner-index", Attribute: "Partial", Extract: extract}, true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.idx.validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: validate() = %v, want ok %t", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestMarshalNodeRejectsInvalidIndex(t *testing.T) {
+	c := Client{}
+	WithMetadataIndex(MetadataIndex{Name: "owner-index", Attribute: "Owner"})(&c)
+
+	if _, err := c.marshalNode(&Node{ID: "a"}); err == nil {
+		t.Error("marshalNode with an index without Extract succeeded, want an error")
+	}
+
+	c = Client{}
+	WithMetadataIndex(MetadataIndex{Name: "parent-index", Attribute: "ParentID", Extract: MetadataField("parent")})(&c)
+
+	if _, err := c.marshalNode(&Node{ID: "a", ParentID: "p", Metadata: `{"parent":"q"}`}); err == nil {
+		t.Error("marshalNode with an index on ParentID succeeded, want an error")
+	}
+}
@@ -0,0 +1,133 @@
+package node
+
+import (
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Middleware decorates a DynamoDBIFace with cross-cutting behaviour, e.g.
+// logging, retries, fault injection or tracing. The returned DynamoDBIFace is
+// expected to eventually call through to next.
+type Middleware func(next DynamoDBIFace) DynamoDBIFace
+
+// Invoker performs the named DynamoDB operation with the given input, which is
+// one of the *dynamodb.<Operation>Input types. The output is the matching
+// *dynamodb.<Operation>Output type.
+type Invoker func(operation string, input interface{}) (interface{}, error)
+
+// Interceptor is called in place of every DynamoDB operation, it sees the
+// operation name and input, and decides whether, and how, to call next.
+type Interceptor func(operation string, input interface{}, next Invoker) (interface{}, error)
+
+// Intercept converts an Interceptor into a Middleware, so behaviour that does
+// not care about the specific operation can be written once.
+func Intercept(i Interceptor) Middleware {
+	return func(next DynamoDBIFace) DynamoDBIFace {
+		return interceptedDataStore{next: next, intercept: i}
+	}
+}
+
+// WithMiddleware wraps the DynamoDBIFace given to NewClient in the given
+// Middleware. The first Middleware is the outermost, i.e. the first to see a
+// call and the last to see its result. It can be given multiple times, later
+// Middleware are nested inside earlier ones.
+func WithMiddleware(mw ...Middleware) ClientOption {
+	return func(c *Client) {
+		c.middleware = append(c.middleware, mw...)
+	}
+}
+
+// chain wraps db in the given Middleware, the first being the outermost.
+func chain(db DynamoDBIFace, mw ...Middleware) DynamoDBIFace {
+	for i := len(mw) - 1; i >= 0; i-- {
+		db = mw[i](db)
+	}
+
+	return db
+}
+
+// interceptedDataStore adapts an Interceptor to the DynamoDBIFace interface.
+type interceptedDataStore struct {
+	next      DynamoDBIFace
+	intercept Interceptor
+}
+
+func (s interceptedDataStore) BatchGetItem(in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	out, err := s.intercept("BatchGetItem", in, func(_ string, in interface{}) (interface{}, error) {
+		return s.next.BatchGetItem(in.(*dynamodb.BatchGetItemInput))
+	})
+
+	res, _ := out.(*dynamodb.BatchGetItemOutput)
+	return res, err
+}
+
+func (s interceptedDataStore) BatchWriteItem(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	out, err := s.intercept("BatchWriteItem", in, func(_ string, in interface{}) (interface{}, error) {
+		return s.next.BatchWriteItem(in.(*dynamodb.BatchWriteItemInput))
+	})
+
+	res, _ := out.(*dynamodb.BatchWriteItemOutput)
+	return res, err
+}
+
+func (s interceptedDataStore) DeleteItem(in *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	out, err := s.intercept("DeleteItem", in, func(_ string, in interface{}) (interface{}, error) {
+		return s.next.DeleteItem(in.(*dynamodb.DeleteItemInput))
+	})
+
+	res, _ := out.(*dynamodb.DeleteItemOutput)
+	return res, err
+}
+
+func (s interceptedDataStore) DescribeTable(in *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+	out, err := s.intercept("DescribeTable", in, func(_ string, in interface{}) (interface{}, error) {
+		return s.next.DescribeTable(in.(*dynamodb.DescribeTableInput))
+	})
+
+	res, _ := out.(*dynamodb.DescribeTableOutput)
+	return res, err
+}
+
+func (s interceptedDataStore) GetItem(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	out, err := s.intercept("GetItem", in, func(_ string, in interface{}) (interface{}, error) {
+		return s.next.GetItem(in.(*dynamodb.GetItemInput))
+	})
+
+	res, _ := out.(*dynamodb.GetItemOutput)
+	return res, err
+}
+
+func (s interceptedDataStore) PutItem(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	out, err := s.intercept("PutItem", in, func(_ string, in interface{}) (interface{}, error) {
+		return s.next.PutItem(in.(*dynamodb.PutItemInput))
+	})
+
+	res, _ := out.(*dynamodb.PutItemOutput)
+	return res, err
+}
+
+func (s interceptedDataStore) Query(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	out, err := s.intercept("Query", in, func(_ string, in interface{}) (interface{}, error) {
+		return s.next.Query(in.(*dynamodb.QueryInput))
+	})
+
+	res, _ := out.(*dynamodb.QueryOutput)
+	return res, err
+}
+
+func (s interceptedDataStore) Scan(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	out, err := s.intercept("Scan", in, func(_ string, in interface{}) (interface{}, error) {
+		return s.next.Scan(in.(*dynamodb.ScanInput))
+	})
+
+	res, _ := out.(*dynamodb.ScanOutput)
+	return res, err
+}
+
+func (s interceptedDataStore) UpdateItem(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	out, err := s.intercept("UpdateItem", in, func(_ string, in interface{}) (interface{}, error) {
+		return s.next.UpdateItem(in.(*dynamodb.UpdateItemInput))
+	})
+
+	res, _ := out.(*dynamodb.UpdateItemOutput)
+	return res, err
+}
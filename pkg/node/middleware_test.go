@@ -0,0 +1,115 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/erumble/dynamo-playground/pkg/logger/loggertest"
+	"github.com/erumble/dynamo-playground/pkg/metrics"
+	"github.com/erumble/dynamo-playground/pkg/node/dynamotest"
+	"github.com/pkg/errors"
+)
+
+// recordCalls returns an Interceptor that appends name and the operation to
+// calls, before and after calling next.
+func recordCalls(name string, calls *[]string) Interceptor {
+	return func(operation string, input interface{}, next Invoker) (interface{}, error) {
+		*calls = append(*calls, name+" "+operation)
+		out, err := next(operation, input)
+		*calls = append(*calls, name+" done")
+		return out, err
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	calls := []string{}
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents",
+		WithMiddleware(Intercept(recordCalls("a", &calls)), Intercept(recordCalls("b", &calls))),
+		WithMiddleware(Intercept(recordCalls("c", &calls))),
+	)
+
+	if _, err := c.Get("x"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	want := []string{"a GetItem", "b GetItem", "c GetItem", "c done", "b done", "a done"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("middleware saw %v, want %v", calls, want)
+	}
+}
+
+func TestInterceptShortCircuit(t *testing.T) {
+	rec := metrics.NewInMemory()
+	db := dynamotest.New()
+	db.Fail["GetItem"] = errors.New("DynamoDB was called")
+
+	cached := Intercept(func(operation string, input interface{}, next Invoker) (interface{}, error) {
+		if operation != "GetItem" {
+			return next(operation, input)
+		}
+		return &dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{
+			"ID":       {S: aws.String("x")},
+			"Metadata": {S: aws.String("from the middleware")},
+		}}, nil
+	})
+
+	c := NewClient(loggertest.Nop(), db, "nodes", "parents", WithMiddleware(cached), WithMetrics(rec))
+
+	n, err := c.Get("x")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if n.Metadata != "from the middleware" {
+		t.Errorf("Get = %+v, want the Node returned by the middleware", n)
+	}
+
+	// Metrics sit inside the middleware, only calls that reach DynamoDB count.
+	if got := rec.Calls("GetItem"); got != 0 {
+		t.Errorf("Calls(GetItem) = %d, want 0", got)
+	}
+
+	if err := c.Put(Node{ID: "y"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := rec.Calls("PutItem"); got != 1 {
+		t.Errorf("Calls(PutItem) = %d, want the call passed through", got)
+	}
+}
+
+func TestInterceptError(t *testing.T) {
+	boom := errors.New("boom")
+	failing := Intercept(func(operation string, input interface{}, next Invoker) (interface{}, error) {
+		return nil, boom
+	})
+
+	db := chain(dynamotest.New(), failing)
+	if _, err := db.PutItem(&dynamodb.PutItemInput{}); err != boom {
+		t.Errorf("PutItem returned %v, want the interceptor's error", err)
+	}
+	if out, err := db.Scan(&dynamodb.ScanInput{}); out != nil || err != boom {
+		t.Errorf("Scan returned %v, %v, want no output and the interceptor's error", out, err)
+	}
+}
+
+func TestInterceptChangesInput(t *testing.T) {
+	seen := []bool{}
+	consistent := Intercept(func(operation string, input interface{}, next Invoker) (interface{}, error) {
+		input.(*dynamodb.GetItemInput).ConsistentRead = aws.Bool(true)
+		return next(operation, input)
+	})
+	record := Intercept(func(operation string, input interface{}, next Invoker) (interface{}, error) {
+		seen = append(seen, aws.BoolValue(input.(*dynamodb.GetItemInput).ConsistentRead))
+		return next(operation, input)
+	})
+
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents", WithMiddleware(consistent, record))
+	if _, err := c.Get("x"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	if !reflect.DeepEqual(seen, []bool{true}) {
+		t.Errorf("inner middleware saw ConsistentRead %v, want it set by the outer one", seen)
+	}
+}
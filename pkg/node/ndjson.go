@@ -0,0 +1,38 @@
+package node
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// WriteNDJSON writes the given nodes to w as newline delimited JSON, one
+// flat Node per line. It is the format nodes are exported to files in.
+func WriteNDJSON(w io.Writer, nodes []*Node) error {
+	enc := json.NewEncoder(w)
+	for _, n := range nodes {
+		if err := enc.Encode(n); err != nil {
+			return errors.Wrapf(err, "error encoding node %s", n.ID)
+		}
+	}
+
+	return nil
+}
+
+// ReadNDJSON reads nodes written by WriteNDJSON from r.
+func ReadNDJSON(r io.Reader) ([]*Node, error) {
+	dec := json.NewDecoder(r)
+
+	nodes := []*Node{}
+	for {
+		n := &Node{}
+		if err := dec.Decode(n); err == io.EOF {
+			return nodes, nil
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "error decoding node %d", len(nodes)+1)
+		}
+
+		nodes = append(nodes, n)
+	}
+}
@@ -0,0 +1,467 @@
+package node
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/pkg/errors"
+)
+
+// Nested JSON represents a tree as a single document, each Node holding its
+// children rather than their IDs:
+//
+//	{"id": "a", "metadata": "...", "children": [{"id": "b", "children": []}]}
+//
+// IDs are optional when decoding, and the document may also be an array of
+// such objects, each of which is a root. Metadata is a string in Node, if the
+// document holds any other JSON value there, its JSON text is kept instead.
+
+// nestedKeys are the keys of a Node in nested JSON.
+const (
+	nestedID       = "id"
+	nestedMetadata = "metadata"
+	nestedChildren = "children"
+)
+
+// EncodeNested writes the subtree rooted at root to w as nested JSON.
+// children is called to find the children of each Node as it is written, so
+// only the Nodes on the path from the root are held at once.
+func EncodeNested(w io.Writer, root *Node, children func(*Node) ([]*Node, error)) error {
+	bw := bufio.NewWriter(w)
+	if err := encodeNested(bw, root, children, map[string]bool{}); err != nil {
+		return err
+	}
+
+	if err := bw.WriteByte('\n'); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// encodeNested writes n and its descendants to w.
+func encodeNested(w *bufio.Writer, n *Node, children func(*Node) ([]*Node, error), seen map[string]bool) error {
+	// A corrupt table could contain a cycle, which would never end.
+	if seen[n.ID] {
+		return errors.Errorf("cycle detected at node %s", n.ID)
+	}
+	seen[n.ID] = true
+
+	id, err := json.Marshal(n.ID)
+	if err != nil {
+		return err
+	}
+	w.WriteString(`{"` + nestedID + `":`)
+	w.Write(id)
+
+	if n.Metadata != "" {
+		metadata, err := json.Marshal(n.Metadata)
+		if err != nil {
+			return err
+		}
+		w.WriteString(`,"` + nestedMetadata + `":`)
+		w.Write(metadata)
+	}
+
+	kids, err := children(n)
+	if err != nil {
+		return errors.Wrapf(err, "error fetching children of %s", n.ID)
+	}
+
+	w.WriteString(`,"` + nestedChildren + `":[`)
+	for i, child := range kids {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		if err := encodeNested(w, child, children, seen); err != nil {
+			return err
+		}
+	}
+	_, err = w.WriteString("]}")
+
+	return err
+}
+
+// WriteNested writes the subtree of t rooted at the Node with the given ID
+// to w as nested JSON.
+func WriteNested(w io.Writer, t *Tree, rootID string) error {
+	root, ok := t.Get(rootID)
+	if !ok {
+		return errors.Wrapf(ErrNotFound, "node %s is not in the tree", rootID)
+	}
+
+	return EncodeNested(w, root, func(n *Node) ([]*Node, error) {
+		return t.Children(n.ID), nil
+	})
+}
+
+// NestedDecoder reads nested JSON and turns it into flat Nodes, one at a
+// time, so documents larger than memory can be imported.
+type NestedDecoder struct {
+	dec  *json.Decoder
+	gen  IDGenerator
+	seen map[string]bool
+}
+
+// NewNestedDecoder returns a NestedDecoder reading from r, generating the
+// IDs missing from the document with gen, or DefaultIDGenerator if it is nil.
+func NewNestedDecoder(r io.Reader, gen IDGenerator) *NestedDecoder {
+	if gen == nil {
+		gen = DefaultIDGenerator
+	}
+
+	return &NestedDecoder{
+		dec:  json.NewDecoder(r),
+		gen:  gen,
+		seen: map[string]bool{},
+	}
+}
+
+// Decode reads the whole document, calling fn with every Node and its depth
+// below the root of its tree. Each Node is complete when fn is called, so
+// children come before their parents. The roots have no ParentID.
+// Children are decoded as they are read when the "id" of their parent comes
+// first, as EncodeNested writes it. Otherwise they are held in memory until
+// the end of their parent, when its ID is known.
+func (d *NestedDecoder) Decode(fn func(n *Node, depth int) error) error {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return errors.Wrap(err, "error reading nested JSON")
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		_, err = d.decodeNode(nil, 0, fn)
+		return err
+
+	case json.Delim('['):
+		for d.dec.More() {
+			if err := d.expect('{'); err != nil {
+				return err
+			}
+			if _, err := d.decodeNode(nil, 0, fn); err != nil {
+				return err
+			}
+		}
+		return d.expect(']')
+	}
+
+	return errors.Errorf("nested JSON must be an object or an array, found %v", tok)
+}
+
+// decodeNode reads the rest of a Node whose opening brace has been read.
+func (d *NestedDecoder) decodeNode(parent *Node, depth int, fn func(*Node, int) error) (*Node, error) {
+	n := &Node{ChildIDs: []string{}}
+	hasID, streamed := false, false
+	var buffered json.RawMessage
+
+	for d.dec.More() {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading nested JSON")
+		}
+
+		switch key, _ := tok.(string); key {
+		case nestedID:
+			if hasID {
+				return nil, errors.New(`"id" appears more than once in a node of nested JSON`)
+			}
+			hasID = true
+			if err := d.dec.Decode(&n.ID); err != nil {
+				return nil, errors.Wrap(err, "error decoding id")
+			}
+
+		case nestedMetadata:
+			raw := json.RawMessage{}
+			if err := d.dec.Decode(&raw); err != nil {
+				return nil, errors.Wrap(err, "error decoding metadata")
+			}
+			if err := json.Unmarshal(raw, &n.Metadata); err != nil {
+				n.Metadata = string(raw)
+			}
+
+		case nestedChildren:
+			if streamed || buffered != nil {
+				return nil, errors.New(`"children" appears more than once in a node of nested JSON`)
+			}
+
+			// Without an ID yet, the children can't be given their ParentID,
+			// keep them until the end of the Node.
+			if !hasID {
+				if err := d.dec.Decode(&buffered); err != nil {
+					return nil, errors.Wrap(err, "error decoding children")
+				}
+				continue
+			}
+
+			streamed = true
+			if err := d.assignID(n); err != nil {
+				return nil, err
+			}
+			if err := d.decodeChildren(n, depth, fn); err != nil {
+				return nil, err
+			}
+
+		default:
+			// Anything else is not part of a Node, skip it.
+			raw := json.RawMessage{}
+			if err := d.dec.Decode(&raw); err != nil {
+				return nil, errors.Wrapf(err, "error decoding %v", tok)
+			}
+		}
+	}
+
+	if err := d.expect('}'); err != nil {
+		return nil, err
+	}
+
+	if !streamed {
+		if err := d.assignID(n); err != nil {
+			return nil, err
+		}
+	}
+
+	if buffered != nil {
+		sub := &NestedDecoder{
+			dec:  json.NewDecoder(bytes.NewReader(buffered)),
+			gen:  d.gen,
+			seen: d.seen,
+		}
+		if err := sub.decodeChildren(n, depth, fn); err != nil {
+			return nil, err
+		}
+	}
+
+	if parent != nil {
+		if err := parent.RegisterChild(n); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := fn(n, depth); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// decodeChildren reads the children array of n.
+func (d *NestedDecoder) decodeChildren(n *Node, depth int, fn func(*Node, int) error) error {
+	if err := d.expect('['); err != nil {
+		return err
+	}
+
+	for d.dec.More() {
+		if err := d.expect('{'); err != nil {
+			return err
+		}
+		if _, err := d.decodeNode(n, depth+1, fn); err != nil {
+			return err
+		}
+	}
+
+	return d.expect(']')
+}
+
+// assignID generates an ID for n if the document did not have one, and
+// makes sure no two Nodes share an ID.
+func (d *NestedDecoder) assignID(n *Node) error {
+	if n.ID == "" {
+		n.ID = d.gen.NewID()
+	}
+
+	if d.seen[n.ID] {
+		return errors.Wrapf(ErrDuplicateChild, "node %s appears more than once", n.ID)
+	}
+	d.seen[n.ID] = true
+
+	return nil
+}
+
+// expect reads the next token, which must be the given delimiter.
+func (d *NestedDecoder) expect(delim json.Delim) error {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return errors.Wrap(err, "error reading nested JSON")
+	}
+
+	if tok != delim {
+		return errors.Errorf("expected %v in nested JSON, found %v", delim, tok)
+	}
+
+	return nil
+}
+
+// ReadNested reads a nested JSON document from r into flat Nodes, children
+// before their parents, see NestedDecoder.
+func ReadNested(r io.Reader, gen IDGenerator) ([]*Node, error) {
+	nodes := []*Node{}
+	err := NewNestedDecoder(r, gen).Decode(func(n *Node, _ int) error {
+		nodes = append(nodes, n)
+		return nil
+	})
+
+	return nodes, err
+}
+
+// ExportNested writes the subtree rooted at the Node with the given ID to w as
+// nested JSON, fetching the children of each Node as it goes. Any ReadOption
+// given must leave the ChildIDs or ChildCount in place, see GetSubtree.
+// It returns ErrNotFound if the root does not exist.
+func (c Client) ExportNested(w io.Writer, rootID string, opts ...ReadOption) error {
+	log := c.log.Indent("ExportNested")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	log.Debug("fetching root...")
+	root, err := c.getExisting(rootID, opts...)
+	if err != nil {
+		return errors.Wrap(err, "Client.ExportNested: error fetching root")
+	}
+
+	log.Debug("encoding subtree...")
+	err = EncodeNested(w, root, func(n *Node) ([]*Node, error) {
+		return c.GetChildren(*n, opts...)
+	})
+	if err != nil {
+		return errors.Wrap(err, "Client.ExportNested")
+	}
+
+	return nil
+}
+
+// ErrExists is returned by ImportNested when a Node in the document is
+// already stored, and ImportOptions.Overwrite is not set.
+var ErrExists = errors.New("node already exists")
+
+// ImportOptions configures ImportNested.
+type ImportOptions struct {
+	// Overwrite replaces stored Nodes with the same IDs as those in the
+	// document. Without it, ImportNested stops with ErrExists instead.
+	Overwrite bool
+}
+
+// ImportNested reads a nested JSON document from r and stores its Nodes in
+// batches as they are decoded. The roots of the document become the last
+// children of the Node with the given parentID, or roots themselves if it is
+// empty. IDs missing from the document come from the Client's IDGenerator.
+// Every Node is checked against Validate and the Client's Limits before it is
+// written, and unless opts.Overwrite is set, against the table to make sure
+// it is not stored yet. That check is made before each batch is written, so
+// it does not catch Nodes stored concurrently. Nodes written before an error
+// are left in place.
+// It returns the IDs of the document's roots, ErrNotFound if the parent does
+// not exist, and ErrExists if a Node would be overwritten.
+func (c Client) ImportNested(r io.Reader, parentID string, opts ImportOptions) ([]string, error) {
+	log := c.log.Indent("ImportNested")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	baseDepth := 0
+	if parentID != "" {
+		log.Debug("fetching ancestors of parent...")
+		ancestors, err := c.Ancestors(parentID)
+		if err != nil {
+			return nil, errors.Wrap(err, "Client.ImportNested: error fetching parent")
+		}
+		baseDepth = len(ancestors) + 1
+	}
+
+	wr := []*dynamodb.WriteRequest{}
+	ids := []string{}
+	flush := func() error {
+		if len(wr) == 0 {
+			return nil
+		}
+		if !opts.Overwrite {
+			if err := c.checkNew(ids); err != nil {
+				return err
+			}
+		}
+		err := c.batchWrite(wr)
+		wr, ids = wr[:0], ids[:0]
+		return err
+	}
+
+	roots := []*Node{}
+	gen := c.idGen
+	if gen == nil {
+		gen = DefaultIDGenerator
+	}
+
+	log.Debug("decoding and storing nodes...")
+	err := NewNestedDecoder(r, gen).Decode(func(n *Node, depth int) error {
+		if err := n.Validate(); err != nil {
+			return err
+		}
+		if err := c.limits.checkFanOut(n); err != nil {
+			return err
+		}
+		if err := c.limits.checkDepth(n.ID, baseDepth+depth); err != nil {
+			return err
+		}
+
+		if depth == 0 {
+			roots = append(roots, n)
+
+			// Roots under an existing parent are stored by AddChild, once
+			// their descendants are in place.
+			if parentID != "" {
+				if opts.Overwrite {
+					return nil
+				}
+				return c.checkNew([]string{n.ID})
+			}
+		}
+
+		av, err := c.marshalNode(n)
+		if err != nil {
+			return err
+		}
+		wr = append(wr, &dynamodb.WriteRequest{
+			PutRequest: &dynamodb.PutRequest{Item: av},
+		})
+		ids = append(ids, n.ID)
+
+		if len(wr) == maxBatchWriteItems {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Client.ImportNested")
+	}
+
+	rootIDs := []string{}
+	for _, root := range roots {
+		if parentID != "" {
+			log.Debugf("adding %s to parent...", root.ID)
+			if err := c.AddChild(parentID, root); err != nil {
+				return nil, errors.Wrap(err, "Client.ImportNested")
+			}
+		}
+		rootIDs = append(rootIDs, root.ID)
+	}
+
+	return rootIDs, nil
+}
+
+// checkNew returns ErrExists if any of the Nodes with the given IDs is stored.
+func (c Client) checkNew(ids []string) error {
+	existing, err := c.BatchGet(ids, KeysOnly())
+	if err != nil {
+		return errors.Wrap(err, "error checking for existing nodes")
+	}
+
+	if len(existing) > 0 {
+		return errors.Wrapf(ErrExists, "node %s", existing[0].ID)
+	}
+
+	return nil
+}
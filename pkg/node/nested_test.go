@@ -0,0 +1,266 @@
+package node
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/erumble/dynamo-playground/pkg/logger/loggertest"
+	"github.com/erumble/dynamo-playground/pkg/node/dynamotest"
+	"github.com/pkg/errors"
+)
+
+// decoded is a Node as Decode passed it to its callback.
+type decoded struct {
+	id, parentID string
+	depth        int
+	children     []string
+}
+
+func decodeAll(t *testing.T, doc string) ([]decoded, error) {
+	t.Helper()
+
+	nodes := []decoded{}
+	err := NewNestedDecoder(strings.NewReader(doc), NewDeterministicGenerator(1)).Decode(func(n *Node, depth int) error {
+		nodes = append(nodes, decoded{n.ID, n.ParentID, depth, append([]string{}, n.ChildIDs...)})
+		return nil
+	})
+
+	return nodes, err
+}
+
+func TestNestedDecoder(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want []decoded
+	}{
+		{
+			name: "id before children",
+			doc:  `{"id": "a", "children": [{"id": "b"}, {"id": "c", "children": [{"id": "d"}]}]}`,
+			want: []decoded{
+				{"b", "a", 1, []string{}},
+				{"d", "c", 2, []string{}},
+				{"c", "a", 1, []string{"d"}},
+				{"a", "", 0, []string{"b", "c"}},
+			},
+		},
+		{
+			name: "id after children",
+			doc:  `{"children": [{"children": [{"id": "d"}], "id": "c"}], "metadata": "m", "id": "a"}`,
+			want: []decoded{
+				{"d", "c", 2, []string{}},
+				{"c", "a", 1, []string{"d"}},
+				{"a", "", 0, []string{"c"}},
+			},
+		},
+		{
+			name: "array of roots",
+			doc:  `[{"id": "a"}, {"children": [{"id": "c"}], "id": "b"}]`,
+			want: []decoded{
+				{"a", "", 0, []string{}},
+				{"c", "b", 1, []string{}},
+				{"b", "", 0, []string{"c"}},
+			},
+		},
+		{
+			name: "unknown keys",
+			doc:  `{"id": "a", "extra": {"children": [1, 2]}, "children": []}`,
+			want: []decoded{
+				{"a", "", 0, []string{}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeAll(t, tt.doc)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Decode returned %d nodes, want %d: %v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				g, w := got[i], tt.want[i]
+				if g.id != w.id || g.parentID != w.parentID || g.depth != w.depth || !sameIDs(g.children, w.children) {
+					t.Errorf("node %d = %+v, want %+v", i, g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestNestedDecoderGeneratedIDs(t *testing.T) {
+	for _, doc := range []string{
+		`{"children": [{"metadata": "x"}]}`,
+		`{"metadata": "y", "children": [{}]}`,
+	} {
+		got, err := decodeAll(t, doc)
+		if err != nil {
+			t.Fatalf("Decode(%s): %v", doc, err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("Decode(%s) returned %d nodes, want 2", doc, len(got))
+		}
+
+		child, root := got[0], got[1]
+		if child.id == "" || root.id == "" || child.id == root.id {
+			t.Errorf("Decode(%s) generated IDs %q and %q", doc, child.id, root.id)
+		}
+		if child.parentID != root.id {
+			t.Errorf("Decode(%s) gave the child ParentID %q, want %q", doc, child.parentID, root.id)
+		}
+		if !sameIDs(root.children, []string{child.id}) {
+			t.Errorf("Decode(%s) gave the root ChildIDs %v, want [%s]", doc, root.children, child.id)
+		}
+	}
+}
+
+func TestNestedDecoderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"duplicate id", `{"id": "a", "children": [{"id": "a"}]}`},
+		{"duplicate id in buffered children", `{"children": [{"id": "a"}], "id": "a"}`},
+		{"id twice", `{"id": "a", "id": "b"}`},
+		{"children twice", `{"children": [], "children": []}`},
+		{"children not an array", `{"id": "a", "children": {}}`},
+		{"buffered children not an array", `{"children": "b", "id": "a"}`},
+		{"not an object", `"a"`},
+		{"truncated", `{"id": "a", "children": [`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeAll(t, tt.doc); err == nil {
+				t.Errorf("Decode(%s) returned no error", tt.doc)
+			}
+		})
+	}
+
+	_, err := decodeAll(t, `{"children": [{"id": "a"}], "id": "a"}`)
+	if errors.Cause(err) != ErrDuplicateChild {
+		t.Errorf("Decode of a duplicate ID returned %v, want ErrDuplicateChild", err)
+	}
+}
+
+func TestNestedRoundTrip(t *testing.T) {
+	root, children := family(1, 3)
+	root.Metadata = `{"a": 1}`
+	grandchild := children[1].CreateChild()
+	grandchild.Metadata = "leaf"
+
+	tree := NewTree(append([]*Node{root, grandchild}, children...))
+
+	buf := &bytes.Buffer{}
+	if err := WriteNested(buf, tree, root.ID); err != nil {
+		t.Fatalf("WriteNested: %v", err)
+	}
+
+	nodes, err := ReadNested(buf, nil)
+	if err != nil {
+		t.Fatalf("ReadNested: %v", err)
+	}
+
+	got := NewTree(nodes)
+	if got.Len() != tree.Len() {
+		t.Fatalf("read %d nodes, want %d", got.Len(), tree.Len())
+	}
+
+	for _, want := range []*Node{root, grandchild, children[0], children[1], children[2]} {
+		n, ok := got.Get(want.ID)
+		if !ok {
+			t.Errorf("node %s was not read", want.ID)
+			continue
+		}
+		if n.ParentID != want.ParentID || n.Metadata != want.Metadata || !sameIDs(n.ChildIDs, want.ChildIDs) {
+			t.Errorf("node %s = %+v, want %+v", want.ID, n, want)
+		}
+	}
+}
+
+func TestImportNested(t *testing.T) {
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents")
+	parent, _ := storeParent(t, c, 0)
+
+	doc := `[{"id": "a", "metadata": "a", "children": [{"id": "a1"}, {"id": "a2"}]}, {"id": "b"}]`
+
+	ids, err := c.ImportNested(strings.NewReader(doc), parent.ID, ImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportNested: %v", err)
+	}
+	if !sameIDs(ids, []string{"a", "b"}) {
+		t.Errorf("ImportNested = %v, want [a b]", ids)
+	}
+
+	checkChildren(t, c, parent.ID, []string{"a", "b"})
+	checkChildren(t, c, "a", []string{"a1", "a2"})
+
+	buf := &bytes.Buffer{}
+	if err := c.ExportNested(buf, "a"); err != nil {
+		t.Fatalf("ExportNested: %v", err)
+	}
+	nodes, err := ReadNested(buf, nil)
+	if err != nil {
+		t.Fatalf("ReadNested: %v", err)
+	}
+	if got := idsOf(nodes); !sameIDs(got, []string{"a1", "a2", "a"}) {
+		t.Errorf("exported %v, want [a1 a2 a]", got)
+	}
+
+	if _, err := c.ImportNested(strings.NewReader(doc), "missing", ImportOptions{}); errors.Cause(err) != ErrNotFound {
+		t.Errorf("ImportNested under a missing parent = %v, want ErrNotFound", err)
+	}
+}
+
+func TestImportNestedExisting(t *testing.T) {
+	tests := []struct {
+		name     string
+		parentID string
+		doc      string
+	}{
+		{"descendant", "", `{"id": "new", "children": [{"id": "a", "metadata": "imported"}]}`},
+		{"root", "", `{"id": "a", "metadata": "imported"}`},
+		{"root under a parent", "parent", `{"id": "a", "metadata": "imported"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, overwrite := range []bool{false, true} {
+				c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents")
+				if err := c.BatchPut([]*Node{{ID: "parent"}, {ID: "a", Metadata: "stored"}}); err != nil {
+					t.Fatalf("BatchPut: %v", err)
+				}
+
+				_, err := c.ImportNested(strings.NewReader(tt.doc), tt.parentID, ImportOptions{Overwrite: overwrite})
+
+				a, gerr := c.getExisting("a")
+				if gerr != nil {
+					t.Fatalf("Get: %v", gerr)
+				}
+
+				if !overwrite {
+					if errors.Cause(err) != ErrExists {
+						t.Errorf("ImportNested = %v, want ErrExists", err)
+					}
+					if a.Metadata != "stored" {
+						t.Errorf("stored Node has Metadata %q, want it left alone", a.Metadata)
+					}
+					continue
+				}
+
+				if err != nil {
+					t.Errorf("ImportNested with Overwrite: %v", err)
+				}
+				if a.Metadata != "imported" {
+					t.Errorf("stored Node has Metadata %q with Overwrite, want it replaced", a.Metadata)
+				}
+			}
+		})
+	}
+}
@@ -1,29 +1,67 @@
 package node
 
 import (
-	"github.com/google/uuid"
+	"github.com/pkg/errors"
 )
 
 // Node represents a recursive struct.
 type Node struct {
-	ID       string   `dynamodbav:"ID"`
-	ParentID string   `dynamodbav:",omitempty"`
-	ChildIDs []string `dynamodbav:",omitempty,omitemptyelem"`
-	Metadata string   `dynamodbav:",omitempty"`
+	ID       string   `dynamodbav:"ID" json:"id"`
+	ParentID string   `dynamodbav:",omitempty" json:"parent_id,omitempty"`
+	ChildIDs []string `dynamodbav:",omitempty,omitemptyelem" json:"child_ids,omitempty"`
+	Metadata string   `dynamodbav:",omitempty" json:"metadata,omitempty"`
+
+	// ChildCount is the number of children the Node has, it is kept even when
+	// ChildIDs is not stored, see IndexedChildren.
+	ChildCount int `dynamodbav:",omitempty" json:"child_count,omitempty"`
+
+	// Position orders the Node among its siblings, it is the range key of the
+	// ParentID GSI. It is assigned when the Node is registered as a child.
+	Position string `dynamodbav:",omitempty" json:"position,omitempty"`
+
+	// LastChildPosition is the highest Position given to any of the Node's
+	// children, including those since removed, so none is given out twice.
+	LastChildPosition string `dynamodbav:",omitempty" json:"last_child_position,omitempty"`
+
+	// Partial is true when the Node was fetched with a projection, any
+	// attribute that was not fetched holds its zero value.
+	Partial bool `dynamodbav:"-" json:"-"`
+
+	// idGen generates the IDs of the Node's children, nil means DefaultIDGenerator.
+	idGen IDGenerator
 }
 
 // New creates a new node.
 // If the parent param is non-nil, it will create a new child node for the parent.
+// The ID comes from the parent's IDGenerator, or DefaultIDGenerator.
 func New(parent *Node) *Node {
-	id := uuid.New().String()
+	return NewWithGenerator(parent, nil)
+}
+
+// NewWithGenerator creates a new node whose ID comes from gen, as do the IDs
+// of every node created under it with CreateChild. If gen is nil, the
+// parent's IDGenerator, or DefaultIDGenerator, is used instead.
+func NewWithGenerator(parent *Node, gen IDGenerator) *Node {
+	if gen == nil && parent != nil {
+		gen = parent.idGen
+	}
+
+	id := ""
+	if gen != nil {
+		id = gen.NewID()
+	} else {
+		id = DefaultIDGenerator.NewID()
+	}
 
 	n := &Node{
 		ID:       id,
 		ChildIDs: []string{},
+		idGen:    gen,
 	}
 
 	if parent != nil {
-		parent.RegisterChild(n)
+		// This can't error, the new node has a fresh ID and no parent.
+		_ = parent.RegisterChild(n)
 	}
 
 	return n
@@ -35,10 +73,145 @@ func (n *Node) CreateChild() *Node {
 	return New(n)
 }
 
-// RegisterChild adds the given Node to the receiver.
-func (n *Node) RegisterChild(c *Node) {
+// SetIDGenerator sets the IDGenerator used for the IDs of nodes created under
+// the receiver with CreateChild.
+func (n *Node) SetIDGenerator(gen IDGenerator) {
+	n.idGen = gen
+}
+
+// RegisterChild adds the given Node to the receiver, as its last child.
+// It returns ErrSelfParent if c is the receiver, ErrDuplicateChild if c is
+// already a child of the receiver, and ErrHasParent if c already has a
+// different parent, use Adopt to move it instead.
+func (n *Node) RegisterChild(c *Node) error {
+	if c.ID == n.ID {
+		return errors.Wrapf(ErrSelfParent, "node %s", n.ID)
+	}
+
+	for _, id := range n.ChildIDs {
+		if id == c.ID {
+			return errors.Wrapf(ErrDuplicateChild, "node %s is already a child of %s", c.ID, n.ID)
+		}
+	}
+
+	if c.ParentID != "" && c.ParentID != n.ID {
+		return errors.Wrapf(ErrHasParent, "node %s is a child of %s", c.ID, c.ParentID)
+	}
+
+	count := n.NumChildren()
+
+	c.Position = n.appendPosition()
 	n.ChildIDs = append(n.ChildIDs, c.ID)
+	n.ChildCount = count + 1
 	c.ParentID = n.ID
+
+	return nil
+}
+
+// appendPosition returns the Position of a child appended to the Node, after
+// that of any child it had before, and records it as the LastChildPosition.
+func (n *Node) appendPosition() string {
+	n.LastChildPosition = positionAfter(n.lastChildPosition())
+	return n.LastChildPosition
+}
+
+// notePosition records p as the LastChildPosition if it is the highest yet.
+func (n *Node) notePosition(p string) {
+	if last := n.lastChildPosition(); p < last {
+		p = last
+	}
+	n.LastChildPosition = p
+}
+
+// lastChildPosition returns the highest Position given to any of the Node's
+// children. A Node stored before LastChildPosition was kept is assumed to
+// have numbered its children sequentially, as RegisterChild did.
+func (n Node) lastChildPosition() string {
+	last := n.LastChildPosition
+	if count := n.NumChildren(); count > 0 && positionAt(count-1) > last {
+		last = positionAt(count - 1)
+	}
+
+	return last
+}
+
+// Adopt registers c as the receiver's last child, detaching it from
+// previous, its current parent, first. previous can be nil if c has no parent.
+// It is Reparent without the ChangeSet.
+func (n *Node) Adopt(c *Node, previous *Node) error {
+	_, err := c.Reparent(previous, n)
+	return err
+}
+
+// RemoveChild removes c from the receiver's children, leaving c without a
+// parent. Both Nodes are in the returned ChangeSet. It returns ErrNotChild if
+// c is not a child of the receiver.
+func (n *Node) RemoveChild(c *Node) (ChangeSet, error) {
+	i := indexOf(n.ChildIDs, c.ID)
+	if i < 0 && c.ParentID != n.ID {
+		return ChangeSet{}, errors.Wrapf(ErrNotChild, "node %s is not a child of %s", c.ID, n.ID)
+	}
+
+	// Record the highest Position before the count drops, so it is not
+	// given to the next child.
+	n.notePosition(c.Position)
+
+	count := n.NumChildren()
+	if i >= 0 {
+		ids := make([]string, 0, len(n.ChildIDs)-1)
+		ids = append(ids, n.ChildIDs[:i]...)
+		n.ChildIDs = append(ids, n.ChildIDs[i+1:]...)
+	}
+	if count > 0 {
+		n.ChildCount = count - 1
+	}
+
+	c.ParentID, c.Position = "", ""
+
+	cs := ChangeSet{}
+	cs.add(n, c)
+	cs.Reparented = []string{c.ID}
+
+	return cs, nil
+}
+
+// Detach removes the receiver from the children of parent, which must be its
+// current parent, see RemoveChild.
+func (n *Node) Detach(parent *Node) (ChangeSet, error) {
+	return parent.RemoveChild(n)
+}
+
+// Reparent moves the receiver from previous, its current parent, to the end
+// of the children of parent. previous can be nil if the receiver has no
+// parent. Nothing is changed if an error is returned. The ChangeSet holds
+// the receiver and both parents.
+func (n *Node) Reparent(previous, parent *Node) (ChangeSet, error) {
+	switch {
+	case previous == nil && n.ParentID != "":
+		return ChangeSet{}, errors.Wrapf(ErrHasParent, "node %s is a child of %s", n.ID, n.ParentID)
+	case previous != nil && previous.ID != n.ParentID:
+		return ChangeSet{}, errors.Wrapf(ErrNotChild, "node %s is not a child of %s", n.ID, previous.ID)
+	case parent.ID == n.ID:
+		return ChangeSet{}, errors.Wrapf(ErrSelfParent, "node %s", n.ID)
+	case previous != parent && indexOf(parent.ChildIDs, n.ID) >= 0:
+		return ChangeSet{}, errors.Wrapf(ErrDuplicateChild, "node %s is already a child of %s", n.ID, parent.ID)
+	}
+
+	cs := ChangeSet{}
+	if previous != nil {
+		removed, err := previous.RemoveChild(n)
+		if err != nil {
+			return ChangeSet{}, err
+		}
+		cs = cs.Merge(removed)
+	}
+
+	// This can't error, the receiver has no parent and was checked above.
+	_ = parent.RegisterChild(n)
+	cs.add(parent, n)
+	cs.Reparented = []string{n.ID}
+
+	return cs, nil
 }
 
 // HasParent returns true if the Node is a child Node.
@@ -48,5 +221,15 @@ func (n Node) HasParent() bool {
 
 // HasChildren returns true if the node has children.
 func (n Node) HasChildren() bool {
-	return len(n.ChildIDs) > 0
+	return n.NumChildren() > 0
+}
+
+// NumChildren returns the number of children the node has. It works whether
+// or not the node's ChildIDs were stored, or its ChildCount was maintained.
+func (n Node) NumChildren() int {
+	if len(n.ChildIDs) > n.ChildCount {
+		return len(n.ChildIDs)
+	}
+
+	return n.ChildCount
 }
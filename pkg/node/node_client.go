@@ -1,10 +1,15 @@
 package node
 
 import (
+	"context"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/erumble/dynamo-playground/pkg/logger"
+	"github.com/erumble/dynamo-playground/pkg/metrics"
+	"github.com/erumble/dynamo-playground/pkg/ratelimit"
 	"github.com/pkg/errors"
 )
 
@@ -13,20 +18,47 @@ type DynamoDBIFace interface {
 	BatchGetItem(*dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error)
 	BatchWriteItem(*dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
 	DeleteItem(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+	DescribeTable(*dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error)
 	GetItem(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
 	PutItem(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
 	Query(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	Scan(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+	UpdateItem(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
 }
 
 // Client provides the concrete implementation to interact with the DynamoDBIface.
 type Client struct {
 	dataStore DynamoDBIFace
 	log       logger.LeveledLogger
+	metrics   metrics.Recorder
+	limiter   *ratelimit.Limiter
+
+	middleware []Middleware
+
+	consistentReads bool
+	childStorage    ChildStorage
+	ancestors       *ancestorCache
+	metadataIndexes map[string]MetadataIndex
+	idGen           IDGenerator
+	limits          Limits
+	dumpSize        int
+	redactors       []Redactor
 
 	gsiName   string
 	tableName string
 }
 
+// ClientOption configures optional behaviour of a Client.
+type ClientOption func(*Client)
+
+// WithMetrics reports the latency, outcome, retries and consumed capacity of
+// every call the Client makes to DynamoDB to the given metrics.Recorder.
+func WithMetrics(r metrics.Recorder) ClientOption {
+	return func(c *Client) {
+		c.metrics = r
+	}
+}
+
 // NewClient creates a new Node Client to interact with DynamoDB.
 //
 // Parameters:
@@ -38,19 +70,81 @@ type Client struct {
 //                have a partition key set as ID, with no range key.
 //   - gsiName: The name of the GSI in for the given table in DynamoDB.
 //              The GSI should have the partition key set as ParentID, and the
-//              range key set as the ID, both are strings.
-func NewClient(logger logger.LeveledLogger, db DynamoDBIFace, tableName string, gsiName string) Client {
-	return Client{
+//              range key set as Position, both are strings. Children are
+//              returned in Position order, and nodes stored without a
+//              Position are not indexed, see BackfillPositions.
+//   - opts: Optional ClientOptions, e.g. WithMetrics, WithMiddleware or WithRateLimiter.
+func NewClient(logger logger.LeveledLogger, db DynamoDBIFace, tableName string, gsiName string, opts ...ClientOption) Client {
+	c := Client{
 		dataStore: db,
 		log:       logger.Indent("nodeClient"),
+		metrics:   metrics.Nop{},
+		ancestors: newAncestorCache(DefaultAncestorCacheTTL),
+		dumpSize:  DefaultDumpSize,
 		gsiName:   gsiName,
 		tableName: tableName,
 	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	// Rate limiting and metrics sit closest to DynamoDB, so every call that
+	// actually reaches it is metered and measured, regardless of what the
+	// middleware does. Neither is installed unless asked for.
+	mw := append([]Middleware{}, c.middleware...)
+	if c.limiter != nil {
+		mw = append(mw, RateLimitMiddleware(c.limiter))
+	}
+	if _, nop := c.metrics.(metrics.Nop); !nop {
+		mw = append(mw, MetricsMiddleware(c.metrics))
+	}
+
+	c.dataStore = chain(c.dataStore, mw...)
+
+	return c
+}
+
+// WithContext returns a copy of the Client that logs through the logger
+// carried by ctx, see logger.NewContext, so entries include the caller's
+// fields, e.g. a request ID. The Client is returned unchanged if ctx carries
+// no logger.
+func (c Client) WithContext(ctx context.Context) Client {
+	if l := logger.FromContext(ctx, nil); l != nil {
+		c.log = l.Indent("nodeClient")
+	}
+
+	return c
 }
 
+// ErrNotFound is returned when a Node that is required to exist is not in DynamoDB.
+var ErrNotFound = errors.New("node not found")
+
 // Get fetches the Node with the given ID from DynamoDB.
 // It does not fetch or associate child nodes.
-func (c Client) Get(id string) (*Node, error) {
+func (c Client) Get(id string, opts ...ReadOption) (*Node, error) {
+	n, _, err := c.get(id, opts)
+	return n, err
+}
+
+// getExisting fetches the Node with the given ID from DynamoDB, returning
+// ErrNotFound if it does not exist.
+func (c Client) getExisting(id string, opts ...ReadOption) (*Node, error) {
+	n, found, err := c.get(id, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, errors.Wrapf(ErrNotFound, "Client.Get: %s", id)
+	}
+
+	return n, nil
+}
+
+// get fetches the Node with the given ID from DynamoDB, and reports whether
+// it was found.
+func (c Client) get(id string, opts []ReadOption) (*Node, bool, error) {
 	log := c.log.Indent("Get")
 	log.Debug("called...")
 	defer log.Debug("exited")
@@ -62,34 +156,79 @@ func (c Client) Get(id string) (*Node, error) {
 	// This can't error
 	av, _ := dynamodbattribute.MarshalMap(n)
 
+	ro := c.readOptions(opts)
+	projection, names := ro.projectionExpression()
+
 	input := &dynamodb.GetItemInput{
-		Key:       av,
-		TableName: aws.String(c.tableName),
+		ConsistentRead:           aws.Bool(ro.consistent),
+		Key:                      av,
+		ProjectionExpression:     projection,
+		ExpressionAttributeNames: names,
+		TableName:                aws.String(c.tableName),
 	}
 
-	log.Debugf("GetItemInput:\n%v", input)
+	log.Debugf("GetItemInput:\n%v", c.dump(input))
 
 	log.Debug("calling GetItem...")
 	res, err := c.dataStore.GetItem(input)
 	if err != nil {
-		return nil, errors.Wrap(err, "Client.Get: Error retrieving node")
+		return nil, false, errors.Wrap(err, "Client.Get: Error retrieving node")
 	}
 
+	log.Debugf("GetItemOutput:\n%v", c.dump(res))
+
 	log.Debug("unmarshalling results...")
 	if err = dynamodbattribute.UnmarshalMap(res.Item, n); err != nil {
-		return nil, errors.Wrap(err, "Client.Get: Error unmarshalling results into type Node")
+		return nil, false, errors.Wrap(err, "Client.Get: Error unmarshalling results into type Node")
 	}
+	n.Partial = ro.partial()
+	n.idGen = c.idGen
 
-	return n, nil
+	return n, res.Item != nil, nil
 }
 
 // BatchGet fetches the nodes with the given IDs from DynamoDB.
-// It does not fetch or associate child nodes.
-func (c Client) BatchGet(ids []string) ([]*Node, error) {
+// It does not fetch or associate child nodes, and the nodes are not returned
+// in any particular order. An ID given more than once is fetched once.
+func (c Client) BatchGet(ids []string, opts ...ReadOption) ([]*Node, error) {
 	log := c.log.Indent("BatchGet")
 	log.Debug("called...")
 	defer log.Debug("exited")
 
+	ro := c.readOptions(opts)
+
+	// DynamoDB rejects a batch that holds the same key twice.
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	ids = unique
+
+	nodes := []*Node{}
+	for start := 0; start < len(ids); start += maxBatchGetKeys {
+		end := start + maxBatchGetKeys
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		page, err := c.batchGet(ids[start:end], ro)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, page...)
+	}
+
+	return nodes, nil
+}
+
+// batchGet fetches a single batch of nodes, resubmitting unprocessed keys.
+func (c Client) batchGet(ids []string, ro readOptions) ([]*Node, error) {
+	log := c.log.Indent("batchGet")
+
 	log.Debug("generating BatchGetItemInput...")
 	avs := []map[string]*dynamodb.AttributeValue{}
 	for _, id := range ids {
@@ -100,33 +239,64 @@ func (c Client) BatchGet(ids []string) ([]*Node, error) {
 		})
 	}
 
+	projection, names := ro.projectionExpression()
+
 	input := &dynamodb.BatchGetItemInput{
 		RequestItems: map[string]*dynamodb.KeysAndAttributes{
 			c.tableName: {
-				Keys: avs,
+				ConsistentRead:           aws.Bool(ro.consistent),
+				Keys:                     avs,
+				ProjectionExpression:     projection,
+				ExpressionAttributeNames: names,
 			},
 		},
 	}
 
-	log.Debugf("BatchGetItemInput:\n%v", input)
+	log.Debugf("BatchGetItemInput:\n%v", c.dump(input))
 
-	log.Debug("calling BatchGetItem...")
-	res, err := c.dataStore.BatchGetItem(input)
-	if err != nil {
-		return nil, errors.Wrap(err, "Client.BatchGet: error retrieving data from dynamodb")
-	}
+	nodes := []*Node{}
+	for attempt := 0; ; attempt++ {
+		log.Debug("calling BatchGetItem...")
+		res, err := c.dataStore.BatchGetItem(input)
+		if err != nil {
+			return nil, errors.Wrap(err, "Client.BatchGet: error retrieving data from dynamodb")
+		}
 
-	log.Debug("unmarshalling results...")
+		log.Debugf("BatchGetItemOutput:\n%v", c.dump(res))
+
+		log.Debug("unmarshalling results...")
+		page, err := c.unmarshalList(res.Responses[c.tableName], ro.partial())
+		if err != nil {
+			return nil, errors.Wrap(err, "Client.BatchGet: error unmarshalling results into type Node")
+		}
+		nodes = append(nodes, page...)
+
+		if len(res.UnprocessedKeys) == 0 {
+			return nodes, nil
+		}
 
-	return unmarshalList(res.Responses[c.tableName])
+		if attempt == maxBatchRetries {
+			return nil, errors.Errorf("Client.BatchGet: keys still unprocessed after %d retries", maxBatchRetries)
+		}
+
+		log.Warnf("retrying %d unprocessed keys, attempt %d...", len(res.UnprocessedKeys[c.tableName].Keys), attempt+1)
+		c.metrics.ObserveRetry("BatchGetItem")
+		time.Sleep(retryBackoff(attempt))
+		input.RequestItems = res.UnprocessedKeys
+	}
 }
 
 // GetChildren fetches all of the children of a given Node from DynamoDB.
-func (c Client) GetChildren(n Node) ([]*Node, error) {
+// Consistent reads are resolved through the Node's parent, see WithConsistentReads.
+func (c Client) GetChildren(n Node, opts ...ReadOption) ([]*Node, error) {
 	log := c.log.Indent("GetChildren")
 	log.Debug("called...")
 	defer log.Debug("exited")
 
+	if c.readOptions(opts).consistent {
+		return c.resolveChildren(n.ID, opts)
+	}
+
 	// No reason to query Dynamo if the node does not have children.
 	if !n.HasChildren() {
 		return []*Node{}, nil
@@ -134,11 +304,12 @@ func (c Client) GetChildren(n Node) ([]*Node, error) {
 
 	// The children of the current Node are those whose ParentID attribute are
 	// equivalent to the current Node's ID.
-	return c.query(n.ID, "ParentID")
+	return c.query(n.ID, "ParentID", opts...)
 }
 
 // GetSiblings fetches all of the siblings of a given Node from DynamoDB.
-func (c Client) GetSiblings(n Node) ([]*Node, error) {
+// Consistent reads are resolved through the Node's parent, see WithConsistentReads.
+func (c Client) GetSiblings(n Node, opts ...ReadOption) ([]*Node, error) {
 	// TODO: determine if this should also return the node that was passed in
 	log := c.log.Indent("GetSiblings")
 	log.Debug("called...")
@@ -149,39 +320,123 @@ func (c Client) GetSiblings(n Node) ([]*Node, error) {
 		return []*Node{}, nil
 	}
 
+	if c.readOptions(opts).consistent {
+		return c.resolveChildren(n.ParentID, opts)
+	}
+
 	// The siblings of the current Node are those whose ParentID attribute are
 	// equivalent to the current Node's ParentID.
-	return c.query(n.ParentID, "ParentID")
+	return c.query(n.ParentID, "ParentID", opts...)
+}
+
+// resolveChildren fetches the children of the Node with the given ID without
+// using the GSI, so they can be read consistently. The children are returned
+// in the same order as the parent's ChildIDs, so they must be stored inline.
+func (c Client) resolveChildren(parentID string, opts []ReadOption) ([]*Node, error) {
+	log := c.log.Indent("resolveChildren")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	if c.childStorage == IndexedChildren {
+		return nil, errors.New("children can only be read consistently when they are stored inline")
+	}
+
+	log.Debug("fetching parent...")
+	parent, err := c.Get(parentID, append(opts, StructureOnly())...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !parent.HasChildren() {
+		return []*Node{}, nil
+	}
+
+	log.Debug("fetching children...")
+	children, err := c.BatchGet(parent.ChildIDs, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[string]*Node{}
+	for _, child := range children {
+		byID[child.ID] = child
+	}
+
+	nodes := []*Node{}
+	for _, id := range parent.ChildIDs {
+		if child, ok := byID[id]; ok {
+			nodes = append(nodes, child)
+		}
+	}
+
+	return nodes, nil
 }
 
 // query is responsible for actually running a query against a DynamoDB table/GSI.
-func (c Client) query(id, partitionKey string) ([]*Node, error) {
+func (c Client) query(id, partitionKey string, opts ...ReadOption) ([]*Node, error) {
 	log := c.log.Indent("query")
 	log.Debug("called...")
 	defer log.Debug("exited")
 
 	log.Debug("generating QueryInput")
-	input := &dynamodb.QueryInput{
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":id": {S: aws.String(id)},
-		},
-		ExpressionAttributeNames: map[string]*string{
-			"#pkey": aws.String(partitionKey),
-		},
-		KeyConditionExpression: aws.String("#pkey = :id"),
-		TableName:              aws.String(c.tableName),
-		IndexName:              aws.String(c.gsiName),
+	ro := c.readOptions(opts)
+	projection, names := ro.projectionExpression()
+	if names == nil {
+		names = map[string]*string{}
 	}
+	names["#pkey"] = aws.String(partitionKey)
 
-	log.Debugf("QueryInput:\n%v", input)
+	values := map[string]*dynamodb.AttributeValue{
+		":id": {S: aws.String(id)},
+	}
 
-	log.Debug("calling Query...")
-	res, err := c.dataStore.Query(input)
-	if err != nil {
-		return nil, errors.Wrap(err, "query: Error retrieving data from DynamoDB")
+	var filter *string
+	if ro.filter != nil {
+		filter = aws.String(ro.filter.expression)
+		for k, v := range ro.filter.names {
+			names[k] = v
+		}
+		for k, v := range ro.filter.values {
+			values[k] = v
+		}
 	}
 
-	return unmarshalList(res.Items)
+	input := &dynamodb.QueryInput{
+		ExpressionAttributeValues: values,
+		ExpressionAttributeNames:  names,
+		FilterExpression:          filter,
+		KeyConditionExpression:    aws.String("#pkey = :id"),
+		ProjectionExpression:      projection,
+		TableName:                 aws.String(c.tableName),
+		IndexName:                 aws.String(c.gsiName),
+	}
+
+	log.Debugf("QueryInput:\n%v", c.dump(input))
+
+	nodes := []*Node{}
+	for {
+		log.Debug("calling Query...")
+		res, err := c.dataStore.Query(input)
+		if err != nil {
+			return nil, errors.Wrap(err, "query: Error retrieving data from DynamoDB")
+		}
+
+		log.Debugf("QueryOutput:\n%v", c.dump(res))
+
+		page, err := c.unmarshalList(res.Items, ro.partial())
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, page...)
+
+		// Results are paginated at 1 MB, which wide parents easily exceed.
+		if len(res.LastEvaluatedKey) == 0 {
+			return nodes, nil
+		}
+
+		log.Debug("fetching next page...")
+		input.ExclusiveStartKey = res.LastEvaluatedKey
+	}
 }
 
 // Put stores the given Node in DynamoDB.
@@ -190,8 +445,17 @@ func (c Client) Put(in Node) error {
 	log.Debug("called...")
 	defer log.Debug("exited")
 
+	if in.Partial {
+		return errPartialWrite(in.ID)
+	}
+
+	log.Debug("validating node...")
+	if err := c.validateWrite([]*Node{&in}); err != nil {
+		return errors.Wrap(err, "Client.Put")
+	}
+
 	log.Debug("marshalling data...")
-	av, err := dynamodbattribute.MarshalMap(in)
+	av, err := c.marshalNode(&in)
 	if err != nil {
 		return err
 	}
@@ -202,27 +466,41 @@ func (c Client) Put(in Node) error {
 		TableName: aws.String(c.tableName),
 	}
 
-	log.Debugf("PutItemInput:\n%v", input)
+	log.Debugf("PutItemInput:\n%v", c.dump(input))
 
 	log.Debug("calling PutItem...")
 	if _, err := c.dataStore.PutItem(input); err != nil {
 		return err
 	}
+	c.ancestors.evict(in.ID)
 
 	return nil
 }
 
 // BatchPut stores the given Node(s), in DynamoDB.
+// The nodes are written in batches of 25, as many as DynamoDB accepts at once.
 func (c Client) BatchPut(in []*Node) error {
 	log := c.log.Indent("BatchPut")
 	log.Debug("called...")
 	defer log.Debug("exited")
 
+	for _, n := range in {
+		if n.Partial {
+			return errPartialWrite(n.ID)
+		}
+	}
+
+	log.Debug("validating nodes...")
+	if err := c.validateWrite(in); err != nil {
+		return errors.Wrap(err, "Client.BatchPut")
+	}
+
 	log.Debug("generating BatchWriteItemInput...")
 	wr := []*dynamodb.WriteRequest{}
 
 	for _, n := range in {
-		av, err := dynamodbattribute.MarshalMap(n)
+
+		av, err := c.marshalNode(n)
 		if err != nil {
 			return err
 		}
@@ -232,52 +510,128 @@ func (c Client) BatchPut(in []*Node) error {
 		})
 	}
 
+	for start := 0; start < len(wr); start += maxBatchWriteItems {
+		end := start + maxBatchWriteItems
+		if end > len(wr) {
+			end = len(wr)
+		}
+
+		if err := c.batchWrite(wr[start:end]); err != nil {
+			return err
+		}
+	}
+
+	ids := []string{}
+	for _, n := range in {
+		ids = append(ids, n.ID)
+	}
+	c.ancestors.evict(ids...)
+
+	return nil
+}
+
+// batchWrite sends a single batch of write requests, resubmitting
+// unprocessed items.
+func (c Client) batchWrite(wr []*dynamodb.WriteRequest) error {
+	log := c.log.Indent("batchWrite")
+
 	input := &dynamodb.BatchWriteItemInput{
 		RequestItems: map[string][]*dynamodb.WriteRequest{
 			c.tableName: wr,
 		},
 	}
 
-	log.Debugf("BatchWriteItemInput:\n%v", input)
+	log.Debugf("BatchWriteItemInput:\n%v", c.dump(input))
 
-	log.Debug("calling BatchWriteItem...")
-	if _, err := c.dataStore.BatchWriteItem(input); err != nil {
-		return err
-	}
+	for attempt := 0; ; attempt++ {
+		log.Debug("calling BatchWriteItem...")
+		res, err := c.dataStore.BatchWriteItem(input)
+		if err != nil {
+			return err
+		}
 
-	return nil
+		log.Debugf("BatchWriteItemOutput:\n%v", c.dump(res))
+
+		if len(res.UnprocessedItems) == 0 {
+			return nil
+		}
+
+		if attempt == maxBatchRetries {
+			return errors.Errorf("batchWrite: items still unprocessed after %d retries", maxBatchRetries)
+		}
+
+		log.Warnf("retrying %d unprocessed items, attempt %d...", len(res.UnprocessedItems[c.tableName]), attempt+1)
+		c.metrics.ObserveRetry("BatchWriteItem")
+		time.Sleep(retryBackoff(attempt))
+		input.RequestItems = res.UnprocessedItems
+	}
 }
 
-// Delete removes the given Node, and all children, from DynamoDB.
+// Delete removes the given Node from DynamoDB. It does not update its
+// parent, or remove its children.
 func (c Client) Delete(in *Node) error {
 	log := c.log.Indent("Delete")
 	log.Debug("called...")
 	defer log.Debug("exited")
 
-	log.Debug("marshalling input...")
-	av, err := dynamodbattribute.MarshalMap(in)
-	if err != nil {
-		return err
-	}
-
 	log.Debug("generating DeleteItemInput...")
 	input := &dynamodb.DeleteItemInput{
-		Key:       av,
+		// Only the key can be given, DynamoDB rejects any other attribute.
+		Key: map[string]*dynamodb.AttributeValue{
+			"ID": {S: aws.String(in.ID)},
+		},
 		TableName: aws.String(c.tableName),
 	}
 
-	log.Debugf("DeleteItemInput:\n%v", input)
+	log.Debugf("DeleteItemInput:\n%v", c.dump(input))
 
 	log.Debug("calling DeleteItem...")
 	if _, err := c.dataStore.DeleteItem(input); err != nil {
 		return err
 	}
+	c.ancestors.evict(in.ID)
+
+	return nil
+}
+
+// BatchDelete removes the Nodes with the given IDs from DynamoDB, in batches
+// of 25. It does not update their parents, or remove their children.
+func (c Client) BatchDelete(ids []string) error {
+	log := c.log.Indent("BatchDelete")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	log.Debug("generating BatchWriteItemInput...")
+	wr := []*dynamodb.WriteRequest{}
+	for _, id := range ids {
+		wr = append(wr, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{
+				Key: map[string]*dynamodb.AttributeValue{
+					"ID": {S: aws.String(id)},
+				},
+			},
+		})
+	}
+
+	for start := 0; start < len(wr); start += maxBatchWriteItems {
+		end := start + maxBatchWriteItems
+		if end > len(wr) {
+			end = len(wr)
+		}
+
+		if err := c.batchWrite(wr[start:end]); err != nil {
+			return err
+		}
+	}
+	c.ancestors.evict(ids...)
 
 	return nil
 }
 
 // unmarshalList unmarshalles a list of results from dynamo into a slice of Nodes.
-func unmarshalList(avs []map[string]*dynamodb.AttributeValue) ([]*Node, error) {
+// If partial is true the items were fetched with a projection, and the Nodes
+// are marked as such.
+func (c Client) unmarshalList(avs []map[string]*dynamodb.AttributeValue, partial bool) ([]*Node, error) {
 	nodes := []*Node{}
 
 	for _, av := range avs {
@@ -285,9 +639,17 @@ func unmarshalList(avs []map[string]*dynamodb.AttributeValue) ([]*Node, error) {
 		if err := dynamodbattribute.UnmarshalMap(av, n); err != nil {
 			return nil, err
 		}
+		n.Partial = partial
+		n.idGen = c.idGen
 
 		nodes = append(nodes, n)
 	}
 
 	return nodes, nil
 }
+
+// errPartialWrite is returned when attempting to store a partially loaded
+// Node, which would overwrite the attributes that were not fetched.
+func errPartialWrite(id string) error {
+	return errors.Errorf("node %s was partially loaded and cannot be written", id)
+}
@@ -0,0 +1,99 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/erumble/dynamo-playground/pkg/logger"
+	"github.com/erumble/dynamo-playground/pkg/logger/loggertest"
+	"github.com/erumble/dynamo-playground/pkg/node/dynamotest"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestClientWithContextLogsRetries(t *testing.T) {
+	base, requestLog := loggertest.New(), loggertest.New()
+	ctx := logger.NewContext(context.Background(), requestLog.With("request", "r1"))
+
+	db := dynamotest.New()
+	c := NewClient(base, db, "nodes", "parents").WithContext(ctx)
+
+	root := NewWithGenerator(nil, NewDeterministicGenerator(1))
+	nodes := []*Node{root, root.CreateChild(), root.CreateChild()}
+
+	db.Unprocessed = 1
+	if err := c.BatchPut(nodes); err != nil {
+		t.Fatalf("BatchPut: %v", err)
+	}
+
+	db.Unprocessed = 1
+	got, err := c.BatchGet([]string{nodes[0].ID, nodes[1].ID, nodes[2].ID})
+	if err != nil {
+		t.Fatalf("BatchGet: %v", err)
+	}
+	if len(got) != len(nodes) {
+		t.Errorf("BatchGet returned %d nodes after retrying, want %d", len(got), len(nodes))
+	}
+
+	warnings := requestLog.Entries().Level(zapcore.WarnLevel)
+	if warnings.Under("nodeClient").Field("request", "r1").Len() != 2 {
+		t.Fatalf("request logger recorded warnings %v, want one per retried batch, with the request's fields", warnings.Messages())
+	}
+	if warnings.Name("nodeClient.batchWrite").Len() != 1 || warnings.Name("nodeClient.batchGet").Len() != 1 {
+		t.Errorf("retries were logged as %+v, want under nodeClient.batchWrite and nodeClient.batchGet", warnings)
+	}
+
+	if base.Entries().Len() != 0 {
+		t.Errorf("the Client's own logger recorded %v, want everything logged through the request's", base.Entries().Messages())
+	}
+}
+
+func TestClientWithContextWithoutLogger(t *testing.T) {
+	base := loggertest.New()
+	db := dynamotest.New()
+	c := NewClient(base, db, "nodes", "parents").WithContext(context.Background())
+
+	db.Unprocessed = 1
+	if err := c.Put(Node{ID: "a"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := c.BatchPut([]*Node{{ID: "b"}, {ID: "c"}}); err != nil {
+		t.Fatalf("BatchPut: %v", err)
+	}
+
+	if base.Entries().Level(zapcore.WarnLevel).Name("nodeClient.batchWrite").Len() != 1 {
+		t.Errorf("the Client's logger recorded warnings %v, want the retry", base.Entries().Level(zapcore.WarnLevel).Messages())
+	}
+}
+
+func TestClientLogsUnderItsName(t *testing.T) {
+	log := loggertest.New()
+	c := NewClient(log, dynamotest.New(), "nodes", "parents")
+
+	if err := c.Put(Node{ID: "a"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := c.Get("a"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	get := log.Entries().Name("nodeClient.Get")
+	if msgs := get.Messages(); len(msgs) < 2 || msgs[0] != "called..." || msgs[len(msgs)-1] != "exited" {
+		t.Errorf("Get logged %v, want it to start with called... and end with exited", msgs)
+	}
+	if get.Contains("GetItemInput:\n").Len() != 1 {
+		t.Errorf("Get logged %v, want the GetItemInput", get.Messages())
+	}
+	if n := log.Entries().AtLeast(zapcore.WarnLevel).Len(); n != 0 {
+		t.Errorf("Put and Get logged %d warnings or errors, want none", n)
+	}
+
+	// Raising the level of the logger given to NewClient quiets the Client.
+	log.Reset()
+	log.Level().SetLevel(zapcore.InfoLevel)
+	if _, err := c.Get("a"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if n := log.Entries().Under("nodeClient").Len(); n != 0 {
+		t.Errorf("Get logged %d entries at info level, want none", n)
+	}
+}
@@ -0,0 +1,392 @@
+package node
+
+import (
+	"errors"
+	"testing"
+)
+
+// family creates a parent with the given number of children, with IDs
+// generated from seed.
+func family(seed int64, children int) (*Node, []*Node) {
+	parent := NewWithGenerator(nil, NewDeterministicGenerator(seed))
+
+	nodes := []*Node{}
+	for i := 0; i < children; i++ {
+		nodes = append(nodes, parent.CreateChild())
+	}
+
+	return parent, nodes
+}
+
+// checkPositions fails the test if any two of the Nodes share a Position, or
+// they are not in Position order.
+func checkPositions(t *testing.T, nodes ...*Node) {
+	t.Helper()
+
+	for i := 1; i < len(nodes); i++ {
+		if nodes[i-1].Position >= nodes[i].Position {
+			t.Errorf("node %d Position %q does not sort after node %d Position %q", i, nodes[i].Position, i-1, nodes[i-1].Position)
+		}
+	}
+}
+
+func TestRegisterChildAfterRemove(t *testing.T) {
+	parent, children := family(1, 3)
+	a, b, c := children[0], children[1], children[2]
+
+	if _, err := parent.RemoveChild(a); err != nil {
+		t.Fatalf("RemoveChild: %v", err)
+	}
+
+	d := parent.CreateChild()
+	checkPositions(t, b, c, d)
+
+	if parent.NumChildren() != 3 {
+		t.Errorf("NumChildren = %d, want 3", parent.NumChildren())
+	}
+}
+
+func TestRegisterChildAfterRemoveLast(t *testing.T) {
+	parent, children := family(1, 3)
+	c := children[2]
+	removed := c.Position
+
+	if _, err := c.Detach(parent); err != nil {
+		t.Fatalf("Detach: %v", err)
+	}
+
+	d := parent.CreateChild()
+	if d.Position <= removed {
+		t.Errorf("new child Position %q does not sort after the removed child's %q", d.Position, removed)
+	}
+}
+
+func TestReparentWithinParent(t *testing.T) {
+	parent, children := family(1, 3)
+	a, b, c := children[0], children[1], children[2]
+
+	if _, err := a.Reparent(parent, parent); err != nil {
+		t.Fatalf("Reparent: %v", err)
+	}
+	checkPositions(t, b, c, a)
+
+	d := parent.CreateChild()
+	checkPositions(t, b, c, a, d)
+
+	if want := []string{b.ID, c.ID, a.ID, d.ID}; !sameIDs(parent.ChildIDs, want) {
+		t.Errorf("ChildIDs = %v, want %v", parent.ChildIDs, want)
+	}
+}
+
+func TestAdoptAfterRemove(t *testing.T) {
+	parent, children := family(1, 3)
+	other, _ := family(2, 0)
+	moved := other.CreateChild()
+
+	if _, err := parent.RemoveChild(children[0]); err != nil {
+		t.Fatalf("RemoveChild: %v", err)
+	}
+	if err := parent.Adopt(moved, other); err != nil {
+		t.Fatalf("Adopt: %v", err)
+	}
+
+	checkPositions(t, children[1], children[2], moved)
+}
+
+func TestRegisterChildLegacyParent(t *testing.T) {
+	// A parent stored before LastChildPosition was kept, with sequentially
+	// numbered children.
+	parent := &Node{ID: "p", ChildIDs: []string{"a", "b", "c"}, ChildCount: 3}
+	a := &Node{ID: "a", ParentID: "p", Position: positionAt(0)}
+	b := &Node{ID: "b", ParentID: "p", Position: positionAt(1)}
+	c := &Node{ID: "c", ParentID: "p", Position: positionAt(2)}
+
+	if _, err := parent.RemoveChild(a); err != nil {
+		t.Fatalf("RemoveChild: %v", err)
+	}
+
+	d := &Node{ID: "d"}
+	if err := parent.RegisterChild(d); err != nil {
+		t.Fatalf("RegisterChild: %v", err)
+	}
+
+	checkPositions(t, b, c, d)
+}
+
+func sameIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newTestTree builds a Tree of a root r with children a and b, a's children
+// a1 and a2, and a second root s. The Nodes are given out of order.
+func newTestTree(t *testing.T) *Tree {
+	t.Helper()
+
+	r, s := &Node{ID: "r"}, &Node{ID: "s"}
+	a, b, a1, a2 := &Node{ID: "a"}, &Node{ID: "b"}, &Node{ID: "a1"}, &Node{ID: "a2"}
+	for _, pair := range [][2]*Node{{r, a}, {r, b}, {a, a1}, {a, a2}} {
+		if err := pair[0].RegisterChild(pair[1]); err != nil {
+			t.Fatalf("RegisterChild: %v", err)
+		}
+	}
+
+	tree := NewTree([]*Node{a2, b, r, a1, s, a})
+	if len(tree.Problems()) != 0 {
+		t.Fatalf("NewTree found problems: %v", tree.Problems())
+	}
+
+	return tree
+}
+
+// iterated returns the IDs of the Nodes the Iterator steps through.
+func iterated(it *Iterator) []string {
+	ids := []string{}
+	for it.Next() {
+		ids = append(ids, it.Node().ID)
+	}
+
+	return ids
+}
+
+// problemIDs returns the IDs of the Nodes with problems, in the order they
+// were found.
+func problemIDs(tree *Tree) []string {
+	ids := []string{}
+	for _, p := range tree.Problems() {
+		ids = append(ids, p.ID)
+	}
+
+	return ids
+}
+
+func TestNewTree(t *testing.T) {
+	tree := newTestTree(t)
+
+	if tree.Len() != 6 {
+		t.Errorf("Len = %d, want 6", tree.Len())
+	}
+	if got := idsOf(tree.Roots()); !sameIDs(got, []string{"r", "s"}) {
+		t.Errorf("Roots = %v, want [r s]", got)
+	}
+	if got := idsOf(tree.Children("a")); !sameIDs(got, []string{"a1", "a2"}) {
+		t.Errorf("Children(a) = %v, want [a1 a2]", got)
+	}
+	if p := tree.Parent("a1"); p == nil || p.ID != "a" {
+		t.Errorf("Parent(a1) = %v, want a", p)
+	}
+	if p := tree.Parent("r"); p != nil {
+		t.Errorf("Parent(r) = %v, want nil", p)
+	}
+	if got := idsOf(tree.Leaves()); !sameIDs(got, []string{"a1", "a2", "b", "s"}) {
+		t.Errorf("Leaves = %v, want [a1 a2 b s]", got)
+	}
+}
+
+func TestNewTreeOrphans(t *testing.T) {
+	// a's parent was not fetched, so a is a root of its own.
+	a, a1 := &Node{ID: "a", ParentID: "missing"}, &Node{ID: "a1"}
+	if err := a.RegisterChild(a1); err != nil {
+		t.Fatalf("RegisterChild: %v", err)
+	}
+
+	tree := NewTree([]*Node{a1, a})
+
+	if len(tree.Problems()) != 0 {
+		t.Errorf("Problems = %v, want none for an orphan", tree.Problems())
+	}
+	if got := idsOf(tree.Roots()); !sameIDs(got, []string{"a"}) {
+		t.Errorf("Roots = %v, want [a]", got)
+	}
+	if p := tree.Parent("a"); p != nil {
+		t.Errorf("Parent(a) = %v, want nil", p)
+	}
+	if got := iterated(tree.DFS()); !sameIDs(got, []string{"a", "a1"}) {
+		t.Errorf("DFS = %v, want [a a1]", got)
+	}
+}
+
+func TestNewTreeDuplicateIDs(t *testing.T) {
+	first, second := &Node{ID: "a", Metadata: "first"}, &Node{ID: "a", Metadata: "second"}
+
+	tree := NewTree([]*Node{first, second})
+
+	if got := problemIDs(tree); !sameIDs(got, []string{"a"}) {
+		t.Errorf("Problems = %v, want one for a", tree.Problems())
+	}
+	if n, _ := tree.Get("a"); n != first {
+		t.Errorf("Get(a) = %+v, want the first Node", n)
+	}
+	if tree.Len() != 1 || len(tree.Roots()) != 1 {
+		t.Errorf("Len = %d with %d roots, want the duplicate left out", tree.Len(), len(tree.Roots()))
+	}
+}
+
+func TestNewTreeDisagreement(t *testing.T) {
+	// p lists c, but c has another parent, and q's child d is not listed.
+	p := &Node{ID: "p", ChildIDs: []string{"c"}}
+	q := &Node{ID: "q", ChildIDs: []string{"c"}}
+	c := &Node{ID: "c", ParentID: "q"}
+	d := &Node{ID: "d", ParentID: "q"}
+
+	tree := NewTree([]*Node{p, q, c, d})
+
+	if got := problemIDs(tree); !sameIDs(got, []string{"p", "d"}) {
+		t.Errorf("Problems = %v, want ones for p and d", tree.Problems())
+	}
+	if parent := tree.Parent("c"); parent == nil || parent.ID != "q" {
+		t.Errorf("Parent(c) = %v, want q, from its ParentID", parent)
+	}
+	if parent := tree.Parent("d"); parent == nil || parent.ID != "q" {
+		t.Errorf("Parent(d) = %v, want q, from its ParentID", parent)
+	}
+	if len(tree.Children("q")) != 2 {
+		t.Errorf("Children(q) = %v, want c and d", idsOf(tree.Children("q")))
+	}
+	if len(tree.Children("p")) != 0 {
+		t.Errorf("Children(p) = %v, want none", idsOf(tree.Children("p")))
+	}
+}
+
+func TestNewTreeCycles(t *testing.T) {
+	r := &Node{ID: "r"}
+	a, b := &Node{ID: "a", ParentID: "b"}, &Node{ID: "b", ParentID: "a"}
+	c := &Node{ID: "c", ParentID: "b"}
+	self := &Node{ID: "self", ParentID: "self"}
+
+	tree := NewTree([]*Node{r, a, b, c, self})
+
+	// The cycle and what hangs off it are left out, a Node that is its own
+	// parent is invalid, but a root.
+	if got := problemIDs(tree); !sameIDs(got, []string{"self", "a", "b", "c"}) {
+		t.Errorf("Problems = %v, want ones for self, a, b and c", tree.Problems())
+	}
+	if got := iterated(tree.DFS()); !sameIDs(got, []string{"r", "self"}) {
+		t.Errorf("DFS = %v, want [r self]", got)
+	}
+	if got := iterated(tree.DFS("a")); !sameIDs(got, []string{"a", "b", "c"}) {
+		t.Errorf("DFS(a) = %v, want each Node of the cycle once", got)
+	}
+	if got := iterated(tree.PostOrder("a")); !sameIDs(got, []string{"c", "b", "a"}) {
+		t.Errorf("PostOrder(a) = %v, want each Node of the cycle once", got)
+	}
+}
+
+func TestTreeIterators(t *testing.T) {
+	tree := newTestTree(t)
+
+	tests := []struct {
+		name string
+		it   *Iterator
+		want []string
+	}{
+		{"DFS", tree.DFS(), []string{"r", "a", "a1", "a2", "b", "s"}},
+		{"DFS from a", tree.DFS("a"), []string{"a", "a1", "a2"}},
+		{"DFS from a and b", tree.DFS("b", "a"), []string{"b", "a", "a1", "a2"}},
+		{"DFS from overlapping", tree.DFS("r", "a"), []string{"r", "a", "a1", "a2", "b"}},
+		{"DFS from missing", tree.DFS("missing"), []string{}},
+		{"BFS", tree.BFS(), []string{"r", "s", "a", "b", "a1", "a2"}},
+		{"BFS from a", tree.BFS("a"), []string{"a", "a1", "a2"}},
+		{"PostOrder", tree.PostOrder(), []string{"a1", "a2", "a", "b", "r", "s"}},
+		{"PostOrder from a", tree.PostOrder("a"), []string{"a1", "a2", "a"}},
+	}
+
+	for _, tt := range tests {
+		if got := iterated(tt.it); !sameIDs(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+		if tt.it.Next() {
+			t.Errorf("%s: Next = true once exhausted", tt.name)
+		}
+	}
+}
+
+// TestTreeIteratorsStopEarly checks that a loop can stop at any point, and
+// the iterator carries on from there.
+func TestTreeIteratorsStopEarly(t *testing.T) {
+	tree := newTestTree(t)
+
+	for name, it := range map[string]*Iterator{"DFS": tree.DFS(), "BFS": tree.BFS()} {
+		found := ""
+		for it.Next() {
+			if it.Node().ID == "a" {
+				found = it.Node().ID
+				break
+			}
+		}
+		if found != "a" {
+			t.Errorf("%s did not find a", name)
+		}
+
+		// The iterator picks up where it stopped.
+		if !it.Next() || it.Node().ID == "a" || it.Node().ID == "r" {
+			t.Errorf("%s continued with %v, want the Node after a", name, it.Node())
+		}
+	}
+}
+
+func TestTreeWalk(t *testing.T) {
+	tree := newTestTree(t)
+
+	visited := []string{}
+	depths := map[string]int{}
+	err := tree.Walk(func(n *Node, depth int) error {
+		visited = append(visited, n.ID)
+		depths[n.ID] = depth
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	if !sameIDs(visited, []string{"r", "a", "a1", "a2", "b", "s"}) {
+		t.Errorf("Walk visited %v, want [r a a1 a2 b s]", visited)
+	}
+	for id, want := range map[string]int{"r": 0, "a": 1, "a2": 2, "b": 1, "s": 0} {
+		if depths[id] != want {
+			t.Errorf("Walk gave %s depth %d, want %d", id, depths[id], want)
+		}
+	}
+
+	// SkipSubtree skips a's children, but not its siblings, and is not
+	// returned.
+	visited = visited[:0]
+	err = tree.Walk(func(n *Node, depth int) error {
+		visited = append(visited, n.ID)
+		if n.ID == "a" {
+			return SkipSubtree
+		}
+		return nil
+	}, "r")
+	if err != nil {
+		t.Errorf("Walk with SkipSubtree = %v, want nil", err)
+	}
+	if !sameIDs(visited, []string{"r", "a", "b"}) {
+		t.Errorf("Walk with SkipSubtree visited %v, want [r a b]", visited)
+	}
+
+	// Any other error stops the walk.
+	stop := errors.New("stop")
+	visited = visited[:0]
+	err = tree.Walk(func(n *Node, depth int) error {
+		visited = append(visited, n.ID)
+		if n.ID == "a1" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("Walk = %v, want %v", err, stop)
+	}
+	if !sameIDs(visited, []string{"r", "a", "a1"}) {
+		t.Errorf("Walk visited %v before stopping, want [r a a1]", visited)
+	}
+}
@@ -0,0 +1,226 @@
+package node
+
+import (
+	"github.com/pkg/errors"
+)
+
+// InsertChild stores child as a child of the Node with the given parentID, at
+// the given (zero based) position among its siblings. A position past the
+// last sibling appends the child. The child must not already be a child of
+// the parent, use ReorderChildren to move existing children.
+//
+// The child is normally given a Position between its new neighbours, so only
+// the parent and the child are written. If the siblings' positions leave no
+// room, e.g. they were stored without one, all siblings are renumbered.
+func (c Client) InsertChild(parentID string, child *Node, position int) error {
+	log := c.log.Indent("InsertChild")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	log.Debug("fetching parent...")
+	parent, err := c.getExisting(parentID, ConsistentRead(true))
+	if err != nil {
+		return errors.Wrap(err, "Client.InsertChild: error fetching parent")
+	}
+	stored := *parent
+
+	log.Debug("fetching siblings...")
+	siblings, err := c.orderedChildren(parent)
+	if err != nil {
+		return errors.Wrap(err, "Client.InsertChild: error fetching siblings")
+	}
+
+	for _, sibling := range siblings {
+		if sibling.ID == child.ID {
+			return errors.Errorf("Client.InsertChild: node %s is already a child of %s", child.ID, parentID)
+		}
+	}
+
+	if position < 0 {
+		position = 0
+	}
+	if position > len(siblings) {
+		position = len(siblings)
+	}
+
+	// The child goes right after its preceding sibling in ChildIDs, so any
+	// IDs that could not be resolved keep their place.
+	index := 0
+	before, after := "", ""
+	if position > 0 {
+		before = siblings[position-1].Position
+		index = indexOf(parent.ChildIDs, siblings[position-1].ID) + 1
+	}
+	if position < len(siblings) {
+		after = siblings[position].Position
+	}
+
+	// Appending numbers the child like RegisterChild does, so positions
+	// don't get longer with every child added at the end, and are not
+	// given out twice.
+	p, ok := positionBetween(before, after)
+	if position == len(siblings) {
+		parent.notePosition(before)
+		p, ok = parent.appendPosition(), true
+	}
+
+	// Counted before the child is listed, NumChildren falls back on ChildIDs.
+	count := parent.NumChildren()
+	parent.ChildIDs = append(parent.ChildIDs[:index], append([]string{child.ID}, parent.ChildIDs[index:]...)...)
+	parent.ChildCount = count + 1
+	child.ParentID = parent.ID
+
+	toWrite := []*Node{parent, child}
+	if ok && positionsOrdered(siblings) {
+		child.Position = p
+	} else {
+		toWrite = renumber(parent, siblings, child, position)
+	}
+
+	if c.childStorage == InlineChildren {
+		log.Debug("storing nodes...")
+		if err := c.BatchPut(toWrite); err != nil {
+			return errors.Wrap(err, "Client.InsertChild: error storing nodes")
+		}
+
+		return nil
+	}
+
+	// Parents with indexed children can be too wide to rewrite safely, so
+	// their ChildCount is incremented in place instead, once the child is
+	// stored, as AddChild does.
+	log.Debug("storing nodes...")
+	if err := c.BatchPut(toWrite[1:]); err != nil {
+		return errors.Wrap(err, "Client.InsertChild: error storing nodes")
+	}
+
+	log.Debug("incrementing child count...")
+	if err := c.incrementChildCount(&stored, child.ID, parent.LastChildPosition); err != nil {
+		log.Debug("removing child...")
+		if derr := c.Delete(child); derr != nil {
+			log.Errorf("error removing child %s of %s after failing to update the parent: %v", child.ID, parentID, derr)
+		}
+		return errors.Wrap(err, "Client.InsertChild: error updating parent")
+	}
+
+	return nil
+}
+
+// ReorderChildren rearranges the children of the Node with the given
+// parentID to match ids, which must contain every child exactly once.
+func (c Client) ReorderChildren(parentID string, ids []string) error {
+	log := c.log.Indent("ReorderChildren")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	log.Debug("fetching parent...")
+	parent, err := c.getExisting(parentID, ConsistentRead(true))
+	if err != nil {
+		return errors.Wrap(err, "Client.ReorderChildren: error fetching parent")
+	}
+
+	log.Debug("fetching children...")
+	children, err := c.orderedChildren(parent)
+	if err != nil {
+		return errors.Wrap(err, "Client.ReorderChildren: error fetching children")
+	}
+
+	current := parent.ChildIDs
+	if c.childStorage == IndexedChildren {
+		current = []string{}
+		for _, child := range children {
+			current = append(current, child.ID)
+		}
+	}
+
+	if !samePermutation(current, ids) {
+		return errors.Errorf("Client.ReorderChildren: ids must contain each child of %s exactly once", parentID)
+	}
+
+	byID := map[string]*Node{}
+	for _, child := range children {
+		byID[child.ID] = child
+	}
+
+	parent.ChildIDs = append([]string{}, ids...)
+	toWrite := []*Node{parent}
+	if c.childStorage == IndexedChildren {
+		// Only the children's positions change, the parent has nothing to store.
+		toWrite = []*Node{}
+	}
+
+	for i, id := range ids {
+		if child, ok := byID[id]; ok {
+			child.Position = positionAt(i)
+			toWrite = append(toWrite, child)
+		}
+	}
+
+	log.Debug("storing nodes...")
+	if err := c.BatchPut(toWrite); err != nil {
+		return errors.Wrap(err, "Client.ReorderChildren: error storing nodes")
+	}
+
+	return nil
+}
+
+// renumber inserts child among siblings at the given position, numbers all of
+// them sequentially, and returns every Node that has to be written.
+func renumber(parent *Node, siblings []*Node, child *Node, position int) []*Node {
+	ordered := append([]*Node{}, siblings[:position]...)
+	ordered = append(ordered, child)
+	ordered = append(ordered, siblings[position:]...)
+
+	toWrite := []*Node{parent}
+	for i, n := range ordered {
+		n.Position = positionAt(i)
+		toWrite = append(toWrite, n)
+	}
+	parent.notePosition(positionAt(len(ordered) - 1))
+
+	return toWrite
+}
+
+// positionsOrdered returns true if every Node has a Position, and they are in
+// strictly increasing order.
+func positionsOrdered(nodes []*Node) bool {
+	for i, n := range nodes {
+		if n.Position == "" || (i > 0 && nodes[i-1].Position >= n.Position) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// indexOf returns the index of id in ids, or -1 if it is not there.
+func indexOf(ids []string, id string) int {
+	for i := range ids {
+		if ids[i] == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// samePermutation returns true if a and b hold the same, unique, IDs.
+func samePermutation(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	seen := map[string]bool{}
+	for _, id := range a {
+		seen[id] = true
+	}
+
+	for _, id := range b {
+		if !seen[id] {
+			return false
+		}
+		delete(seen, id)
+	}
+
+	return len(seen) == 0
+}
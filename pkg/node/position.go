@@ -0,0 +1,136 @@
+package node
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// positionDigits are the digits positions are made of, in sort order.
+	positionDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+	// positionWidth is the number of digits positionAt numbers children with.
+	positionWidth = 6
+)
+
+// positionAt returns the Position of the child at the given (zero based)
+// index, when children are numbered sequentially. The trailing digit keeps
+// positions from ending in the smallest digit, so there is always room to
+// insert a position before any other.
+func positionAt(index int) string {
+	return fmt.Sprintf("%0*s1", positionWidth, strconv.FormatInt(int64(index), len(positionDigits)))
+}
+
+// positionAfter returns the Position of a child appended after the one at
+// p, an empty p meaning there is none. It is numbered like positionAt, from
+// the number p starts with, so positions stay short however many children
+// are appended, and sort after any position made by positionBetween.
+func positionAfter(p string) string {
+	if p == "" {
+		return positionAt(0)
+	}
+
+	// A short p, e.g. one made by positionBetween, is zero padded, which
+	// sorts it no later than the number it is read as.
+	number := p
+	if len(number) > positionWidth {
+		number = number[:positionWidth]
+	}
+	number += strings.Repeat(positionDigits[:1], positionWidth-len(number))
+
+	index, err := strconv.ParseInt(number, len(positionDigits), 64)
+	if err != nil || index+1 >= maxPositionIndex() {
+		// Out of numbers, extending p still sorts after it.
+		return p + positionDigits[1:2]
+	}
+
+	return positionAt(int(index + 1))
+}
+
+// maxPositionIndex is the first index positionAt can't number within positionWidth digits.
+func maxPositionIndex() int64 {
+	max := int64(1)
+	for i := 0; i < positionWidth; i++ {
+		max *= int64(len(positionDigits))
+	}
+
+	return max
+}
+
+// positionBetween returns a Position that sorts strictly between before and
+// after. An empty before means the start of the list, an empty after means
+// the end of the list. It returns false if no such position exists, i.e.
+// before does not sort before after, or either ends in the smallest digit.
+func positionBetween(before, after string) (string, bool) {
+	if after != "" && before >= after {
+		return "", false
+	}
+
+	if strings.HasSuffix(before, positionDigits[:1]) || strings.HasSuffix(after, positionDigits[:1]) {
+		return "", false
+	}
+
+	for _, r := range before + after {
+		if !strings.ContainsRune(positionDigits, r) {
+			return "", false
+		}
+	}
+
+	return midpoint(before, after), true
+}
+
+// midpoint implements positionBetween, treating both positions as fractions
+// in base len(positionDigits). It assumes its inputs are valid.
+func midpoint(before, after string) string {
+	if after != "" {
+		// Skip the common prefix, treating a short before as zero padded.
+		n := 0
+		for n < len(after) && digitAt(before, n) == after[n] {
+			n++
+		}
+
+		if n > 0 {
+			rest := ""
+			if n < len(before) {
+				rest = before[n:]
+			}
+			return after[:n] + midpoint(rest, after[n:])
+		}
+	}
+
+	lo := 0
+	if before != "" {
+		lo = strings.IndexByte(positionDigits, before[0])
+	}
+
+	hi := len(positionDigits)
+	if after != "" {
+		hi = strings.IndexByte(positionDigits, after[0])
+	}
+
+	if hi-lo > 1 {
+		return positionDigits[(lo+hi+1)/2 : (lo+hi+1)/2+1]
+	}
+
+	// The first digits are adjacent, a longer after can simply be truncated,
+	// otherwise keep before's first digit and find a position after the rest.
+	if len(after) > 1 {
+		return after[:1]
+	}
+
+	rest := ""
+	if before != "" {
+		rest = before[1:]
+	}
+
+	return positionDigits[lo:lo+1] + midpoint(rest, "")
+}
+
+func digitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+
+	return positionDigits[0]
+}
@@ -0,0 +1,163 @@
+package node
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPositionAt(t *testing.T) {
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{0, "0000001"},
+		{1, "0000011"},
+		{35, "00000z1"},
+		{36, "0000101"},
+		{36*36*36*36*36*36 - 1, "zzzzzz1"},
+	}
+
+	for _, tt := range tests {
+		if got := positionAt(tt.index); got != tt.want {
+			t.Errorf("positionAt(%d) = %q, want %q", tt.index, got, tt.want)
+		}
+	}
+
+	for i := 0; i < 5000; i++ {
+		if a, b := positionAt(i), positionAt(i+1); a >= b {
+			t.Fatalf("positionAt(%d) = %q does not sort before positionAt(%d) = %q", i, a, i+1, b)
+		}
+	}
+}
+
+func TestPositionBetween(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		want          string
+		ok            bool
+	}{
+		{"empty list", "", "", "i", true},
+		{"before first", "", "0000001", "0000000i", true},
+		{"after last", "zzzzzz1", "", "zzzzzzj", true},
+		{"adjacent digits", "1", "2", "1i", true},
+		{"gap between digits", "1", "3", "2", true},
+		{"sequential", "0000001", "0000011", "000001", true},
+		{"prefix", "0000011", "00000111", "00000110i", true},
+		{"equal", "0000011", "0000011", "", false},
+		{"reversed", "0000021", "0000011", "", false},
+		{"before ends in smallest digit", "10", "", "", false},
+		{"after ends in smallest digit", "", "10", "", false},
+		{"invalid digit", "A", "", "", false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := positionBetween(tt.before, tt.after)
+			if got != tt.want || ok != tt.ok {
+				t.Fatalf("positionBetween(%q, %q) = %q, %t, want %q, %t", tt.before, tt.after, got, ok, tt.want, tt.ok)
+			}
+
+			if ok && !between(tt.before, got, tt.after) {
+				t.Errorf("positionBetween(%q, %q) = %q does not sort between them", tt.before, tt.after, got)
+			}
+		})
+	}
+}
+
+func TestMidpoint(t *testing.T) {
+	tests := []struct {
+		before, after string
+		want          string
+	}{
+		{"", "", "i"},
+		{"i", "j", "ii"},
+		{"i", "", "r"},
+		{"", "1", "0i"},
+		{"01", "1", "0j"},
+		{"0000001", "0000011", "000001"},
+	}
+
+	for _, tt := range tests {
+		if got := midpoint(tt.before, tt.after); got != tt.want {
+			t.Errorf("midpoint(%q, %q) = %q, want %q", tt.before, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestPositionAfter(t *testing.T) {
+	tests := []struct {
+		name string
+		p    string
+		want string
+	}{
+		{"no children", "", "0000001"},
+		{"sequential", "0000001", "0000011"},
+		{"carry", "00000z1", "0000101"},
+		{"between sequential", "0000011i", "0000021"},
+		{"short", "i", "i000011"},
+		{"out of numbers", "zzzzzz1", "zzzzzz11"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := positionAfter(tt.p)
+			if got != tt.want {
+				t.Errorf("positionAfter(%q) = %q, want %q", tt.p, got, tt.want)
+			}
+			if got <= tt.p {
+				t.Errorf("positionAfter(%q) = %q does not sort after it", tt.p, got)
+			}
+		})
+	}
+}
+
+// TestPositionsStayOrdered inserts and appends positions at random, as
+// InsertChild does, and checks they stay unique and ordered.
+func TestPositionsStayOrdered(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	positions := []string{}
+
+	for i := 0; i < 2000; i++ {
+		at := r.Intn(len(positions) + 1)
+
+		p := ""
+		if at == len(positions) {
+			last := ""
+			if at > 0 {
+				last = positions[at-1]
+			}
+			p = positionAfter(last)
+		} else {
+			before := ""
+			if at > 0 {
+				before = positions[at-1]
+			}
+			var ok bool
+			if p, ok = positionBetween(before, positions[at]); !ok {
+				t.Fatalf("no position between %q and %q", before, positions[at])
+			}
+		}
+
+		positions = append(positions[:at], append([]string{p}, positions[at:]...)...)
+	}
+
+	if !sort.StringsAreSorted(positions) {
+		t.Fatal("positions are not in order")
+	}
+	for i := 1; i < len(positions); i++ {
+		if positions[i-1] == positions[i] {
+			t.Fatalf("position %q was handed out twice", positions[i])
+		}
+	}
+}
+
+// between returns true if p sorts strictly between before and after, an
+// empty after meaning there is no upper bound.
+func between(before, p, after string) bool {
+	return before < p && (after == "" || p < after) && !strings.HasSuffix(p, positionDigits[:1])
+}
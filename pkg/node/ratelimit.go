@@ -0,0 +1,168 @@
+package node
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/erumble/dynamo-playground/pkg/ratelimit"
+)
+
+// WithRateLimiter meters every call the Client makes to DynamoDB against the
+// given ratelimit.Limiter. The Limiter can be shared between Clients.
+func WithRateLimiter(l *ratelimit.Limiter) ClientOption {
+	return func(c *Client) {
+		c.limiter = l
+	}
+}
+
+// RateLimitMiddleware blocks each call until the Limiter has enough read or
+// write capacity for it. The cost of a call is estimated up front and then
+// corrected using the consumed capacity reported by DynamoDB, when available.
+// NewClient installs it automatically when WithRateLimiter is given.
+func RateLimitMiddleware(l *ratelimit.Limiter) Middleware {
+	return Intercept(func(operation string, input interface{}, next Invoker) (interface{}, error) {
+		estimate, write := estimateCapacity(input)
+		if estimate == 0 {
+			return next(operation, input)
+		}
+
+		if write {
+			l.WaitWrite(estimate)
+		} else {
+			l.WaitRead(estimate)
+		}
+
+		out, err := next(operation, input)
+
+		if consumed, ok := consumedCapacity(out); ok {
+			if write {
+				l.AdjustWrite(consumed - estimate)
+			} else {
+				l.AdjustRead(consumed - estimate)
+			}
+		}
+
+		return out, err
+	})
+}
+
+// estimateCapacity guesses the capacity units the given input will consume,
+// and whether they are write units. It also asks DynamoDB to report the
+// actual consumed capacity so the guess can be corrected.
+func estimateCapacity(input interface{}) (float64, bool) {
+	indexes := aws.String(dynamodb.ReturnConsumedCapacityIndexes)
+
+	switch in := input.(type) {
+	case *dynamodb.DescribeTableInput:
+		// Control plane calls do not consume capacity.
+		return 0, false
+
+	case *dynamodb.GetItemInput:
+		if in.ReturnConsumedCapacity == nil {
+			in.ReturnConsumedCapacity = indexes
+		}
+		return 1, false
+
+	case *dynamodb.BatchGetItemInput:
+		if in.ReturnConsumedCapacity == nil {
+			in.ReturnConsumedCapacity = indexes
+		}
+		keys := 0
+		for _, ka := range in.RequestItems {
+			keys += len(ka.Keys)
+		}
+		return float64(keys), false
+
+	case *dynamodb.QueryInput:
+		if in.ReturnConsumedCapacity == nil {
+			in.ReturnConsumedCapacity = indexes
+		}
+		return 1, false
+
+	case *dynamodb.ScanInput:
+		if in.ReturnConsumedCapacity == nil {
+			in.ReturnConsumedCapacity = indexes
+		}
+		return 1, false
+
+	case *dynamodb.PutItemInput:
+		if in.ReturnConsumedCapacity == nil {
+			in.ReturnConsumedCapacity = indexes
+		}
+		return 1, true
+
+	case *dynamodb.DeleteItemInput:
+		if in.ReturnConsumedCapacity == nil {
+			in.ReturnConsumedCapacity = indexes
+		}
+		return 1, true
+
+	case *dynamodb.UpdateItemInput:
+		if in.ReturnConsumedCapacity == nil {
+			in.ReturnConsumedCapacity = indexes
+		}
+		return 1, true
+
+	case *dynamodb.BatchWriteItemInput:
+		if in.ReturnConsumedCapacity == nil {
+			in.ReturnConsumedCapacity = indexes
+		}
+		requests := 0
+		for _, wr := range in.RequestItems {
+			requests += len(wr)
+		}
+		return float64(requests), true
+	}
+
+	return 1, false
+}
+
+// consumedCapacity sums the capacity units reported in the given output.
+// It returns false if DynamoDB did not report any.
+func consumedCapacity(output interface{}) (float64, bool) {
+	ccs := []*dynamodb.ConsumedCapacity{}
+
+	switch out := output.(type) {
+	case *dynamodb.GetItemOutput:
+		if out != nil {
+			ccs = append(ccs, out.ConsumedCapacity)
+		}
+	case *dynamodb.BatchGetItemOutput:
+		if out != nil {
+			ccs = append(ccs, out.ConsumedCapacity...)
+		}
+	case *dynamodb.QueryOutput:
+		if out != nil {
+			ccs = append(ccs, out.ConsumedCapacity)
+		}
+	case *dynamodb.ScanOutput:
+		if out != nil {
+			ccs = append(ccs, out.ConsumedCapacity)
+		}
+	case *dynamodb.PutItemOutput:
+		if out != nil {
+			ccs = append(ccs, out.ConsumedCapacity)
+		}
+	case *dynamodb.DeleteItemOutput:
+		if out != nil {
+			ccs = append(ccs, out.ConsumedCapacity)
+		}
+	case *dynamodb.UpdateItemOutput:
+		if out != nil {
+			ccs = append(ccs, out.ConsumedCapacity)
+		}
+	case *dynamodb.BatchWriteItemOutput:
+		if out != nil {
+			ccs = append(ccs, out.ConsumedCapacity...)
+		}
+	}
+
+	total, ok := 0.0, false
+	for _, cc := range ccs {
+		if cc != nil && cc.CapacityUnits != nil {
+			total += *cc.CapacityUnits
+			ok = true
+		}
+	}
+
+	return total, ok
+}
@@ -0,0 +1,119 @@
+package node
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// ReadOption configures a single read from DynamoDB.
+type ReadOption func(*readOptions)
+
+type readOptions struct {
+	// projection is the list of attributes to fetch, all attributes are
+	// fetched when it is empty.
+	projection []string
+
+	// consistent requests a strongly consistent read.
+	consistent bool
+
+	// filter is applied to queries, see FindInSubtree.
+	filter *filterExpression
+}
+
+// filterExpression is a FilterExpression with the names and values it uses.
+type filterExpression struct {
+	expression string
+	names      map[string]*string
+	values     map[string]*dynamodb.AttributeValue
+}
+
+// withFilter applies the given filter to a query.
+func withFilter(f *filterExpression) ReadOption {
+	return func(o *readOptions) {
+		o.filter = f
+	}
+}
+
+// WithConsistentReads makes strongly consistent reads the default for every
+// read the Client makes, it can be overridden per call with ConsistentRead.
+//
+// The ParentID GSI cannot be read consistently, so when a read is consistent
+// GetChildren and GetSiblings do not query it. Instead, they Get the parent
+// and resolve its ChildIDs with a consistent BatchGet, which costs more
+// capacity, but reflects every write that has completed.
+func WithConsistentReads() ClientOption {
+	return func(c *Client) {
+		c.consistentReads = true
+	}
+}
+
+// ConsistentRead sets whether the read is strongly consistent, overriding the
+// default set with WithConsistentReads.
+func ConsistentRead(consistent bool) ReadOption {
+	return func(o *readOptions) {
+		o.consistent = consistent
+	}
+}
+
+// KeysOnly fetches only the ID of each Node.
+func KeysOnly() ReadOption {
+	return Project()
+}
+
+// StructureOnly fetches only the attributes needed to traverse and change a
+// tree, i.e. ID, ParentID, ChildIDs, ChildCount, Position and
+// LastChildPosition, leaving Metadata behind.
+func StructureOnly() ReadOption {
+	return Project("ParentID", "ChildIDs", "ChildCount", "Position", "LastChildPosition")
+}
+
+// Project fetches only the given attributes of each Node. The ID is always
+// fetched, whether it is given or not.
+func Project(attributes ...string) ReadOption {
+	return func(o *readOptions) {
+		o.projection = []string{"ID"}
+		for _, attr := range attributes {
+			if attr != "ID" {
+				o.projection = append(o.projection, attr)
+			}
+		}
+	}
+}
+
+// readOptions applies the given ReadOptions on top of the Client's defaults.
+func (c Client) readOptions(opts []ReadOption) readOptions {
+	o := readOptions{consistent: c.consistentReads}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// partial returns true if the read only fetches some of the attributes.
+func (o readOptions) partial() bool {
+	return len(o.projection) > 0
+}
+
+// projectionExpression returns the ProjectionExpression for the read, and
+// the ExpressionAttributeNames it refers to. Every attribute is aliased so
+// reserved words can be projected safely. It returns nil if all attributes
+// are to be fetched.
+func (o readOptions) projectionExpression() (*string, map[string]*string) {
+	if !o.partial() {
+		return nil, nil
+	}
+
+	names := map[string]*string{}
+	aliases := []string{}
+	for _, attr := range o.projection {
+		alias := fmt.Sprintf("#proj%d", len(aliases))
+		aliases = append(aliases, alias)
+		names[alias] = aws.String(attr)
+	}
+
+	return aws.String(strings.Join(aliases, ", ")), names
+}
@@ -0,0 +1,272 @@
+package node
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/erumble/dynamo-playground/pkg/logger/loggertest"
+	"github.com/erumble/dynamo-playground/pkg/node/dynamotest"
+)
+
+// readInput is what a read asked DynamoDB for.
+type readInput struct {
+	operation  string
+	projection []string
+	consistent bool
+}
+
+// recordReads returns a middleware that appends every GetItem, BatchGetItem
+// and Query to reads, with the attributes it projects sorted by name.
+func recordReads(reads *[]readInput) Middleware {
+	return Intercept(func(operation string, input interface{}, next Invoker) (interface{}, error) {
+		var projection *string
+		var names map[string]*string
+		var consistent *bool
+
+		switch in := input.(type) {
+		case *dynamodb.GetItemInput:
+			projection, names, consistent = in.ProjectionExpression, in.ExpressionAttributeNames, in.ConsistentRead
+		case *dynamodb.BatchGetItemInput:
+			for _, ka := range in.RequestItems {
+				projection, names, consistent = ka.ProjectionExpression, ka.ExpressionAttributeNames, ka.ConsistentRead
+			}
+		case *dynamodb.QueryInput:
+			projection, names, consistent = in.ProjectionExpression, in.ExpressionAttributeNames, in.ConsistentRead
+		default:
+			return next(operation, input)
+		}
+
+		r := readInput{operation: operation, consistent: aws.BoolValue(consistent)}
+		if projection != nil {
+			for _, alias := range strings.Split(aws.StringValue(projection), ", ") {
+				r.projection = append(r.projection, aws.StringValue(names[alias]))
+			}
+			sort.Strings(r.projection)
+		}
+		*reads = append(*reads, r)
+
+		return next(operation, input)
+	})
+}
+
+func TestProjection(t *testing.T) {
+	reads := []readInput{}
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents", WithMiddleware(recordReads(&reads)))
+
+	parent, children := family(1, 2)
+	parent.Metadata = "parent"
+	children[0].Metadata = "first"
+	children[1].Metadata = "second"
+	if err := c.BatchPut(append([]*Node{parent}, children...)); err != nil {
+		t.Fatalf("BatchPut: %v", err)
+	}
+
+	structure := []string{"ChildCount", "ChildIDs", "ID", "LastChildPosition", "ParentID", "Position"}
+
+	tests := []struct {
+		name       string
+		read       func() ([]*Node, error)
+		operation  string
+		projection []string
+		metadata   []string
+	}{
+		{
+			name: "Get",
+			read: func() ([]*Node, error) {
+				n, err := c.Get(parent.ID)
+				return []*Node{n}, err
+			},
+			operation: "GetItem",
+			metadata:  []string{"parent"},
+		},
+		{
+			name: "Get StructureOnly",
+			read: func() ([]*Node, error) {
+				n, err := c.Get(parent.ID, StructureOnly())
+				return []*Node{n}, err
+			},
+			operation:  "GetItem",
+			projection: structure,
+			metadata:   []string{""},
+		},
+		{
+			name: "BatchGet KeysOnly",
+			read: func() ([]*Node, error) {
+				return c.BatchGet([]string{children[0].ID, children[1].ID}, KeysOnly())
+			},
+			operation:  "BatchGetItem",
+			projection: []string{"ID"},
+			metadata:   []string{"", ""},
+		},
+		{
+			name: "GetChildren Project",
+			read: func() ([]*Node, error) {
+				return c.GetChildren(*parent, Project("Metadata", "ID"))
+			},
+			operation:  "Query",
+			projection: []string{"ID", "Metadata"},
+			metadata:   []string{"first", "second"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reads = reads[:0]
+
+			nodes, err := tt.read()
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+
+			want := []readInput{{operation: tt.operation, projection: tt.projection}}
+			if !reflect.DeepEqual(reads, want) {
+				t.Errorf("DynamoDB was asked for %+v, want %+v", reads, want)
+			}
+
+			metadata := []string{}
+			for _, n := range nodes {
+				metadata = append(metadata, n.Metadata)
+				if n.Partial != (tt.projection != nil) {
+					t.Errorf("node %s Partial = %v with projection %v", n.ID, n.Partial, tt.projection)
+				}
+				if tt.projection != nil && n.ID == "" {
+					t.Error("a projected node is missing its ID")
+				}
+			}
+			sort.Strings(metadata)
+			if !reflect.DeepEqual(metadata, tt.metadata) {
+				t.Errorf("read Metadata %q, want %q", metadata, tt.metadata)
+			}
+		})
+	}
+}
+
+func TestPartialWrite(t *testing.T) {
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents")
+
+	if err := c.Put(Node{ID: "a", Metadata: "keep me"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	partial, err := c.Get("a", KeysOnly())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	if err := c.Put(*partial); err == nil {
+		t.Error("Put of a partially loaded node returned no error")
+	}
+	if err := c.BatchPut([]*Node{partial}); err == nil {
+		t.Error("BatchPut of a partially loaded node returned no error")
+	}
+
+	full, err := c.Get("a")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if full.Metadata != "keep me" {
+		t.Errorf("Metadata = %q after rejected writes, want it unchanged", full.Metadata)
+	}
+}
+
+func TestConsistentRead(t *testing.T) {
+	parent, children := family(1, 2)
+
+	tests := []struct {
+		name       string
+		opts       []ClientOption
+		read       []ReadOption
+		consistent bool
+	}{
+		{"default", nil, nil, false},
+		{"per read", nil, []ReadOption{ConsistentRead(true)}, true},
+		{"client default", []ClientOption{WithConsistentReads()}, nil, true},
+		{"overridden", []ClientOption{WithConsistentReads()}, []ReadOption{ConsistentRead(false)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reads := []readInput{}
+			c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents",
+				append(tt.opts, WithMiddleware(recordReads(&reads)))...)
+			if err := c.BatchPut(append([]*Node{parent}, children...)); err != nil {
+				t.Fatalf("BatchPut: %v", err)
+			}
+
+			if _, err := c.Get(parent.ID, tt.read...); err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if _, err := c.BatchGet([]string{children[0].ID, children[1].ID}, tt.read...); err != nil {
+				t.Fatalf("BatchGet: %v", err)
+			}
+
+			for _, r := range reads {
+				if r.consistent != tt.consistent {
+					t.Errorf("%s ConsistentRead = %v, want %v", r.operation, r.consistent, tt.consistent)
+				}
+			}
+			if len(reads) != 2 {
+				t.Errorf("made reads %+v, want a GetItem and a BatchGetItem", reads)
+			}
+		})
+	}
+}
+
+func TestConsistentGetChildren(t *testing.T) {
+	reads := []readInput{}
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents",
+		WithConsistentReads(), WithMiddleware(recordReads(&reads)))
+
+	parent, children := family(1, 3)
+	// The order of ChildIDs wins over Position, which the GSI would sort by.
+	parent.ChildIDs = []string{children[2].ID, children[0].ID, children[1].ID}
+	if err := c.BatchPut(append([]*Node{parent}, children...)); err != nil {
+		t.Fatalf("BatchPut: %v", err)
+	}
+
+	got, err := c.GetChildren(*parent)
+	if err != nil {
+		t.Fatalf("GetChildren: %v", err)
+	}
+
+	ids := []string{}
+	for _, n := range got {
+		ids = append(ids, n.ID)
+	}
+	if !reflect.DeepEqual(ids, parent.ChildIDs) {
+		t.Errorf("GetChildren = %v, want the parent's ChildIDs %v", ids, parent.ChildIDs)
+	}
+
+	operations := []string{}
+	for _, r := range reads {
+		operations = append(operations, r.operation)
+		if !r.consistent {
+			t.Errorf("%s was not consistent", r.operation)
+		}
+	}
+	if !reflect.DeepEqual(operations, []string{"GetItem", "BatchGetItem"}) {
+		t.Errorf("GetChildren called %v, want the parent fetched and its ChildIDs resolved without a Query", operations)
+	}
+
+	reads = reads[:0]
+	siblings, err := c.GetSiblings(*children[0], ConsistentRead(false))
+	if err != nil {
+		t.Fatalf("GetSiblings: %v", err)
+	}
+	if len(siblings) != 3 || len(reads) != 1 || reads[0].operation != "Query" {
+		t.Errorf("inconsistent GetSiblings returned %d nodes with %+v, want all 3 from a Query", len(siblings), reads)
+	}
+}
+
+func TestConsistentGetChildrenIndexed(t *testing.T) {
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents", WithChildStorage(IndexedChildren))
+
+	parent, _ := family(1, 2)
+	if _, err := c.GetChildren(*parent, ConsistentRead(true)); err == nil {
+		t.Error("GetChildren read consistently with IndexedChildren, want an error")
+	}
+}
@@ -0,0 +1,26 @@
+package node
+
+import (
+	"time"
+)
+
+const (
+	// maxBatchRetries is how many times unprocessed items from a batch call are
+	// resubmitted before giving up.
+	maxBatchRetries = 5
+
+	// baseRetryDelay is the delay before the first resubmission, it doubles on
+	// every subsequent attempt.
+	baseRetryDelay = 50 * time.Millisecond
+
+	// maxBatchGetKeys is the most keys DynamoDB accepts in a single BatchGetItem.
+	maxBatchGetKeys = 100
+
+	// maxBatchWriteItems is the most items DynamoDB accepts in a single BatchWriteItem.
+	maxBatchWriteItems = 25
+)
+
+// retryBackoff returns how long to wait before the given (zero based) retry attempt.
+func retryBackoff(attempt int) time.Duration {
+	return baseRetryDelay << uint(attempt)
+}
@@ -0,0 +1,53 @@
+package node
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/pkg/errors"
+)
+
+// GetAll fetches every Node in the table, in no particular order.
+// It reads the whole table, so it is meant for tooling rather than for
+// serving requests.
+func (c Client) GetAll(opts ...ReadOption) ([]*Node, error) {
+	log := c.log.Indent("GetAll")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	log.Debug("generating ScanInput...")
+	ro := c.readOptions(opts)
+	projection, names := ro.projectionExpression()
+
+	input := &dynamodb.ScanInput{
+		ConsistentRead:           aws.Bool(ro.consistent),
+		ExpressionAttributeNames: names,
+		ProjectionExpression:     projection,
+		TableName:                aws.String(c.tableName),
+	}
+
+	log.Debugf("ScanInput:\n%v", c.dump(input))
+
+	nodes := []*Node{}
+	for {
+		log.Debug("calling Scan...")
+		res, err := c.dataStore.Scan(input)
+		if err != nil {
+			return nil, errors.Wrap(err, "Client.GetAll: error retrieving data from DynamoDB")
+		}
+
+		log.Debugf("ScanOutput:\n%v", c.dump(res))
+
+		page, err := c.unmarshalList(res.Items, ro.partial())
+		if err != nil {
+			return nil, errors.Wrap(err, "Client.GetAll: error unmarshalling results into type Node")
+		}
+		nodes = append(nodes, page...)
+
+		if len(res.LastEvaluatedKey) == 0 {
+			return nodes, nil
+		}
+
+		log.Debug("fetching next page...")
+		input.ExclusiveStartKey = res.LastEvaluatedKey
+	}
+}
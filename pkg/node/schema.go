@@ -0,0 +1,118 @@
+package node
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/pkg/errors"
+)
+
+// SchemaError lists everything about the table that does not match what the
+// Client was configured with.
+type SchemaError struct {
+	Table    string
+	Problems []string
+}
+
+func (e SchemaError) Error() string {
+	return fmt.Sprintf("table %s does not match the client configuration:\n  - %s", e.Table, strings.Join(e.Problems, "\n  - "))
+}
+
+// ValidateSchema checks that the table, the ParentID GSI and every
+// MetadataIndex are keyed the way the Client expects, and that every
+// MetadataIndex is usable, so a misconfigured table or Client is caught
+// before the first read or write. It returns a SchemaError
+// listing every problem found.
+func (c Client) ValidateSchema() error {
+	log := c.log.Indent("ValidateSchema")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	log.Debug("calling DescribeTable...")
+	res, err := c.dataStore.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(c.tableName)})
+	if err != nil {
+		return errors.Wrap(err, "Client.ValidateSchema: error describing table")
+	}
+
+	table := res.Table
+	types := map[string]string{}
+	for _, ad := range table.AttributeDefinitions {
+		types[aws.StringValue(ad.AttributeName)] = aws.StringValue(ad.AttributeType)
+	}
+
+	problems := []string{}
+	check := func(what string, schema []*dynamodb.KeySchemaElement, hash, rng string) {
+		want := map[string]string{dynamodb.KeyTypeHash: hash, dynamodb.KeyTypeRange: rng}
+		got := map[string]string{}
+		for _, ks := range schema {
+			got[aws.StringValue(ks.KeyType)] = aws.StringValue(ks.AttributeName)
+		}
+
+		for _, kt := range []string{dynamodb.KeyTypeHash, dynamodb.KeyTypeRange} {
+			if got[kt] != want[kt] {
+				problems = append(problems, fmt.Sprintf("%s: %s key should be %q, found %q", what, kt, want[kt], got[kt]))
+			} else if want[kt] != "" && types[want[kt]] != dynamodb.ScalarAttributeTypeS {
+				problems = append(problems, fmt.Sprintf("%s: %s key %s should be a string", what, kt, want[kt]))
+			}
+		}
+	}
+
+	check("table", table.KeySchema, "ID", "")
+
+	gsis := map[string]*dynamodb.GlobalSecondaryIndexDescription{}
+	for _, gsi := range table.GlobalSecondaryIndexes {
+		gsis[aws.StringValue(gsi.IndexName)] = gsi
+	}
+
+	if gsi, ok := gsis[c.gsiName]; !ok {
+		problems = append(problems, fmt.Sprintf("GSI %s does not exist", c.gsiName))
+	} else {
+		check("GSI "+c.gsiName, gsi.KeySchema, "ParentID", "Position")
+
+		if gsi.Projection == nil || aws.StringValue(gsi.Projection.ProjectionType) != dynamodb.ProjectionTypeAll {
+			problems = append(problems, fmt.Sprintf("GSI %s should project all attributes", c.gsiName))
+		}
+	}
+
+	names := []string{}
+	for name := range c.metadataIndexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		idx := c.metadataIndexes[name]
+		if err := idx.validate(); err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+
+		gsi, ok := gsis[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("GSI %s does not exist", name))
+			continue
+		}
+
+		got := ""
+		for _, ks := range gsi.KeySchema {
+			if aws.StringValue(ks.KeyType) == dynamodb.KeyTypeHash {
+				got = aws.StringValue(ks.AttributeName)
+			}
+		}
+
+		if got != idx.Attribute {
+			problems = append(problems, fmt.Sprintf("GSI %s: HASH key should be %q, found %q", name, idx.Attribute, got))
+		} else if types[got] != dynamodb.ScalarAttributeTypeS {
+			problems = append(problems, fmt.Sprintf("GSI %s: HASH key %s should be a string", name, got))
+		}
+	}
+
+	if len(problems) > 0 {
+		return SchemaError{Table: c.tableName, Problems: problems}
+	}
+
+	return nil
+}
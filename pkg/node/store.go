@@ -0,0 +1,34 @@
+package node
+
+// Store is the storage a tree of Nodes is kept in. Client is the DynamoDB
+// Store, MemoryStore and FileStore keep the Nodes in process, for tests,
+// local development and edge deployments. storetest.Run checks that a Store
+// behaves the same as the others.
+//
+// Every Store follows the Client's rules:
+//   - Get returns a Node with only its ID set, and no error, if there is no
+//     Node with that ID.
+//   - BatchGet leaves out the IDs it cannot find, and does not order the Nodes.
+//   - GetChildren returns nothing for a Node without children, and the
+//     children of a Node otherwise, in Position order. GetSiblings returns the
+//     children of the Node's parent, the Node included.
+//   - Put and BatchPut refuse partially loaded Nodes and Nodes that fail
+//     Validate, writing nothing.
+//   - Delete removes only the given Node, not its children, and does not
+//     update its parent.
+//   - The Nodes returned are copies, changing them does not change the Store.
+type Store interface {
+	Get(id string, opts ...ReadOption) (*Node, error)
+	BatchGet(ids []string, opts ...ReadOption) ([]*Node, error)
+	GetChildren(n Node, opts ...ReadOption) ([]*Node, error)
+	GetSiblings(n Node, opts ...ReadOption) ([]*Node, error)
+	Put(in Node) error
+	BatchPut(in []*Node) error
+	Delete(in *Node) error
+}
+
+var (
+	_ Store = Client{}
+	_ Store = &MemoryStore{}
+	_ Store = &FileStore{}
+)
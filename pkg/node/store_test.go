@@ -0,0 +1,68 @@
+package node_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/erumble/dynamo-playground/pkg/logger/loggertest"
+	"github.com/erumble/dynamo-playground/pkg/node"
+	"github.com/erumble/dynamo-playground/pkg/node/dynamotest"
+	"github.com/erumble/dynamo-playground/pkg/node/storetest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) node.Store {
+		return node.NewMemoryStore()
+	})
+}
+
+func TestFileStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) node.Store {
+		s, err := node.OpenFileStore(filepath.Join(t.TempDir(), "nodes.ndjson"))
+		if err != nil {
+			t.Fatalf("OpenFileStore: %v", err)
+		}
+
+		return s
+	})
+}
+
+// TestFileStoreReopen checks that what one FileStore writes, the next one
+// reads back.
+func TestFileStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes.ndjson")
+
+	s, err := node.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+
+	root := node.NewWithGenerator(nil, node.NewDeterministicGenerator(1))
+	child := root.CreateChild()
+	if err := s.BatchPut([]*node.Node{root, child}); err != nil {
+		t.Fatalf("BatchPut: %v", err)
+	}
+
+	reopened, err := node.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+
+	children, err := reopened.GetChildren(*root)
+	if err != nil {
+		t.Fatalf("GetChildren: %v", err)
+	}
+	if len(children) != 1 || children[0].ID != child.ID || children[0].Position != child.Position {
+		t.Errorf("GetChildren after reopening = %+v, want %+v", children, child)
+	}
+}
+
+var _ node.DynamoDBIFace = dynamotest.New()
+
+// TestClientStore runs the Store conformance suite against a Client backed
+// by an in-memory fake of DynamoDB.
+func TestClientStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) node.Store {
+		return node.NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents")
+	})
+}
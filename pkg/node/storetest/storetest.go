@@ -0,0 +1,327 @@
+// Package storetest is a conformance suite for node.Store implementations.
+// Call Run from a test in the package that implements the Store:
+//
+//	func TestMemoryStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) node.Store {
+//			return node.NewMemoryStore()
+//		})
+//	}
+package storetest
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/erumble/dynamo-playground/pkg/node"
+)
+
+// Run checks that the Stores returned by newStore follow the rules described
+// by node.Store. newStore is called once per subtest, and must return an
+// empty Store.
+func Run(t *testing.T, newStore func(t *testing.T) node.Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s node.Store)
+	}{
+		{"Get", testGet},
+		{"GetMissing", testGetMissing},
+		{"GetReturnsCopies", testGetReturnsCopies},
+		{"Projection", testProjection},
+		{"BatchGet", testBatchGet},
+		{"BatchGetMany", testBatchGetMany},
+		{"GetChildren", testGetChildren},
+		{"GetSiblings", testGetSiblings},
+		{"PutOverwrites", testPutOverwrites},
+		{"PutRejectsPartial", testPutRejectsPartial},
+		{"PutRejectsInvalid", testPutRejectsInvalid},
+		{"BatchPutMany", testBatchPutMany},
+		{"BatchPutAllOrNothing", testBatchPutAllOrNothing},
+		{"Delete", testDelete},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+// tree stores a root with three children, the first of which has a child
+// of its own, and returns them in that order.
+func tree(t *testing.T, s node.Store) (root, a, b, c, d *node.Node) {
+	gen := node.NewDeterministicGenerator(1)
+	root = node.NewWithGenerator(nil, gen)
+	root.Metadata = "root"
+	a, b, c = root.CreateChild(), root.CreateChild(), root.CreateChild()
+	d = a.CreateChild()
+
+	if err := s.BatchPut([]*node.Node{root, a, b, c, d}); err != nil {
+		t.Fatalf("BatchPut: %v", err)
+	}
+
+	return root, a, b, c, d
+}
+
+func testGet(t *testing.T, s node.Store) {
+	root, a, _, _, _ := tree(t, s)
+
+	got, err := s.Get(a.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	if got.ID != a.ID || got.ParentID != root.ID || got.Position != a.Position || got.NumChildren() != 1 || got.Partial {
+		t.Errorf("Get = %+v, want %+v", got, a)
+	}
+}
+
+func testGetMissing(t *testing.T, s node.Store) {
+	got, err := s.Get("missing")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	if got.ID != "missing" || got.ParentID != "" || got.HasChildren() || got.Metadata != "" {
+		t.Errorf("Get of a missing Node = %+v, want only its ID", got)
+	}
+}
+
+func testGetReturnsCopies(t *testing.T, s node.Store) {
+	root, _, _, _, _ := tree(t, s)
+
+	got, err := s.Get(root.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got.Metadata = "changed"
+	got.ChildIDs[0] = "changed"
+
+	again, err := s.Get(root.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	if again.Metadata != "root" || again.ChildIDs[0] != root.ChildIDs[0] {
+		t.Errorf("changing a returned Node changed the Store: %+v", again)
+	}
+}
+
+func testProjection(t *testing.T, s node.Store) {
+	root, a, _, _, _ := tree(t, s)
+
+	got, err := s.Get(root.ID, node.KeysOnly())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.ID != root.ID || got.Metadata != "" || got.HasChildren() || !got.Partial {
+		t.Errorf("Get with KeysOnly = %+v, want only the ID, marked Partial", got)
+	}
+
+	children, err := s.GetChildren(*root, node.StructureOnly())
+	if err != nil {
+		t.Fatalf("GetChildren: %v", err)
+	}
+	if len(children) != 3 || children[0].ID != a.ID || children[0].NumChildren() != 1 || !children[0].Partial {
+		t.Errorf("GetChildren with StructureOnly = %v, want the structure of 3 children, marked Partial", ids(children))
+	}
+}
+
+func testBatchGet(t *testing.T, s node.Store) {
+	root, a, _, _, d := tree(t, s)
+
+	got, err := s.BatchGet([]string{d.ID, "missing", root.ID, a.ID, root.ID})
+	if err != nil {
+		t.Fatalf("BatchGet: %v", err)
+	}
+
+	want := []string{root.ID, a.ID, d.ID}
+	if gotIDs := sorted(ids(got)); !equal(gotIDs, sorted(want)) {
+		t.Errorf("BatchGet = %v, want %v", gotIDs, sorted(want))
+	}
+}
+
+func testBatchGetMany(t *testing.T, s node.Store) {
+	root := node.NewWithGenerator(nil, node.NewDeterministicGenerator(2))
+	nodes := []*node.Node{root}
+	for i := 0; i < 250; i++ {
+		nodes = append(nodes, root.CreateChild())
+	}
+	if err := s.BatchPut(nodes); err != nil {
+		t.Fatalf("BatchPut: %v", err)
+	}
+
+	got, err := s.BatchGet(ids(nodes))
+	if err != nil {
+		t.Fatalf("BatchGet: %v", err)
+	}
+
+	if len(got) != len(nodes) {
+		t.Errorf("BatchGet returned %d Nodes, want %d", len(got), len(nodes))
+	}
+}
+
+func testGetChildren(t *testing.T, s node.Store) {
+	root, a, b, c, d := tree(t, s)
+
+	got, err := s.GetChildren(*root)
+	if err != nil {
+		t.Fatalf("GetChildren: %v", err)
+	}
+	if want := ids([]*node.Node{a, b, c}); !equal(ids(got), want) {
+		t.Errorf("GetChildren = %v, want %v in Position order", ids(got), want)
+	}
+
+	got, err = s.GetChildren(*d)
+	if err != nil {
+		t.Fatalf("GetChildren: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("GetChildren of a leaf = %v, want none", ids(got))
+	}
+}
+
+func testGetSiblings(t *testing.T, s node.Store) {
+	root, a, b, c, _ := tree(t, s)
+
+	got, err := s.GetSiblings(*b)
+	if err != nil {
+		t.Fatalf("GetSiblings: %v", err)
+	}
+	if want := ids([]*node.Node{a, b, c}); !equal(ids(got), want) {
+		t.Errorf("GetSiblings = %v, want %v in Position order", ids(got), want)
+	}
+
+	got, err = s.GetSiblings(*root)
+	if err != nil {
+		t.Fatalf("GetSiblings: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("GetSiblings of a root = %v, want none", ids(got))
+	}
+}
+
+func testPutOverwrites(t *testing.T, s node.Store) {
+	_, a, _, _, _ := tree(t, s)
+
+	updated := *a
+	updated.Metadata = "updated"
+	if err := s.Put(updated); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got, err := s.Get(a.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Metadata != "updated" || got.ParentID != a.ParentID {
+		t.Errorf("Get after Put = %+v, want %+v", got, updated)
+	}
+}
+
+func testPutRejectsPartial(t *testing.T, s node.Store) {
+	root, _, _, _, _ := tree(t, s)
+
+	partial, err := s.Get(root.ID, node.KeysOnly())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	if err := s.Put(*partial); err == nil {
+		t.Error("Put of a partially loaded Node succeeded, want an error")
+	}
+
+	got, err := s.Get(root.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Metadata != "root" {
+		t.Errorf("rejected Put changed the Node: %+v", got)
+	}
+}
+
+func testPutRejectsInvalid(t *testing.T, s node.Store) {
+	if err := s.Put(node.Node{ID: "self", ParentID: "self"}); err == nil {
+		t.Error("Put of a Node that is its own parent succeeded, want an error")
+	}
+
+	if err := s.Put(node.Node{}); err == nil {
+		t.Error("Put of a Node without an ID succeeded, want an error")
+	}
+}
+
+func testBatchPutMany(t *testing.T, s node.Store) {
+	root := node.NewWithGenerator(nil, node.NewDeterministicGenerator(3))
+	nodes := []*node.Node{root}
+	for i := 0; i < 60; i++ {
+		nodes = append(nodes, root.CreateChild())
+	}
+	if err := s.BatchPut(nodes); err != nil {
+		t.Fatalf("BatchPut: %v", err)
+	}
+
+	got, err := s.GetChildren(*root)
+	if err != nil {
+		t.Fatalf("GetChildren: %v", err)
+	}
+	if want := ids(nodes[1:]); !equal(ids(got), want) {
+		t.Errorf("GetChildren returned %d Nodes, want all %d in Position order", len(got), len(want))
+	}
+}
+
+func testBatchPutAllOrNothing(t *testing.T, s node.Store) {
+	good := &node.Node{ID: "good"}
+	bad := &node.Node{ID: "bad", ParentID: "bad"}
+
+	if err := s.BatchPut([]*node.Node{good, bad}); err == nil {
+		t.Fatal("BatchPut with an invalid Node succeeded, want an error")
+	}
+
+	got, err := s.BatchGet([]string{good.ID})
+	if err != nil {
+		t.Fatalf("BatchGet: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("rejected BatchPut stored %v", ids(got))
+	}
+}
+
+func testDelete(t *testing.T, s node.Store) {
+	root, a, _, _, d := tree(t, s)
+
+	if err := s.Delete(a); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	got, err := s.BatchGet([]string{root.ID, a.ID, d.ID})
+	if err != nil {
+		t.Fatalf("BatchGet: %v", err)
+	}
+
+	// Only the given Node is removed, its parent and children are untouched.
+	want := sorted([]string{root.ID, d.ID})
+	if gotIDs := sorted(ids(got)); !equal(gotIDs, want) {
+		t.Errorf("BatchGet after Delete = %v, want %v", gotIDs, want)
+	}
+}
+
+func ids(nodes []*node.Node) []string {
+	out := []string{}
+	for _, n := range nodes {
+		out = append(out, n.ID)
+	}
+
+	return out
+}
+
+func sorted(s []string) []string {
+	out := append([]string{}, s...)
+	sort.Strings(out)
+
+	return out
+}
+
+func equal(a, b []string) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
@@ -0,0 +1,43 @@
+package node
+
+import (
+	"github.com/pkg/errors"
+)
+
+// GetSubtree fetches the Node with the given ID and all of its descendants
+// from DynamoDB. The root is first, followed by its descendants in breadth
+// first order, with siblings in Position order. Any ReadOption given must
+// leave the ChildIDs or ChildCount in place, or the traversal stops early.
+// It returns ErrNotFound if the root does not exist.
+func (c Client) GetSubtree(rootID string, opts ...ReadOption) ([]*Node, error) {
+	log := c.log.Indent("GetSubtree")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	log.Debug("fetching root...")
+	root, err := c.getExisting(rootID, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Client.GetSubtree: error fetching root")
+	}
+
+	// A corrupt table could contain a cycle, so never visit a Node twice.
+	seen := map[string]bool{root.ID: true}
+	nodes := []*Node{root}
+
+	for i := 0; i < len(nodes); i++ {
+		log.Debugf("fetching children of %s...", nodes[i].ID)
+		children, err := c.GetChildren(*nodes[i], opts...)
+		if err != nil {
+			return nil, errors.Wrap(err, "Client.GetSubtree: error fetching children")
+		}
+
+		for _, child := range children {
+			if !seen[child.ID] {
+				seen[child.ID] = true
+				nodes = append(nodes, child)
+			}
+		}
+	}
+
+	return nodes, nil
+}
@@ -0,0 +1,354 @@
+package node
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// SkipSubtree can be returned by a WalkFunc to skip the children of the
+// Node it was called with. Walk does not return it.
+var SkipSubtree = errors.New("skip this subtree")
+
+// WalkFunc is called by Tree.Walk for every Node visited, with its depth
+// below the root the walk started at.
+type WalkFunc func(n *Node, depth int) error
+
+// Inconsistency describes a problem found while building a Tree.
+type Inconsistency struct {
+	ID      string
+	Problem string
+}
+
+func (i Inconsistency) Error() string {
+	return fmt.Sprintf("node %s: %s", i.ID, i.Problem)
+}
+
+// Tree indexes a set of Nodes by ID and by parent, so they can be navigated
+// without rebuilding the relationships each time. Children are ordered by
+// Position, then by their place in the parent's ChildIDs, then by ID.
+// A Tree is not safe for concurrent modification.
+type Tree struct {
+	nodes    map[string]*Node
+	children map[string][]*Node
+	roots    []*Node
+	problems []Inconsistency
+}
+
+// NewTree builds a Tree from the given Nodes. Nodes whose parent is not in
+// the slice are roots. Problems with the input, such as duplicate IDs,
+// parents and children that disagree, or cycles, are reported by Problems;
+// the first Node with an ID wins and Nodes in a cycle are left out.
+func NewTree(nodes []*Node) *Tree {
+	t := &Tree{
+		nodes:    map[string]*Node{},
+		children: map[string][]*Node{},
+	}
+
+	ordered := []*Node{}
+	for _, n := range nodes {
+		if _, ok := t.nodes[n.ID]; ok {
+			t.report(n.ID, "duplicate ID, only the first is kept")
+			continue
+		}
+		if err := n.Validate(); err != nil {
+			t.report(n.ID, errors.Cause(err).Error())
+		}
+
+		t.nodes[n.ID] = n
+		ordered = append(ordered, n)
+	}
+
+	for _, n := range ordered {
+		for _, id := range n.ChildIDs {
+			child, ok := t.nodes[id]
+			switch {
+			case !ok:
+				continue
+			case child.ParentID != n.ID:
+				t.report(n.ID, fmt.Sprintf("lists %s as a child, but its parent is %q", id, child.ParentID))
+			}
+		}
+
+		parent, ok := t.nodes[n.ParentID]
+		if !ok || n.ParentID == n.ID {
+			t.roots = append(t.roots, n)
+			continue
+		}
+
+		if len(parent.ChildIDs) > 0 && indexOf(parent.ChildIDs, n.ID) < 0 {
+			t.report(n.ID, fmt.Sprintf("has parent %s, which does not list it as a child", parent.ID))
+		}
+		t.children[parent.ID] = append(t.children[parent.ID], n)
+	}
+
+	for id, children := range t.children {
+		sortChildren(t.nodes[id], children)
+	}
+
+	// Anything not reachable from a root is part of a cycle.
+	reached := map[string]bool{}
+	for it := t.DFS(); it.Next(); {
+		reached[it.Node().ID] = true
+	}
+	for _, n := range ordered {
+		if !reached[n.ID] {
+			t.report(n.ID, "is part of a cycle")
+		}
+	}
+
+	return t
+}
+
+// GetTree fetches the subtree rooted at the Node with the given ID, see
+// GetSubtree, and builds a Tree from it.
+func (c Client) GetTree(rootID string, opts ...ReadOption) (*Tree, error) {
+	nodes, err := c.GetSubtree(rootID, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Client.GetTree")
+	}
+
+	return NewTree(nodes), nil
+}
+
+// report records a problem found while building the Tree.
+func (t *Tree) report(id, problem string) {
+	t.problems = append(t.problems, Inconsistency{ID: id, Problem: problem})
+}
+
+// sortChildren orders the children of parent, see Tree.
+func sortChildren(parent *Node, children []*Node) {
+	sort.SliceStable(children, func(i, j int) bool {
+		a, b := children[i], children[j]
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+
+		ai, bi := indexOf(parent.ChildIDs, a.ID), indexOf(parent.ChildIDs, b.ID)
+		if ai != bi {
+			return ai < bi
+		}
+
+		return a.ID < b.ID
+	})
+}
+
+// Problems returns the inconsistencies found while building the Tree.
+func (t *Tree) Problems() []Inconsistency {
+	return t.problems
+}
+
+// Len returns the number of Nodes in the Tree.
+func (t *Tree) Len() int {
+	return len(t.nodes)
+}
+
+// Get returns the Node with the given ID, and whether it is in the Tree.
+func (t *Tree) Get(id string) (*Node, bool) {
+	n, ok := t.nodes[id]
+	return n, ok
+}
+
+// Parent returns the parent of the Node with the given ID, or nil if
+// either is not in the Tree.
+func (t *Tree) Parent(id string) *Node {
+	n, ok := t.nodes[id]
+	if !ok || n.ParentID == n.ID {
+		return nil
+	}
+
+	return t.nodes[n.ParentID]
+}
+
+// Children returns the children of the Node with the given ID, in order.
+func (t *Tree) Children(id string) []*Node {
+	return t.children[id]
+}
+
+// Roots returns the Nodes whose parent is not in the Tree.
+func (t *Tree) Roots() []*Node {
+	return t.roots
+}
+
+// Leaves returns the Nodes without children in the Tree, in depth first order.
+func (t *Tree) Leaves() []*Node {
+	leaves := []*Node{}
+	for it := t.DFS(); it.Next(); {
+		if len(t.children[it.Node().ID]) == 0 {
+			leaves = append(leaves, it.Node())
+		}
+	}
+
+	return leaves
+}
+
+// Iterator steps through the Nodes of a Tree.
+//
+//	for it := tree.DFS(); it.Next(); {
+//		n := it.Node()
+//	}
+type Iterator struct {
+	next func() *Node
+	node *Node
+}
+
+// Next advances the Iterator, it returns false when there are no Nodes left.
+func (it *Iterator) Next() bool {
+	it.node = it.next()
+	return it.node != nil
+}
+
+// Node returns the current Node.
+func (it *Iterator) Node() *Node {
+	return it.node
+}
+
+// start returns the Nodes with the given IDs, or the roots if there are none.
+func (t *Tree) start(ids []string) []*Node {
+	if len(ids) == 0 {
+		return t.roots
+	}
+
+	nodes := []*Node{}
+	for _, id := range ids {
+		if n, ok := t.nodes[id]; ok {
+			nodes = append(nodes, n)
+		}
+	}
+
+	return nodes
+}
+
+// DFS iterates over the subtrees rooted at the given IDs, or the whole
+// Tree if none are given, in depth first pre-order: parents before children.
+func (t *Tree) DFS(ids ...string) *Iterator {
+	stack := reversed(t.start(ids))
+	seen := map[string]bool{}
+
+	return &Iterator{next: func() *Node {
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if seen[n.ID] {
+				continue
+			}
+			seen[n.ID] = true
+
+			stack = append(stack, reversed(t.children[n.ID])...)
+			return n
+		}
+
+		return nil
+	}}
+}
+
+// BFS iterates over the subtrees rooted at the given IDs, or the whole
+// Tree if none are given, in breadth first order.
+func (t *Tree) BFS(ids ...string) *Iterator {
+	queue := append([]*Node{}, t.start(ids)...)
+	seen := map[string]bool{}
+
+	return &Iterator{next: func() *Node {
+		for len(queue) > 0 {
+			n := queue[0]
+			queue = queue[1:]
+			if seen[n.ID] {
+				continue
+			}
+			seen[n.ID] = true
+
+			queue = append(queue, t.children[n.ID]...)
+			return n
+		}
+
+		return nil
+	}}
+}
+
+// PostOrder iterates over the subtrees rooted at the given IDs, or the
+// whole Tree if none are given, in depth first post-order: children before
+// parents.
+func (t *Tree) PostOrder(ids ...string) *Iterator {
+	type frame struct {
+		node     *Node
+		expanded bool
+	}
+
+	stack := []frame{}
+	for _, n := range reversed(t.start(ids)) {
+		stack = append(stack, frame{node: n})
+	}
+	seen := map[string]bool{}
+
+	return &Iterator{next: func() *Node {
+		for len(stack) > 0 {
+			f := stack[len(stack)-1]
+			if f.expanded {
+				stack = stack[:len(stack)-1]
+				return f.node
+			}
+
+			if seen[f.node.ID] {
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			seen[f.node.ID] = true
+
+			stack[len(stack)-1].expanded = true
+			for _, child := range reversed(t.children[f.node.ID]) {
+				stack = append(stack, frame{node: child})
+			}
+		}
+
+		return nil
+	}}
+}
+
+// Walk calls fn for every Node in the subtrees rooted at the given IDs, or
+// the whole Tree if none are given, in depth first pre-order. If fn returns
+// SkipSubtree the children of that Node are skipped, any other error stops
+// the walk and is returned.
+func (t *Tree) Walk(fn WalkFunc, ids ...string) error {
+	seen := map[string]bool{}
+
+	var walk func(n *Node, depth int) error
+	walk = func(n *Node, depth int) error {
+		if seen[n.ID] {
+			return nil
+		}
+		seen[n.ID] = true
+
+		if err := fn(n, depth); err == SkipSubtree {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		for _, child := range t.children[n.ID] {
+			if err := walk(child, depth+1); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for _, n := range t.start(ids) {
+		if err := walk(n, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reversed returns a reversed copy of nodes.
+func reversed(nodes []*Node) []*Node {
+	out := make([]*Node, len(nodes))
+	for i, n := range nodes {
+		out[len(nodes)-1-i] = n
+	}
+
+	return out
+}
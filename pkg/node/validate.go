@@ -0,0 +1,160 @@
+package node
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrSelfParent is returned when a Node would be its own parent or child.
+	ErrSelfParent = errors.New("node cannot be its own parent")
+
+	// ErrDuplicateChild is returned when a Node would be registered as a
+	// child of the same parent twice.
+	ErrDuplicateChild = errors.New("node is already a child of this parent")
+
+	// ErrHasParent is returned when a Node that already has a parent is
+	// registered with another one without being detached from the first.
+	ErrHasParent = errors.New("node already has a different parent")
+
+	// ErrMaxFanOut is returned when a Node would have more children than
+	// Limits.MaxFanOut allows.
+	ErrMaxFanOut = errors.New("node has too many children")
+
+	// ErrMaxDepth is returned when a Node would be deeper than Limits.MaxDepth allows.
+	ErrMaxDepth = errors.New("node is too deep")
+
+	// ErrInvalid is returned when a Node breaks one of the other invariants
+	// checked by Validate.
+	ErrInvalid = errors.New("invalid node")
+)
+
+// Validate checks the invariants a single Node has to hold before it is
+// stored. The errors it returns wrap one of the Err values in this package,
+// use errors.Cause to check which.
+func (n Node) Validate() error {
+	if n.ID == "" {
+		return errors.Wrap(ErrInvalid, "node has no ID")
+	}
+
+	if n.ParentID == n.ID {
+		return errors.Wrapf(ErrSelfParent, "node %s", n.ID)
+	}
+
+	if n.ChildCount > 0 && n.ChildCount < len(n.ChildIDs) {
+		return errors.Wrapf(ErrInvalid, "node %s has %d ChildIDs, but a ChildCount of %d", n.ID, len(n.ChildIDs), n.ChildCount)
+	}
+
+	seen := map[string]bool{}
+	for _, id := range n.ChildIDs {
+		switch {
+		case id == "":
+			return errors.Wrapf(ErrInvalid, "node %s has an empty child ID", n.ID)
+		case id == n.ID:
+			return errors.Wrapf(ErrSelfParent, "node %s", n.ID)
+		case seen[id]:
+			return errors.Wrapf(ErrDuplicateChild, "node %s lists child %s more than once", n.ID, id)
+		}
+		seen[id] = true
+	}
+
+	return nil
+}
+
+// Limits constrains the shape of a tree. A limit that is not positive is
+// not enforced.
+type Limits struct {
+	// MaxDepth is the most ancestors a Node can have, i.e. roots are at depth 0.
+	MaxDepth int
+
+	// MaxFanOut is the most children a Node can have.
+	MaxFanOut int
+}
+
+// WithLimits makes the Client refuse to write Nodes that exceed the given
+// Limits. Every Node is checked with Validate before it is written,
+// whether Limits are given or not.
+func WithLimits(l Limits) ClientOption {
+	return func(c *Client) {
+		c.limits = l
+	}
+}
+
+// checkFanOut returns ErrMaxFanOut if n has more children than allowed.
+func (l Limits) checkFanOut(n *Node) error {
+	if l.MaxFanOut > 0 && n.NumChildren() > l.MaxFanOut {
+		return errors.Wrapf(ErrMaxFanOut, "node %s has %d children, the limit is %d", n.ID, n.NumChildren(), l.MaxFanOut)
+	}
+
+	return nil
+}
+
+// checkDepth returns ErrMaxDepth if a Node at depth is deeper than allowed.
+func (l Limits) checkDepth(id string, depth int) error {
+	if l.MaxDepth > 0 && depth > l.MaxDepth {
+		return errors.Wrapf(ErrMaxDepth, "node %s would be at depth %d, the limit is %d", id, depth, l.MaxDepth)
+	}
+
+	return nil
+}
+
+// validateWrite checks every Node about to be written, returning the first
+// problem found. The depth of a Node is worked out from the other Nodes being
+// written, falling back to the ancestors of its parent in the table.
+func (c Client) validateWrite(nodes []*Node) error {
+	byID := map[string]*Node{}
+	for _, n := range nodes {
+		if err := n.Validate(); err != nil {
+			return err
+		}
+		if err := c.limits.checkFanOut(n); err != nil {
+			return err
+		}
+		byID[n.ID] = n
+	}
+
+	if c.limits.MaxDepth <= 0 {
+		return nil
+	}
+
+	depths := map[string]int{}
+	var depthOf func(n *Node, seen int) (int, error)
+	depthOf = func(n *Node, seen int) (int, error) {
+		if d, ok := depths[n.ID]; ok {
+			return d, nil
+		}
+
+		if seen > len(byID) {
+			return 0, errors.Wrapf(ErrSelfParent, "node %s is its own ancestor", n.ID)
+		}
+
+		d := 0
+		if parent, ok := byID[n.ParentID]; ok {
+			pd, err := depthOf(parent, seen+1)
+			if err != nil {
+				return 0, err
+			}
+			d = pd + 1
+		} else if n.ParentID != "" {
+			ancestors, err := c.Ancestors(n.ParentID)
+			if err != nil {
+				return 0, errors.Wrapf(err, "error checking depth of node %s", n.ID)
+			}
+			d = len(ancestors) + 1
+		}
+
+		depths[n.ID] = d
+		return d, nil
+	}
+
+	for _, n := range nodes {
+		d, err := depthOf(n, 0)
+		if err != nil {
+			return err
+		}
+		if err := c.limits.checkDepth(n.ID, d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/erumble/dynamo-playground/pkg/logger/loggertest"
+	"github.com/erumble/dynamo-playground/pkg/node/dynamotest"
+	"github.com/pkg/errors"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		node Node
+		want error
+	}{
+		{"valid", Node{ID: "a", ParentID: "p", ChildIDs: []string{"b", "c"}, ChildCount: 2}, nil},
+		{"more children than listed", Node{ID: "a", ChildIDs: []string{"b"}, ChildCount: 5}, nil},
+		{"no ID", Node{ParentID: "p"}, ErrInvalid},
+		{"own parent", Node{ID: "a", ParentID: "a"}, ErrSelfParent},
+		{"own child", Node{ID: "a", ChildIDs: []string{"b", "a"}}, ErrSelfParent},
+		{"fewer children than listed", Node{ID: "a", ChildIDs: []string{"b", "c"}, ChildCount: 1}, ErrInvalid},
+		{"empty child ID", Node{ID: "a", ChildIDs: []string{"b", ""}}, ErrInvalid},
+		{"duplicate child", Node{ID: "a", ChildIDs: []string{"b", "c", "b"}}, ErrDuplicateChild},
+	}
+
+	for _, tt := range tests {
+		if err := tt.node.Validate(); errors.Cause(err) != tt.want {
+			t.Errorf("%s: Validate = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRegisterChildInvariants(t *testing.T) {
+	parent, children := family(1, 1)
+	other, _ := family(2, 0)
+
+	tests := []struct {
+		name  string
+		child *Node
+		want  error
+	}{
+		{"itself", parent, ErrSelfParent},
+		{"twice", children[0], ErrDuplicateChild},
+		{"another parent's child", other.CreateChild(), ErrHasParent},
+	}
+
+	for _, tt := range tests {
+		if err := parent.RegisterChild(tt.child); errors.Cause(err) != tt.want {
+			t.Errorf("%s: RegisterChild = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if parent.NumChildren() != 1 {
+		t.Errorf("NumChildren = %d after the failed registrations, want 1", parent.NumChildren())
+	}
+}
+
+func TestMaxFanOut(t *testing.T) {
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents", WithLimits(Limits{MaxFanOut: 2}))
+
+	wide, _ := family(1, 3)
+	if err := c.Put(*wide); errors.Cause(err) != ErrMaxFanOut {
+		t.Errorf("Put of a Node with 3 children = %v, want ErrMaxFanOut", err)
+	}
+	if err := c.BatchPut([]*Node{wide}); errors.Cause(err) != ErrMaxFanOut {
+		t.Errorf("BatchPut of a Node with 3 children = %v, want ErrMaxFanOut", err)
+	}
+
+	parent, children := storeParent(t, c, 3)
+	for _, child := range children[:2] {
+		if err := c.AddChild(parent.ID, child); err != nil {
+			t.Fatalf("AddChild: %v", err)
+		}
+	}
+
+	if err := c.AddChild(parent.ID, children[2]); errors.Cause(err) != ErrMaxFanOut {
+		t.Errorf("AddChild to a full parent = %v, want ErrMaxFanOut", err)
+	}
+	if err := c.InsertChild(parent.ID, children[2], 0); errors.Cause(err) != ErrMaxFanOut {
+		t.Errorf("InsertChild into a full parent = %v, want ErrMaxFanOut", err)
+	}
+	if _, err := c.getExisting(children[2].ID); errors.Cause(err) != ErrNotFound {
+		t.Errorf("the rejected child was stored: %v", err)
+	}
+	checkChildren(t, c, parent.ID, idsOf(children[:2]))
+}
+
+// TestMaxFanOutCondition checks that the update of the parent is conditional
+// on MaxFanOut, for parents filled up by a Client without the limit.
+func TestMaxFanOutCondition(t *testing.T) {
+	for _, cs := range childStorages {
+		t.Run(cs.name, func(t *testing.T) {
+			db := dynamotest.New()
+			unlimited := NewClient(loggertest.Nop(), db, "nodes", "parents", WithChildStorage(cs.storage))
+			c := NewClient(loggertest.Nop(), db, "nodes", "parents", WithChildStorage(cs.storage), WithLimits(Limits{MaxFanOut: 2}))
+
+			parent, children := storeParent(t, unlimited, 3)
+			for _, child := range children {
+				if err := unlimited.AddChild(parent.ID, child); err != nil {
+					t.Fatalf("AddChild: %v", err)
+				}
+			}
+
+			stored, err := c.getExisting(parent.ID, ConsistentRead(true))
+			if err != nil {
+				t.Fatalf("Get parent: %v", err)
+			}
+
+			// Skip the check AddChild makes before the update, as if the
+			// parent was read before it filled up.
+			if err := c.incrementChildCount(stored, "new", ""); errors.Cause(err) != ErrMaxFanOut {
+				t.Errorf("incrementChildCount of a full parent = %v, want ErrMaxFanOut", err)
+			}
+			checkChildren(t, c, parent.ID, idsOf(children))
+		})
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	c := NewClient(loggertest.Nop(), dynamotest.New(), "nodes", "parents", WithLimits(Limits{MaxDepth: 1}))
+
+	root := &Node{ID: "root"}
+	child, grandchild := &Node{ID: "child"}, &Node{ID: "grandchild"}
+	if err := root.RegisterChild(child); err != nil {
+		t.Fatalf("RegisterChild: %v", err)
+	}
+	if err := child.RegisterChild(grandchild); err != nil {
+		t.Fatalf("RegisterChild: %v", err)
+	}
+
+	// The depth of a Node is worked out from the others in the batch.
+	if err := c.BatchPut([]*Node{grandchild, child, root}); errors.Cause(err) != ErrMaxDepth {
+		t.Errorf("BatchPut of a tree 2 deep = %v, want ErrMaxDepth", err)
+	}
+
+	if err := c.BatchPut([]*Node{root, child}); err != nil {
+		t.Fatalf("BatchPut: %v", err)
+	}
+
+	// Or from the ancestors of its parent in the table.
+	if err := c.Put(*grandchild); errors.Cause(err) != ErrMaxDepth {
+		t.Errorf("Put below the limit = %v, want ErrMaxDepth", err)
+	}
+	if err := c.AddChild(child.ID, &Node{ID: "added"}); errors.Cause(err) != ErrMaxDepth {
+		t.Errorf("AddChild below the limit = %v, want ErrMaxDepth", err)
+	}
+	if err := c.AddChild(root.ID, &Node{ID: "sibling"}); err != nil {
+		t.Errorf("AddChild within the limit: %v", err)
+	}
+
+	if err := c.Put(Node{ID: "orphan", ParentID: "missing"}); errors.Cause(err) != ErrNotFound {
+		t.Errorf("Put of a Node with a missing parent = %v, want ErrNotFound", err)
+	}
+
+	// A cycle within the batch has no depth.
+	a, b := &Node{ID: "a", ParentID: "b"}, &Node{ID: "b", ParentID: "a"}
+	if err := c.BatchPut([]*Node{a, b}); errors.Cause(err) != ErrSelfParent {
+		t.Errorf("BatchPut of a cycle = %v, want ErrSelfParent", err)
+	}
+}
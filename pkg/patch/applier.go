@@ -0,0 +1,643 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/erumble/dynamo-playground/pkg/logger"
+	"github.com/erumble/dynamo-playground/pkg/node"
+	"github.com/pkg/errors"
+)
+
+// StepKind is the kind of a Step.
+type StepKind string
+
+const (
+	// PutStep stores Nodes.
+	PutStep StepKind = "put"
+	// DeleteStep removes the Nodes with the given IDs.
+	DeleteStep StepKind = "delete"
+	// ReorderStep rearranges the children of ParentID.
+	ReorderStep StepKind = "reorder"
+)
+
+// Step is a single batch of writes in a Plan.
+type Step struct {
+	Kind StepKind
+
+	// Nodes are the Nodes to store for PutStep.
+	Nodes []*node.Node
+
+	// IDs are the Nodes to remove for DeleteStep, or the new order of the
+	// children for ReorderStep.
+	IDs []string
+
+	// ParentID is the Node whose children are rearranged for ReorderStep.
+	ParentID string
+}
+
+// String returns a human readable description of the Step.
+func (s Step) String() string {
+	switch s.Kind {
+	case PutStep:
+		ids := []string{}
+		for _, n := range s.Nodes {
+			ids = append(ids, n.ID)
+		}
+		return fmt.Sprintf("put %d node(s): %s", len(ids), strings.Join(ids, ", "))
+	case DeleteStep:
+		return fmt.Sprintf("delete %d node(s): %s", len(s.IDs), strings.Join(s.IDs, ", "))
+	case ReorderStep:
+		return fmt.Sprintf("reorder children of %s: %s", s.ParentID, strings.Join(s.IDs, ", "))
+	}
+
+	return string(s.Kind)
+}
+
+// Plan is the ordered list of Steps that applies a set of Operations.
+type Plan struct {
+	Steps []Step
+}
+
+// String returns a human readable description of every Step, one per line,
+// so a Plan can be reviewed before it is applied.
+func (p Plan) String() string {
+	buf := &bytes.Buffer{}
+	for i, s := range p.Steps {
+		fmt.Fprintf(buf, "%d. %s\n", i+1, s)
+	}
+
+	return buf.String()
+}
+
+// Applier validates Operations against the table behind a node.Client, and
+// applies them.
+type Applier struct {
+	client node.Client
+	log    logger.LeveledLogger
+}
+
+// NewApplier creates an Applier that reads and writes through the given client.
+func NewApplier(logger logger.LeveledLogger, client node.Client) Applier {
+	return Applier{
+		client: client,
+		log:    logger.Indent("patchApplier"),
+	}
+}
+
+// Plan validates the given Operations against the current state of the table
+// and orders them into a Plan. New Nodes are created parents first, then
+// existing Nodes are updated, then deleted Nodes are removed children first,
+// and finally children are reordered. Nothing is written.
+func (a Applier) Plan(ops []Operation) (Plan, error) {
+	log := a.log.Indent("Plan")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	p := &planner{client: a.client, ops: ops, state: map[string]*node.Node{}, dirty: map[string]bool{}}
+
+	steps := []func() error{p.index, p.load, p.validate, p.apply}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return Plan{}, errors.Wrap(err, "Applier.Plan")
+		}
+	}
+
+	return p.plan(), nil
+}
+
+// Apply executes the Steps of the given Plan in order. The Steps are not
+// transactional, if one fails the Steps before it remain applied.
+func (a Applier) Apply(p Plan) error {
+	log := a.log.Indent("Apply")
+	log.Debug("called...")
+	defer log.Debug("exited")
+
+	for i, s := range p.Steps {
+		log.Debugf("step %d: %s", i+1, s)
+
+		var err error
+		switch s.Kind {
+		case PutStep:
+			err = a.client.BatchPut(s.Nodes)
+		case DeleteStep:
+			err = a.client.BatchDelete(s.IDs)
+		case ReorderStep:
+			err = a.reorder(s.ParentID, s.IDs)
+		default:
+			err = errors.Errorf("unknown step kind %q", s.Kind)
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "Applier.Apply: step %d (%s) failed", i+1, s.Kind)
+		}
+	}
+
+	return nil
+}
+
+// reorder moves the given children of parentID to the front, in the given
+// order, followed by any other children in their current order.
+func (a Applier) reorder(parentID string, ids []string) error {
+	parent, err := a.client.Get(parentID, node.ConsistentRead(true))
+	if err != nil {
+		return err
+	}
+
+	current := parent.ChildIDs
+	if len(current) == 0 {
+		children, err := a.client.GetChildren(*parent, node.KeysOnly())
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			current = append(current, child.ID)
+		}
+	}
+
+	listed := map[string]bool{}
+	order := []string{}
+	for _, id := range ids {
+		listed[id] = true
+		order = append(order, id)
+	}
+	for _, id := range current {
+		if !listed[id] {
+			order = append(order, id)
+		}
+	}
+
+	return a.client.ReorderChildren(parentID, order)
+}
+
+// planner holds the state used to build a Plan.
+type planner struct {
+	client node.Client
+	ops    []Operation
+
+	creates  map[string]*node.Node
+	deletes  map[string]bool
+	moves    map[string]string
+	updates  map[string]string
+	reorders []Operation
+
+	// state holds the existing Nodes the Operations touch, updated in place
+	// as the Operations are applied, and dirty marks the ones that changed.
+	state map[string]*node.Node
+	dirty map[string]bool
+}
+
+// index groups the Operations by kind, rejecting conflicting ones.
+func (p *planner) index() error {
+	p.creates = map[string]*node.Node{}
+	p.deletes = map[string]bool{}
+	p.moves = map[string]string{}
+	p.updates = map[string]string{}
+
+	for _, op := range p.ops {
+		if p.deletes[op.ID] || (op.Op != Reorder && p.creates[op.ID] != nil) {
+			return errors.Errorf("conflicting operations for node %s", op.ID)
+		}
+
+		switch op.Op {
+		case Create:
+			if op.Node == nil || op.Node.ID != op.ID {
+				return errors.Errorf("create operation for node %s has no matching node", op.ID)
+			}
+			if _, ok := p.moves[op.ID]; ok {
+				return errors.Errorf("conflicting operations for node %s", op.ID)
+			}
+			if _, ok := p.updates[op.ID]; ok {
+				return errors.Errorf("conflicting operations for node %s", op.ID)
+			}
+
+			n := *op.Node
+			n.ChildIDs, n.ChildCount, n.Position = nil, 0, ""
+			p.creates[op.ID] = &n
+
+		case Delete:
+			_, moved := p.moves[op.ID]
+			_, updated := p.updates[op.ID]
+			if moved || updated {
+				return errors.Errorf("conflicting operations for node %s", op.ID)
+			}
+			p.deletes[op.ID] = true
+
+		case Move:
+			if op.ParentID == op.ID {
+				return errors.Errorf("node %s cannot be its own parent", op.ID)
+			}
+			p.moves[op.ID] = op.ParentID
+
+		case UpdateMetadata:
+			p.updates[op.ID] = op.Metadata
+
+		case Reorder:
+			p.reorders = append(p.reorders, op)
+
+		default:
+			return errors.Errorf("unknown operation %q for node %s", op.Op, op.ID)
+		}
+	}
+
+	return nil
+}
+
+// load fetches every existing Node the Operations touch, including the
+// current parents of the Nodes that are moved or deleted.
+func (p *planner) load() error {
+	ids := map[string]bool{}
+	for _, op := range p.ops {
+		ids[op.ID] = true
+		if op.Op == Create && op.Node.ParentID != "" {
+			ids[op.Node.ParentID] = true
+		}
+		if op.Op == Move && op.ParentID != "" {
+			ids[op.ParentID] = true
+		}
+	}
+
+	if err := p.fetch(ids); err != nil {
+		return err
+	}
+
+	parents := map[string]bool{}
+	for id := range p.deletes {
+		if n, ok := p.state[id]; ok && n.ParentID != "" {
+			parents[n.ParentID] = true
+		}
+	}
+	for id := range p.moves {
+		if n, ok := p.state[id]; ok && n.ParentID != "" {
+			parents[n.ParentID] = true
+		}
+	}
+
+	return p.fetch(parents)
+}
+
+func (p *planner) fetch(ids map[string]bool) error {
+	missing := []string{}
+	for id := range ids {
+		if _, ok := p.state[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	nodes, err := p.client.BatchGet(missing, node.ConsistentRead(true))
+	if err != nil {
+		return errors.Wrap(err, "error fetching current state")
+	}
+
+	for _, n := range nodes {
+		p.state[n.ID] = n
+	}
+
+	return nil
+}
+
+// exists returns true if the Node with the given ID will exist once the
+// Operations are applied.
+func (p *planner) exists(id string) bool {
+	if p.deletes[id] {
+		return false
+	}
+
+	_, ok := p.state[id]
+	return ok || p.creates[id] != nil
+}
+
+// validate checks the Operations against the current state of the table.
+func (p *planner) validate() error {
+	for id, n := range p.creates {
+		if _, ok := p.state[id]; ok {
+			return errors.Errorf("cannot create node %s, it already exists", id)
+		}
+		if n.ParentID != "" && !p.exists(n.ParentID) {
+			return errors.Errorf("cannot create node %s, parent %s does not exist", id, n.ParentID)
+		}
+
+		seen := map[string]bool{}
+		for c := n; c != nil; c = p.creates[c.ParentID] {
+			if seen[c.ID] {
+				return errors.Errorf("cannot create node %s, its new ancestors form a cycle", id)
+			}
+			seen[c.ID] = true
+		}
+	}
+
+	for id := range p.updates {
+		if _, ok := p.state[id]; !ok {
+			return errors.Errorf("cannot update node %s, it does not exist", id)
+		}
+	}
+
+	for id, parentID := range p.moves {
+		if _, ok := p.state[id]; !ok {
+			return errors.Errorf("cannot move node %s, it does not exist", id)
+		}
+		if parentID == "" {
+			continue
+		}
+		if !p.exists(parentID) {
+			return errors.Errorf("cannot move node %s, parent %s does not exist", id, parentID)
+		}
+		if err := p.checkCycle(id, parentID); err != nil {
+			return err
+		}
+	}
+
+	for id := range p.deletes {
+		n, ok := p.state[id]
+		if !ok {
+			return errors.Errorf("cannot delete node %s, it does not exist", id)
+		}
+
+		children, err := p.client.GetChildren(*n, node.KeysOnly())
+		if err != nil {
+			return errors.Wrapf(err, "error fetching children of %s", id)
+		}
+
+		for _, child := range children {
+			if _, moved := p.moves[child.ID]; !moved && !p.deletes[child.ID] {
+				return errors.Errorf("cannot delete node %s, its child %s would be orphaned", id, child.ID)
+			}
+		}
+	}
+
+	for _, op := range p.reorders {
+		if !p.exists(op.ID) {
+			return errors.Errorf("cannot reorder children of %s, it does not exist", op.ID)
+		}
+
+		children, err := p.children(op.ID)
+		if err != nil {
+			return err
+		}
+
+		listed := map[string]bool{}
+		for _, id := range op.ChildIDs {
+			if !children[id] {
+				return errors.Errorf("cannot reorder children of %s, %s is not one of them", op.ID, id)
+			}
+			if listed[id] {
+				return errors.Errorf("cannot reorder children of %s, %s is listed twice", op.ID, id)
+			}
+			listed[id] = true
+		}
+	}
+
+	return nil
+}
+
+// children returns the IDs of the children the Node with the given ID will
+// have once the Operations are applied: those it has now that are neither
+// moved nor deleted, and those moved under it or created under it.
+func (p *planner) children(id string) (map[string]bool, error) {
+	children := map[string]bool{}
+
+	if n, ok := p.state[id]; ok {
+		current, err := p.client.GetChildren(*n, node.KeysOnly())
+		if err != nil {
+			return nil, errors.Wrapf(err, "error fetching children of %s", id)
+		}
+
+		for _, child := range current {
+			if _, moved := p.moves[child.ID]; !moved && !p.deletes[child.ID] {
+				children[child.ID] = true
+			}
+		}
+	}
+
+	for childID, parentID := range p.moves {
+		if parentID == id {
+			children[childID] = true
+		}
+	}
+	for childID, n := range p.creates {
+		if n.ParentID == id {
+			children[childID] = true
+		}
+	}
+
+	return children, nil
+}
+
+// checkCycle returns an error if moving id under parentID would make it its
+// own ancestor. New parents are followed through the Operations, existing
+// ones through their ancestors in the table.
+func (p *planner) checkCycle(id, parentID string) error {
+	for next := parentID; next != ""; {
+		if next == id {
+			return errors.Errorf("cannot move node %s under its own descendant %s", id, parentID)
+		}
+
+		if n, ok := p.creates[next]; ok {
+			next = n.ParentID
+			continue
+		}
+
+		ancestors, err := p.client.Ancestors(next)
+		if err != nil {
+			return errors.Wrapf(err, "error fetching ancestors of %s", next)
+		}
+
+		for _, a := range ancestors {
+			if a == id {
+				return errors.Errorf("cannot move node %s under its own descendant %s", id, parentID)
+			}
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+// apply applies the Operations to the in memory state.
+func (p *planner) apply() error {
+	// Detach moved and deleted Nodes from their current parents first, so
+	// their parents' child counts are right before anything is added.
+	for id := range p.moves {
+		p.detach(p.state[id])
+	}
+	for id := range p.deletes {
+		p.detach(p.state[id])
+	}
+
+	for id, metadata := range p.updates {
+		p.state[id].Metadata = metadata
+		p.dirty[id] = true
+	}
+
+	for _, id := range sortedMoveIDs(p.moves) {
+		n := p.state[id]
+		n.ParentID, n.Position = "", ""
+		if parentID := p.moves[id]; parentID != "" {
+			if err := p.parent(parentID).RegisterChild(n); err != nil {
+				return errors.Wrapf(err, "cannot move node %s", id)
+			}
+		}
+		p.dirty[id] = true
+	}
+
+	for _, n := range p.createOrder() {
+		if n.ParentID != "" {
+			if err := p.parent(n.ParentID).RegisterChild(n); err != nil {
+				return errors.Wrapf(err, "cannot create node %s", n.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// detach removes n from the children of its current parent. n keeps its
+// ParentID and Position, deletes are ordered by them, and moves clear them
+// before registering n with its new parent.
+func (p *planner) detach(n *node.Node) {
+	parent, ok := p.state[n.ParentID]
+	if !ok {
+		return
+	}
+
+	// RemoveChild also keeps the parent from handing n's Position out again.
+	// It can't error, n's ParentID is the parent's ID.
+	parentID, position := n.ParentID, n.Position
+	_, _ = parent.RemoveChild(n)
+	n.ParentID, n.Position = parentID, position
+
+	p.dirty[parent.ID] = true
+}
+
+// parent returns the Node with the given ID, whether it is being created or
+// already exists, marking existing Nodes as changed.
+func (p *planner) parent(id string) *node.Node {
+	if n, ok := p.creates[id]; ok {
+		return n
+	}
+
+	p.dirty[id] = true
+	return p.state[id]
+}
+
+// createOrder returns the Nodes to create, parents before their children.
+func (p *planner) createOrder() []*node.Node {
+	ordered := []*node.Node{}
+	for _, level := range p.createLevels() {
+		ordered = append(ordered, level...)
+	}
+
+	return ordered
+}
+
+// createLevels groups the Nodes to create by how many of their ancestors are
+// also being created.
+func (p *planner) createLevels() [][]*node.Node {
+	levels := [][]*node.Node{}
+	for _, id := range sortedNodeIDs(p.creates) {
+		depth := 0
+		for n := p.creates[id]; p.creates[n.ParentID] != nil; n = p.creates[n.ParentID] {
+			depth++
+		}
+
+		for len(levels) <= depth {
+			levels = append(levels, []*node.Node{})
+		}
+		levels[depth] = append(levels[depth], p.creates[id])
+	}
+
+	return levels
+}
+
+// deleteLevels groups the Nodes to delete by how many of their ancestors are
+// also being deleted, deepest first.
+func (p *planner) deleteLevels() [][]string {
+	levels := [][]string{}
+	for _, id := range sortedIDs(p.deletes) {
+		depth := 0
+		for n := p.state[id]; p.deletes[n.ParentID]; n = p.state[n.ParentID] {
+			depth++
+		}
+
+		for len(levels) <= depth {
+			levels = append(levels, []string{})
+		}
+		levels[depth] = append(levels[depth], id)
+	}
+
+	for i, j := 0, len(levels)-1; i < j; i, j = i+1, j-1 {
+		levels[i], levels[j] = levels[j], levels[i]
+	}
+
+	return levels
+}
+
+// plan builds the Plan from the in memory state.
+func (p *planner) plan() Plan {
+	steps := []Step{}
+
+	for _, level := range p.createLevels() {
+		steps = append(steps, Step{Kind: PutStep, Nodes: level})
+	}
+
+	updated := []*node.Node{}
+	for _, id := range sortedIDs(p.dirty) {
+		if !p.deletes[id] {
+			updated = append(updated, p.state[id])
+		}
+	}
+	if len(updated) > 0 {
+		steps = append(steps, Step{Kind: PutStep, Nodes: updated})
+	}
+
+	for _, level := range p.deleteLevels() {
+		steps = append(steps, Step{Kind: DeleteStep, IDs: level})
+	}
+
+	for _, op := range p.reorders {
+		steps = append(steps, Step{Kind: ReorderStep, ParentID: op.ID, IDs: op.ChildIDs})
+	}
+
+	return Plan{Steps: steps}
+}
+
+// sortedIDs returns the IDs in the given set in order, so Plans are
+// deterministic.
+func sortedIDs(set map[string]bool) []string {
+	ids := []string{}
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// sortedNodeIDs returns the IDs of the given Nodes in order.
+func sortedNodeIDs(nodes map[string]*node.Node) []string {
+	ids := []string{}
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// sortedMoveIDs returns the IDs of the moved Nodes in order.
+func sortedMoveIDs(moves map[string]string) []string {
+	ids := []string{}
+	for id := range moves {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
@@ -0,0 +1,302 @@
+package patch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/erumble/dynamo-playground/pkg/logger/loggertest"
+	"github.com/erumble/dynamo-playground/pkg/node"
+	"github.com/erumble/dynamo-playground/pkg/node/dynamotest"
+	"github.com/pkg/errors"
+)
+
+// newTable returns a Client on a table holding a root r with children x and
+// y, and x's child x1.
+func newTable(t *testing.T, opts ...node.ClientOption) (node.Client, *dynamotest.Fake) {
+	t.Helper()
+
+	db := dynamotest.New()
+	c := node.NewClient(loggertest.Nop(), db, "nodes", "parents", opts...)
+
+	r, x, y, x1 := &node.Node{ID: "r"}, &node.Node{ID: "x"}, &node.Node{ID: "y"}, &node.Node{ID: "x1"}
+	for _, pair := range [][2]*node.Node{{r, x}, {r, y}, {x, x1}} {
+		if err := pair[0].RegisterChild(pair[1]); err != nil {
+			t.Fatalf("RegisterChild: %v", err)
+		}
+	}
+
+	if err := c.BatchPut([]*node.Node{r, x, y, x1}); err != nil {
+		t.Fatalf("BatchPut: %v", err)
+	}
+
+	return c, db
+}
+
+// steps describes each Step of the Plan.
+func steps(p Plan) []string {
+	s := []string{}
+	for _, step := range p.Steps {
+		s = append(s, step.String())
+	}
+
+	return s
+}
+
+// children returns the IDs of the children of the Node with the given ID.
+func children(t *testing.T, c node.Client, id string) []string {
+	t.Helper()
+
+	parent, err := c.Get(id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	nodes, err := c.GetChildren(*parent)
+	if err != nil {
+		t.Fatalf("GetChildren: %v", err)
+	}
+
+	ids := []string{}
+	for _, n := range nodes {
+		ids = append(ids, n.ID)
+	}
+
+	return ids
+}
+
+func TestPlanOrder(t *testing.T) {
+	c, db := newTable(t)
+	a := NewApplier(loggertest.Nop(), c)
+
+	// The Operations are given in the wrong order on purpose.
+	ops := []Operation{
+		{Op: Reorder, ID: "r", ChildIDs: []string{"c1", "y"}},
+		{Op: Create, ID: "c2", Node: &node.Node{ID: "c2", ParentID: "c1"}},
+		{Op: Delete, ID: "x"},
+		{Op: Create, ID: "c1", Node: &node.Node{ID: "c1", ParentID: "r"}},
+		{Op: UpdateMetadata, ID: "y", Metadata: "updated"},
+		{Op: Delete, ID: "x1"},
+	}
+
+	before := db.Len()
+	p, err := a.Plan(ops)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if db.Len() != before {
+		t.Errorf("Plan changed the table")
+	}
+
+	want := []string{
+		"put 1 node(s): c1",
+		"put 1 node(s): c2",
+		"put 2 node(s): r, y",
+		"delete 1 node(s): x1",
+		"delete 1 node(s): x",
+		"reorder children of r: c1, y",
+	}
+	if got := steps(p); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if err := a.Apply(p); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	if got := children(t, c, "r"); !reflect.DeepEqual(got, []string{"c1", "y"}) {
+		t.Errorf("children of r = %v, want [c1 y]", got)
+	}
+	if got := children(t, c, "c1"); !reflect.DeepEqual(got, []string{"c2"}) {
+		t.Errorf("children of c1 = %v, want [c2]", got)
+	}
+	for _, id := range []string{"x", "x1"} {
+		if n, err := c.Get(id); err != nil || n.Position != "" || n.ParentID != "" {
+			t.Errorf("Get(%s) = %+v, %v, want it deleted", id, n, err)
+		}
+	}
+	if y, err := c.Get("y"); err != nil || y.Metadata != "updated" {
+		t.Errorf("Get(y) = %+v, %v, want its Metadata updated", y, err)
+	}
+}
+
+func TestPlanMove(t *testing.T) {
+	for _, storage := range []node.ChildStorage{node.InlineChildren, node.IndexedChildren} {
+		c, _ := newTable(t, node.WithChildStorage(storage))
+		a := NewApplier(loggertest.Nop(), c)
+
+		p, err := a.Plan([]Operation{
+			{Op: Move, ID: "y", ParentID: "x"},
+			{Op: Reorder, ID: "x", ChildIDs: []string{"y", "x1"}},
+		})
+		if err != nil {
+			t.Fatalf("Plan: %v", err)
+		}
+
+		want := []string{"put 3 node(s): r, x, y", "reorder children of x: y, x1"}
+		if got := steps(p); !reflect.DeepEqual(got, want) {
+			t.Errorf("Plan = %v, want %v", got, want)
+		}
+
+		if err := a.Apply(p); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+		if got := children(t, c, "x"); !reflect.DeepEqual(got, []string{"y", "x1"}) {
+			t.Errorf("children of x = %v, want [y x1]", got)
+		}
+		if got := children(t, c, "r"); !reflect.DeepEqual(got, []string{"x"}) {
+			t.Errorf("children of r = %v, want [x]", got)
+		}
+	}
+}
+
+func TestPlanRejects(t *testing.T) {
+	create := func(id, parentID string) Operation {
+		return Operation{Op: Create, ID: id, Node: &node.Node{ID: id, ParentID: parentID}}
+	}
+
+	tests := []struct {
+		name string
+		ops  []Operation
+		want string
+	}{
+		{"create then delete", []Operation{create("c", "r"), {Op: Delete, ID: "c"}}, "conflicting operations"},
+		{"delete then create", []Operation{{Op: Delete, ID: "y"}, create("y", "r")}, "conflicting operations"},
+		{"create twice", []Operation{create("c", "r"), create("c", "x")}, "conflicting operations"},
+		{"create then move", []Operation{create("c", "r"), {Op: Move, ID: "c", ParentID: "x"}}, "conflicting operations"},
+		{"move then create", []Operation{{Op: Move, ID: "c", ParentID: "x"}, create("c", "r")}, "conflicting operations"},
+		{"update then create", []Operation{{Op: UpdateMetadata, ID: "c"}, create("c", "r")}, "conflicting operations"},
+		{"move then delete", []Operation{{Op: Move, ID: "y", ParentID: "x"}, {Op: Delete, ID: "y"}}, "conflicting operations"},
+		{"update then delete", []Operation{{Op: UpdateMetadata, ID: "y"}, {Op: Delete, ID: "y"}}, "conflicting operations"},
+		{"delete then reorder", []Operation{{Op: Delete, ID: "x1"}, {Op: Reorder, ID: "x1"}}, "conflicting operations"},
+		{"create without node", []Operation{{Op: Create, ID: "c"}}, "no matching node"},
+		{"unknown op", []Operation{{Op: "rename", ID: "y"}}, "unknown operation"},
+		{"create existing", []Operation{create("y", "r")}, "already exists"},
+		{"create under missing", []Operation{create("c", "missing")}, "parent missing does not exist"},
+		{"create under deleted", []Operation{{Op: Delete, ID: "y"}, create("c", "y")}, "parent y does not exist"},
+		{"update missing", []Operation{{Op: UpdateMetadata, ID: "missing"}}, "does not exist"},
+		{"move missing", []Operation{{Op: Move, ID: "missing", ParentID: "r"}}, "does not exist"},
+		{"move under missing", []Operation{{Op: Move, ID: "y", ParentID: "missing"}}, "parent missing does not exist"},
+		{"delete missing", []Operation{{Op: Delete, ID: "missing"}}, "does not exist"},
+		{"delete orphans", []Operation{{Op: Delete, ID: "x"}}, "child x1 would be orphaned"},
+		{"reorder missing", []Operation{{Op: Reorder, ID: "missing"}}, "does not exist"},
+		{"reorder deleted", []Operation{{Op: Reorder, ID: "y"}, {Op: Delete, ID: "y"}}, "does not exist"},
+		{"reorder stranger", []Operation{{Op: Reorder, ID: "r", ChildIDs: []string{"y", "x1"}}}, "x1 is not one of them"},
+		{"reorder moved away", []Operation{{Op: Move, ID: "y", ParentID: "x"}, {Op: Reorder, ID: "r", ChildIDs: []string{"y", "x"}}}, "y is not one of them"},
+		{"reorder deleted child", []Operation{{Op: Delete, ID: "x1"}, {Op: Reorder, ID: "x", ChildIDs: []string{"x1"}}}, "x1 is not one of them"},
+		{"reorder twice", []Operation{{Op: Reorder, ID: "r", ChildIDs: []string{"y", "x", "y"}}}, "y is listed twice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTable(t)
+
+			_, err := NewApplier(loggertest.Nop(), c).Plan(tt.ops)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Plan = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanReorderNewChildren(t *testing.T) {
+	c, _ := newTable(t)
+	a := NewApplier(loggertest.Nop(), c)
+
+	p, err := a.Plan([]Operation{
+		{Op: Create, ID: "c", Node: &node.Node{ID: "c", ParentID: "r"}},
+		{Op: Move, ID: "x1", ParentID: "r"},
+		{Op: Reorder, ID: "r", ChildIDs: []string{"x1", "c", "y"}},
+		{Op: Create, ID: "d", Node: &node.Node{ID: "d"}},
+		{Op: Create, ID: "e", Node: &node.Node{ID: "e", ParentID: "d"}},
+		{Op: Create, ID: "f", Node: &node.Node{ID: "f", ParentID: "d"}},
+		{Op: Reorder, ID: "d", ChildIDs: []string{"f", "e"}},
+	})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	if err := a.Apply(p); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	// Children left out of a Reorder follow those listed.
+	if got := children(t, c, "r"); !reflect.DeepEqual(got, []string{"x1", "c", "y", "x"}) {
+		t.Errorf("children of r = %v, want [x1 c y x]", got)
+	}
+	if got := children(t, c, "d"); !reflect.DeepEqual(got, []string{"f", "e"}) {
+		t.Errorf("children of d = %v, want [f e]", got)
+	}
+}
+
+func TestPlanCycles(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  []Operation
+	}{
+		{"own parent", []Operation{{Op: Move, ID: "x", ParentID: "x"}}},
+		{"under a child", []Operation{{Op: Move, ID: "x", ParentID: "x1"}}},
+		{"under a descendant", []Operation{{Op: Move, ID: "r", ParentID: "x1"}}},
+		{"under a new node", []Operation{
+			{Op: Create, ID: "c", Node: &node.Node{ID: "c", ParentID: "x1"}},
+			{Op: Move, ID: "x", ParentID: "c"},
+		}},
+		{"new nodes", []Operation{
+			{Op: Create, ID: "a", Node: &node.Node{ID: "a", ParentID: "b"}},
+			{Op: Create, ID: "b", Node: &node.Node{ID: "b", ParentID: "a"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTable(t)
+
+			if _, err := NewApplier(loggertest.Nop(), c).Plan(tt.ops); err == nil {
+				t.Error("Plan returned no error")
+			}
+		})
+	}
+}
+
+func TestApplyStopsAtFailure(t *testing.T) {
+	// The second batch write fails.
+	writes := 0
+	failSecond := node.Intercept(func(operation string, input interface{}, next node.Invoker) (interface{}, error) {
+		if operation == "BatchWriteItem" {
+			writes++
+			if writes == 2 {
+				return nil, errors.New("boom")
+			}
+		}
+		return next(operation, input)
+	})
+
+	c, _ := newTable(t, node.WithMiddleware(failSecond))
+	a := NewApplier(loggertest.Nop(), c)
+
+	p, err := a.Plan([]Operation{
+		{Op: Create, ID: "c", Node: &node.Node{ID: "c", ParentID: "y"}},
+		{Op: UpdateMetadata, ID: "x", Metadata: "updated"},
+		{Op: Delete, ID: "x1"},
+	})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	writes = 0
+	err = a.Apply(p)
+	if err == nil || !strings.Contains(err.Error(), "step 2 (put) failed") {
+		t.Fatalf("Apply = %v, want step 2 to fail", err)
+	}
+
+	// The first step stays applied, the ones after the failure never ran.
+	if n, err := c.Get("c"); err != nil || n.ParentID != "y" {
+		t.Errorf("Get(c) = %+v, %v, want the node created by step 1", n, err)
+	}
+	if n, err := c.Get("x"); err != nil || n.Metadata != "" {
+		t.Errorf("Get(x) = %+v, %v, want it not updated", n, err)
+	}
+	if n, err := c.Get("x1"); err != nil || n.ParentID != "x" {
+		t.Errorf("Get(x1) = %+v, %v, want it not deleted", n, err)
+	}
+}
@@ -0,0 +1,70 @@
+// Package patch applies a list of node operations, e.g. the changes found by
+// package diff, to a table.
+package patch
+
+import (
+	"github.com/erumble/dynamo-playground/pkg/diff"
+	"github.com/erumble/dynamo-playground/pkg/node"
+)
+
+// Op is the kind of an Operation.
+type Op string
+
+const (
+	// Create stores a new Node, its parent must exist or be created first.
+	Create Op = "create"
+	// UpdateMetadata replaces the Metadata of an existing Node.
+	UpdateMetadata Op = "update_metadata"
+	// Move changes the parent of an existing Node.
+	Move Op = "move"
+	// Reorder rearranges the children of an existing or created Node.
+	Reorder Op = "reorder"
+	// Delete removes an existing Node, its children must be deleted or moved too.
+	Delete Op = "delete"
+)
+
+// Operation is a single change to make to a table.
+// Which fields are set depends on the Op.
+type Operation struct {
+	Op Op     `json:"op"`
+	ID string `json:"id"`
+
+	// Node is the Node to store for Create, its ParentID, if any, is used as
+	// its parent. Its ChildIDs are ignored, children are created on their own.
+	Node *node.Node `json:"node,omitempty"`
+
+	// ParentID is the new parent for Move, an empty ParentID makes the Node a root.
+	ParentID string `json:"parent_id,omitempty"`
+
+	// Metadata is the new Metadata for UpdateMetadata.
+	Metadata string `json:"metadata,omitempty"`
+
+	// ChildIDs is the new order of the children for Reorder. Each must be a
+	// child once the other Operations are applied, whether it exists or is
+	// created, and be listed once. Children left out follow in their current
+	// order.
+	ChildIDs []string `json:"child_ids,omitempty"`
+}
+
+// FromDiff converts the changes found by diff.Compare into the operations
+// that apply them.
+func FromDiff(r diff.Result) []Operation {
+	ops := []Operation{}
+
+	for _, c := range r.Changes {
+		switch c.Op {
+		case diff.Add:
+			ops = append(ops, Operation{Op: Create, ID: c.ID, Node: c.Node})
+		case diff.Remove:
+			ops = append(ops, Operation{Op: Delete, ID: c.ID})
+		case diff.Move:
+			ops = append(ops, Operation{Op: Move, ID: c.ID, ParentID: c.ToParent})
+		case diff.Modify:
+			ops = append(ops, Operation{Op: UpdateMetadata, ID: c.ID, Metadata: c.ToMetadata})
+		case diff.Reorder:
+			ops = append(ops, Operation{Op: Reorder, ID: c.ID, ChildIDs: c.ToOrder})
+		}
+	}
+
+	return ops
+}
@@ -0,0 +1,131 @@
+// Package ratelimit provides token buckets used to keep DynamoDB clients within
+// the read and write capacity provisioned for a table.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket that refills at a fixed rate up to its burst size.
+// Callers take tokens up front and are made to wait until the bucket is no
+// longer in debt, so large requests are never starved by small ones.
+// It is safe for concurrent use.
+type Bucket struct {
+	mu sync.Mutex
+
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket creates a full Bucket that refills at rate tokens per second and
+// holds at most burst tokens. If burst is not positive it defaults to rate.
+// A rate that is not positive is treated as unlimited, Wait never blocks.
+func NewBucket(rate, burst float64) *Bucket {
+	if burst <= 0 {
+		burst = rate
+	}
+
+	return &Bucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// Wait takes n tokens from the bucket, blocking until the bucket has refilled
+// enough to cover them.
+func (b *Bucket) Wait(n float64) {
+	if d := b.reserve(n); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// Adjust takes n more tokens from the bucket without blocking, or returns
+// them if n is negative. It is used to correct an estimate once the actual
+// cost of a request is known.
+func (b *Bucket) Adjust(n float64) {
+	b.reserve(n)
+}
+
+// reserve takes n tokens and returns how long the caller has to wait for the
+// bucket to pay off any debt.
+func (b *Bucket) reserve(n float64) time.Duration {
+	if b.rate <= 0 {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	b.last = now
+
+	b.tokens -= n
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Limiter meters reads and writes separately, in capacity units per second.
+// A single Limiter can be shared by multiple clients to keep all of them,
+// together, within a table's provisioned throughput.
+type Limiter struct {
+	read  *Bucket
+	write *Bucket
+}
+
+// NewLimiter creates a Limiter that allows readUnits read capacity units and
+// writeUnits write capacity units per second. A limit that is not positive
+// is treated as unlimited.
+func NewLimiter(readUnits, writeUnits float64) *Limiter {
+	l := &Limiter{}
+
+	if readUnits > 0 {
+		l.read = NewBucket(readUnits, readUnits)
+	}
+
+	if writeUnits > 0 {
+		l.write = NewBucket(writeUnits, writeUnits)
+	}
+
+	return l
+}
+
+// WaitRead blocks until units read capacity units are available.
+func (l *Limiter) WaitRead(units float64) {
+	if l.read != nil {
+		l.read.Wait(units)
+	}
+}
+
+// WaitWrite blocks until units write capacity units are available.
+func (l *Limiter) WaitWrite(units float64) {
+	if l.write != nil {
+		l.write.Wait(units)
+	}
+}
+
+// AdjustRead corrects an earlier read estimate by delta capacity units.
+func (l *Limiter) AdjustRead(delta float64) {
+	if l.read != nil {
+		l.read.Adjust(delta)
+	}
+}
+
+// AdjustWrite corrects an earlier write estimate by delta capacity units.
+func (l *Limiter) AdjustWrite(delta float64) {
+	if l.write != nil {
+		l.write.Adjust(delta)
+	}
+}
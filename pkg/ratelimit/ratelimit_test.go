@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketWait(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		burst    float64
+		take     float64
+		minDelay time.Duration
+		maxDelay time.Duration
+	}{
+		{"within burst", 100, 10, 10, 0, 20 * time.Millisecond},
+		{"beyond burst", 100, 10, 15, 40 * time.Millisecond, 200 * time.Millisecond},
+		{"zero rate", 0, 0, 1000, 0, 20 * time.Millisecond},
+		{"negative rate", -1, 5, 1000, 0, 20 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBucket(tt.rate, tt.burst)
+
+			start := time.Now()
+			b.Wait(tt.take)
+			if d := time.Since(start); d < tt.minDelay || d > tt.maxDelay {
+				t.Errorf("Wait(%v) took %v, want between %v and %v", tt.take, d, tt.minDelay, tt.maxDelay)
+			}
+		})
+	}
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+	l := NewLimiter(0, 0)
+
+	start := time.Now()
+	l.WaitRead(1000)
+	l.WaitWrite(1000)
+	l.AdjustRead(1000)
+	l.AdjustWrite(-1000)
+	if d := time.Since(start); d > 20*time.Millisecond {
+		t.Errorf("unlimited Limiter blocked for %v", d)
+	}
+}